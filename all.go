@@ -0,0 +1,157 @@
+package relay
+
+import (
+	"context"
+	"iter"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+// WalkPage is a page yielded by Paginator.All
+type WalkPage[T any] struct {
+	*PaginateResponse[T]
+	// Complete is true on the final page of a walk that has exhausted all pages,
+	// it is false on every page of a walk that is stopped by the budget or the context
+	Complete bool
+}
+
+type walkOptions struct {
+	maxPages int
+}
+
+type WalkOption func(*walkOptions)
+
+// WithMaxPages stops the walk of Paginator.All after n pages
+func WithMaxPages(n int) WalkOption {
+	return func(o *walkOptions) {
+		o.maxPages = n
+	}
+}
+
+// All walks the pages starting from req, forward by after/first, or backward by before/last if req.Last is set.
+// The walk ends after the page that has no next (or previous) page, which is yielded with Complete set.
+// If the context is done before a page is fetched, its error is yielded and the walk ends.
+// req can start the walk from its after cursor (or before if walking backward), but not bound it on the other side,
+// i.e. before with a forward walk or after with a backward one, which is rejected since the pages beyond the bound can't be told.
+func (p *Paginator[T]) All(ctx context.Context, req *PaginateRequest[T], opts ...WalkOption) iter.Seq2[*WalkPage[T], error] {
+	o := &walkOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return func(yield func(*WalkPage[T], error) bool) {
+		backward := req.Last != nil
+		if !backward && req.Before != nil || backward && req.After != nil {
+			yield(nil, errors.New("All can't be bounded by before when walking forward, or by after when walking backward"))
+			return
+		}
+		r := *req
+		for pages := 0; o.maxPages <= 0 || pages < o.maxPages; pages++ {
+			if err := ctx.Err(); err != nil {
+				yield(nil, errors.WithStack(err))
+				return
+			}
+
+			resp, err := p.Paginate(ctx, &r)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+
+			hasMore, next := resp.PageInfo.HasNextPage, resp.PageInfo.EndCursor
+			if backward {
+				hasMore, next = resp.PageInfo.HasPreviousPage, resp.PageInfo.StartCursor
+			}
+			if hasMore && next == nil {
+				yield(nil, errors.New("cursor of the subsequent page is not available, All can't be used with WithCompactPageInfo"))
+				return
+			}
+
+			if !yield(&WalkPage[T]{PaginateResponse: resp, Complete: !hasMore}, nil) || !hasMore {
+				return
+			}
+
+			if backward {
+				r.Before = next
+			} else {
+				r.After = next
+			}
+		}
+	}
+}
+
+// Stream walks the pages like All with pageSize as first (or last if req.Last is set), and sends the edges to out in the walking order,
+// i.e. reversed within each page if walking backward. Sending blocks until out is received from, so a slow consumer holds back the fetching.
+// out is closed when Stream returns, after all edges are sent, or on the first error, including the error of ctx.
+func (p *Paginator[T]) Stream(ctx context.Context, req *PaginateRequest[T], pageSize int, out chan<- Edge[T]) error {
+	defer close(out)
+
+	r := *req
+	backward := req.Last != nil
+	if backward {
+		r.First, r.Last = nil, &pageSize
+	} else {
+		r.First, r.Last = &pageSize, nil
+	}
+
+	for page, err := range p.All(ctx, &r) {
+		if err != nil {
+			return err
+		}
+		edges := page.Edges
+		if page.Nodes != nil {
+			edges = make([]Edge[T], len(page.Nodes))
+			for i, node := range page.Nodes {
+				edges[i] = Edge[T]{Node: node}
+			}
+		}
+		for i := range edges {
+			edge := edges[i]
+			if backward {
+				edge = edges[len(edges)-1-i]
+			}
+			select {
+			case out <- edge:
+			case <-ctx.Done():
+				return errors.WithStack(ctx.Err())
+			}
+		}
+	}
+	return nil
+}
+
+// EachBatch walks the pages like All with the max limit as first (or last if req.Last is set), and calls fn with the nodes of each page
+// in the walking order, i.e. reversed if walking backward, so only a page is held in memory at once, e.g. for exports.
+// It stops at the first error of fn, the pagination or ctx.
+func (p *Paginator[T]) EachBatch(ctx context.Context, req *PaginateRequest[T], fn func(nodes []T) error) error {
+	r := *req
+	backward := req.Last != nil
+	if backward {
+		r.First, r.Last = nil, &p.backwardMaxLimit
+	} else {
+		r.First, r.Last = &p.forwardMaxLimit, nil
+	}
+
+	for page, err := range p.All(ctx, &r) {
+		if err != nil {
+			return err
+		}
+		nodes := page.Nodes
+		if nodes == nil {
+			nodes = make([]T, len(page.Edges))
+			for i, edge := range page.Edges {
+				nodes[i] = edge.Node
+			}
+		}
+		if backward {
+			slices.Reverse(nodes)
+		}
+		if len(nodes) == 0 {
+			continue
+		}
+		if err := fn(nodes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
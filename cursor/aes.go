@@ -64,19 +64,42 @@ func decryptAES(cipherText string, key []byte) (string, error) {
 }
 
 func WrapAES[T any](next relay.ApplyCursorsFunc[T], encryptionKey []byte) relay.ApplyCursorsFunc[T] {
+	return WrapAESKeyring(next, encryptionKey)
+}
+
+// decryptAESKeyring tries the keys in order, the error of the first key is returned if all of them fail
+func decryptAESKeyring(cipherText string, keys [][]byte) (string, error) {
+	var firstErr error
+	for _, key := range keys {
+		plainText, err := decryptAES(cipherText, key)
+		if err == nil {
+			return plainText, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", firstErr
+}
+
+// WrapAESKeyring is WrapAES with key rotation, cursors are encrypted with primaryKey,
+// and decrypted with primaryKey or else decryptionKeys in order, e.g. the previous keys,
+// so the cursors issued before the rotation keep working until the previous keys are dropped.
+func WrapAESKeyring[T any](next relay.ApplyCursorsFunc[T], primaryKey []byte, decryptionKeys ...[]byte) relay.ApplyCursorsFunc[T] {
+	keys := append([][]byte{primaryKey}, decryptionKeys...)
 	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
 		if req.After != nil {
-			decodedCursor, err := decryptAES(*req.After, encryptionKey)
+			decodedCursor, err := decryptAESKeyring(*req.After, keys)
 			if err != nil {
-				return nil, errors.Wrap(err, "invalid after cursor")
+				return nil, invalidCursor(errors.Wrap(err, "invalid after cursor"))
 			}
 			req.After = lo.ToPtr(decodedCursor)
 		}
 
 		if req.Before != nil {
-			decodedCursor, err := decryptAES(*req.Before, encryptionKey)
+			decodedCursor, err := decryptAESKeyring(*req.Before, keys)
 			if err != nil {
-				return nil, errors.Wrap(err, "invalid before cursor")
+				return nil, invalidCursor(errors.Wrap(err, "invalid before cursor"))
 			}
 			req.Before = lo.ToPtr(decodedCursor)
 		}
@@ -94,7 +117,7 @@ func WrapAES[T any](next relay.ApplyCursorsFunc[T], encryptionKey []byte) relay.
 				if err != nil {
 					return "", err
 				}
-				encryptedCursor, err := encryptAES(cursor, encryptionKey)
+				encryptedCursor, err := encryptAES(cursor, primaryKey)
 				if err != nil {
 					return "", err
 				}
@@ -0,0 +1,41 @@
+package cursor
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const arrayConnectionPrefix = "arrayconnection:"
+
+// RelayArrayConnectionParser is an OffsetParser of the `base64("arrayconnection:<offset>")` cursors,
+// which graphql-relay-js issues for the connections from arrays.
+type RelayArrayConnectionParser struct{}
+
+var _ OffsetParser = RelayArrayConnectionParser{}
+
+func (RelayArrayConnectionParser) Encode(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(arrayConnectionPrefix + strconv.Itoa(offset)))
+}
+
+func (RelayArrayConnectionParser) Decode(cursor string) (int, error) {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errors.Wrapf(err, "decode arrayconnection cursor %q", cursor)
+	}
+	s, ok := strings.CutPrefix(string(b), arrayConnectionPrefix)
+	if !ok {
+		return 0, errors.Errorf("decode arrayconnection cursor %q: missing %q prefix", cursor, arrayConnectionPrefix)
+	}
+	offset, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, errors.Wrapf(err, "decode arrayconnection cursor %q", cursor)
+	}
+	if strconv.Itoa(offset) != s {
+		// e.g. `+1` or `01`, which would be another cursor of the same offset
+		return 0, errors.Errorf("decode arrayconnection cursor %q: non-canonical offset %q", cursor, s)
+	}
+	return offset, nil
+}
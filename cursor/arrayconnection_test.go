@@ -0,0 +1,78 @@
+package cursor
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayArrayConnectionParser(t *testing.T) {
+	parser := RelayArrayConnectionParser{}
+
+	// the same as offsetToCursor of graphql-relay-js
+	require.Equal(t, "YXJyYXljb25uZWN0aW9uOjA=", parser.Encode(0))
+	require.Equal(t, "YXJyYXljb25uZWN0aW9uOjQy", parser.Encode(42))
+
+	for _, offset := range []int{0, 1, 9, 10, 99, 12345} {
+		decoded, err := parser.Decode(parser.Encode(offset))
+		require.NoError(t, err)
+		require.Equal(t, offset, decoded)
+	}
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	for _, cursor := range []string{
+		"",
+		"0",
+		"not base64!",
+		encode("0"),
+		encode("arrayconnection:"),
+		encode("arrayconnection:abc"),
+		encode("arrayconnection:1.5"),
+		encode("arrayconnection:+1"),
+		encode("arrayconnection:01"),
+		encode("arrayconnection: 1"),
+		encode("ArrayConnection:1"),
+		encode("arrayconnection:1:2"),
+	} {
+		_, err := parser.Decode(cursor)
+		require.ErrorContains(t, err, "decode arrayconnection cursor", cursor)
+	}
+}
+
+func TestOffsetAdapterWithRelayArrayConnectionParser(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	finder := OffsetFinderFunc[string](func(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]string, error) {
+		return items[min(skip, len(items)):min(skip+limit, len(items))], nil
+	})
+	f := NewOffsetAdapter(finder, WithOffsetParser(RelayArrayConnectionParser{}))
+	parser := RelayArrayConnectionParser{}
+
+	resp, err := f(context.Background(), &relay.ApplyCursorsRequest{
+		After: lo.ToPtr(parser.Encode(1)),
+		Limit: 2,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 2)
+	require.Equal(t, "c", resp.Edges[0].Node)
+	cursor, err := resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+	require.NoError(t, err)
+	require.Equal(t, parser.Encode(2), cursor)
+
+	// plain offset cursors are rejected
+	_, err = f(context.Background(), &relay.ApplyCursorsRequest{
+		After: lo.ToPtr(EncodeOffsetCursor(1)),
+		Limit: 2,
+	})
+	require.ErrorContains(t, err, "decode arrayconnection cursor")
+
+	// negative offsets are rejected as plain ones are
+	_, err = f(context.Background(), &relay.ApplyCursorsRequest{
+		Before: lo.ToPtr(parser.Encode(-1)),
+		Limit:  2,
+	})
+	require.ErrorContains(t, err, "before < 0")
+}
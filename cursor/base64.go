@@ -3,26 +3,35 @@ package cursor
 import (
 	"context"
 	"encoding/base64"
+	"net/url"
+	"strings"
 
 	relay "github.com/molon/gorelay"
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 )
 
-func WrapBase64[T any](next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T] {
+// WrapBase64 encodes the cursors of next with the standard base64 encoding.
+// With WithTolerantBase64, the cursors mangled by clients are still decoded.
+func WrapBase64[T any](next relay.ApplyCursorsFunc[T], opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	decode := base64.StdEncoding.DecodeString
+	if o.tolerantBase64 {
+		decode = decodeBase64Tolerantly
+	}
 	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
 		if req.After != nil {
-			cursor, err := base64.StdEncoding.DecodeString(*req.After)
+			cursor, err := decode(*req.After)
 			if err != nil {
-				return nil, errors.Wrap(err, "invalid after cursor")
+				return nil, invalidCursor(errors.Wrap(err, "invalid after cursor"))
 			}
 			req.After = lo.ToPtr(string(cursor))
 		}
 
 		if req.Before != nil {
-			cursor, err := base64.StdEncoding.DecodeString(*req.Before)
+			cursor, err := decode(*req.Before)
 			if err != nil {
-				return nil, errors.Wrap(err, "invalid before cursor")
+				return nil, invalidCursor(errors.Wrap(err, "invalid before cursor"))
 			}
 			req.Before = lo.ToPtr(string(cursor))
 		}
@@ -48,3 +57,29 @@ func WrapBase64[T any](next relay.ApplyCursorsFunc[T]) relay.ApplyCursorsFunc[T]
 		return resp, nil
 	}
 }
+
+// decodeBase64Tolerantly decodes the standard base64 encoding, padded or not, or the URL-safe one, e.g. `+` as `-` and `/` as `_`,
+// and `+` turned into a space by form decoding. If it fails, it tries once more after URL-decoding s, which clients encode twice.
+// The error is the one of the standard encoding.
+func decodeBase64Tolerantly(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err == nil {
+		return b, nil
+	}
+	if b, ok := decodeBase64Variant(s); ok {
+		return b, nil
+	}
+	if unescaped, uerr := url.PathUnescape(s); uerr == nil && unescaped != s {
+		if b, ok := decodeBase64Variant(unescaped); ok {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+var base64VariantReplacer = strings.NewReplacer("-", "+", "_", "/", " ", "+")
+
+func decodeBase64Variant(s string) ([]byte, bool) {
+	b, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(base64VariantReplacer.Replace(s), "="))
+	return b, err == nil
+}
@@ -0,0 +1,67 @@
+package cursor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapBase64Tolerant(t *testing.T) {
+	const raw = `{"Name":"x???~>"}`
+
+	var received *string
+	next := func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[string], error) {
+		received = req.After
+		return &relay.ApplyCursorsResponse[string]{
+			Edges: []relay.LazyEdge[string]{{
+				Node:   "node",
+				Cursor: func(ctx context.Context, node string) (string, error) { return raw, nil },
+			}},
+		}, nil
+	}
+	strict := WrapBase64(next)
+	tolerant := WrapBase64(next, WithTolerantBase64())
+
+	resp, err := tolerant(context.Background(), &relay.ApplyCursorsRequest{Limit: 1})
+	require.NoError(t, err)
+	cursor, err := resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+	require.NoError(t, err)
+	// issued as standard base64, with all the characters clients mangle
+	require.Equal(t, "eyJOYW1lIjoieD8/P34+In0=", cursor)
+
+	urlSafe := strings.NewReplacer("+", "-", "/", "_").Replace(cursor)
+	for name, mangled := range map[string]string{
+		"Issued":                cursor,
+		"Unpadded":              strings.TrimRight(cursor, "="),
+		"URLSafe":               urlSafe,
+		"URLSafeUnpadded":       strings.TrimRight(urlSafe, "="),
+		"PlusAsSpace":           strings.ReplaceAll(cursor, "+", " "),
+		"URLEncoded":            url.QueryEscape(cursor),
+		"URLEncodedUnpadded":    url.QueryEscape(strings.TrimRight(cursor, "=")),
+		"URLEncodedPlusAsSpace": url.PathEscape(strings.ReplaceAll(cursor, "+", " ")),
+	} {
+		t.Run(name, func(t *testing.T) {
+			received = nil
+			_, err := tolerant(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(mangled), Limit: 1})
+			require.NoError(t, err)
+			require.Equal(t, raw, *received)
+
+			if mangled != cursor {
+				_, err = strict(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(mangled), Limit: 1})
+				require.ErrorIs(t, err, ErrInvalidCursor)
+			}
+		})
+	}
+
+	// garbage is still invalid, and URL-decoded once only
+	for _, invalid := range []string{"!!!", "eyJOYW1lIjoieD8%", url.QueryEscape(url.QueryEscape(cursor))} {
+		_, err := tolerant(context.Background(), &relay.ApplyCursorsRequest{Before: lo.ToPtr(invalid), Limit: 1})
+		require.ErrorIs(t, err, ErrInvalidCursor, invalid)
+		require.ErrorContains(t, err, "invalid before cursor")
+	}
+}
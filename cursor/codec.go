@@ -0,0 +1,153 @@
+package cursor
+
+import (
+	"context"
+	"encoding/base64"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// Codec converts the cursors of the wrapped adapter into a format of clients and back
+type Codec interface {
+	Encode(cursor string) (string, error)
+	Decode(cursor string) (string, error)
+}
+
+// IdentityCodec leaves cursors as they are, e.g. the JSON of keyset cursors
+type IdentityCodec struct{}
+
+func (IdentityCodec) Encode(cursor string) (string, error) { return cursor, nil }
+
+func (IdentityCodec) Decode(cursor string) (string, error) { return cursor, nil }
+
+// Base64Codec encodes cursors with the standard base64 encoding, the same as WrapBase64
+type Base64Codec struct{}
+
+func (Base64Codec) Encode(cursor string) (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte(cursor)), nil
+}
+
+func (Base64Codec) Decode(cursor string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", errors.Wrap(err, "decode base64 cursor")
+	}
+	return string(b), nil
+}
+
+// CodecRegistry registers Codecs by short names along with one-byte ids, the id prefixes the cursors encoded by the codec,
+// so that a server can serve multiple cursor formats simultaneously and decode any of them by the right codec.
+type CodecRegistry struct {
+	ids         map[string]byte
+	codecs      map[byte]Codec
+	defaultName string
+}
+
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{ids: map[string]byte{}, codecs: map[byte]Codec{}}
+}
+
+// Register registers codec by name, the first registered one is the default.
+// id must be a printable ASCII character (e.g. 'j'), so that the cursors are still text, and it must not be changed once cursors are issued.
+func (r *CodecRegistry) Register(id byte, name string, codec Codec) *CodecRegistry {
+	if id <= ' ' || id > '~' {
+		panic("codec id must be a printable ASCII character")
+	}
+	if name == "" {
+		panic("codec name must be set")
+	}
+	if _, ok := r.codecs[id]; ok {
+		panic("codec id " + string(id) + " is already registered")
+	}
+	if _, ok := r.ids[name]; ok {
+		panic("codec name " + name + " is already registered")
+	}
+	r.ids[name] = id
+	r.codecs[id] = codec
+	if r.defaultName == "" {
+		r.defaultName = name
+	}
+	return r
+}
+
+// Encode encodes cursor with the codec of name, or the default one if name is empty, and prefixes it with the id of the codec
+func (r *CodecRegistry) Encode(name, cursor string) (string, error) {
+	if name == "" {
+		name = r.defaultName
+	}
+	id, ok := r.ids[name]
+	if !ok {
+		return "", errors.Errorf("codec %q is not registered", name)
+	}
+	encoded, err := r.codecs[id].Encode(cursor)
+	if err != nil {
+		return "", err
+	}
+	return string(id) + encoded, nil
+}
+
+// Decode decodes cursor with the codec of the id it is prefixed with
+func (r *CodecRegistry) Decode(cursor string) (string, error) {
+	if cursor == "" {
+		return "", errors.New("missing codec id")
+	}
+	codec, ok := r.codecs[cursor[0]]
+	if !ok {
+		return "", errors.Errorf("unknown codec id %q", cursor[0])
+	}
+	return codec.Decode(cursor[1:])
+}
+
+// WrapCodecs encodes cursors with the codec named by choose, e.g. negotiated with the client, or the default one if choose is nil or returns "",
+// and decodes cursors with the codecs they were encoded with.
+func WrapCodecs[T any](next relay.ApplyCursorsFunc[T], registry *CodecRegistry, choose func(ctx context.Context) string) relay.ApplyCursorsFunc[T] {
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		var name string
+		if choose != nil {
+			name = choose(ctx)
+		}
+		if name == "" {
+			name = registry.defaultName
+		}
+		if _, ok := registry.ids[name]; !ok {
+			return nil, errors.Errorf("codec %q is not registered", name)
+		}
+
+		if req.After != nil {
+			cursor, err := registry.Decode(*req.After)
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid after cursor"))
+			}
+			req.After = lo.ToPtr(cursor)
+		}
+
+		if req.Before != nil {
+			cursor, err := registry.Decode(*req.Before)
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid before cursor"))
+			}
+			req.Before = lo.ToPtr(cursor)
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range resp.Edges {
+			edge := &resp.Edges[i]
+			originalCursor := edge.Cursor
+			edge.Cursor = func(ctx context.Context, node T) (string, error) {
+				cursor, err := originalCursor(ctx, node)
+				if err != nil {
+					return "", err
+				}
+				return registry.Encode(name, cursor)
+			}
+		}
+
+		return resp, nil
+	}
+}
@@ -0,0 +1,86 @@
+package cursor
+
+import (
+	"context"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodecRegistry(t *testing.T) {
+	registry := NewCodecRegistry().
+		Register('j', "json", IdentityCodec{}).
+		Register('b', "base64", Base64Codec{})
+
+	jsonCursor, err := registry.Encode("json", `{"ID":1}`)
+	require.NoError(t, err)
+	require.Equal(t, `j{"ID":1}`, jsonCursor)
+	base64Cursor, err := registry.Encode("base64", `{"ID":1}`)
+	require.NoError(t, err)
+	require.Equal(t, `beyJJRCI6MX0=`, base64Cursor)
+	defaultCursor, err := registry.Encode("", `{"ID":1}`)
+	require.NoError(t, err)
+	require.Equal(t, jsonCursor, defaultCursor)
+
+	for _, c := range []string{jsonCursor, base64Cursor} {
+		decoded, err := registry.Decode(c)
+		require.NoError(t, err)
+		require.Equal(t, `{"ID":1}`, decoded)
+	}
+
+	_, err = registry.Encode("msgpack", `{"ID":1}`)
+	require.ErrorContains(t, err, `codec "msgpack" is not registered`)
+	_, err = registry.Decode(`x{"ID":1}`)
+	require.ErrorContains(t, err, `unknown codec id 'x'`)
+	_, err = registry.Decode(`b{"ID":1}`)
+	require.ErrorContains(t, err, "decode base64 cursor")
+
+	require.PanicsWithValue(t, "codec id j is already registered", func() { registry.Register('j', "other", IdentityCodec{}) })
+	require.PanicsWithValue(t, "codec name json is already registered", func() { registry.Register('o', "json", IdentityCodec{}) })
+	require.PanicsWithValue(t, "codec id must be a printable ASCII character", func() { registry.Register(0, "other", IdentityCodec{}) })
+}
+
+func TestWrapCodecs(t *testing.T) {
+	var nodes []*sliceNode
+	for i := 1; i <= 10; i++ {
+		nodes = append(nodes, &sliceNode{ID: i})
+	}
+	registry := NewCodecRegistry().
+		Register('j', "json", IdentityCodec{}).
+		Register('b', "base64", Base64Codec{})
+
+	type formatKey struct{}
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}},
+		WrapCodecs(NewKeysetAdapter[*sliceNode](NewSliceKeysetFinder(nodes)), registry, func(ctx context.Context) string {
+			format, _ := ctx.Value(formatKey{}).(string)
+			return format
+		}),
+	)
+
+	// cursors issued to two clients in different formats
+	cursors := map[string]string{}
+	for _, format := range []string{"json", "base64"} {
+		resp, err := p.Paginate(context.WithValue(context.Background(), formatKey{}, format), &relay.PaginateRequest[*sliceNode]{First: lo.ToPtr(3)})
+		require.NoError(t, err)
+		cursors[format] = *resp.PageInfo.EndCursor
+	}
+	require.Equal(t, `j{"ID":3}`, cursors["json"])
+	require.Equal(t, `beyJJRCI6M30=`, cursors["base64"])
+
+	// both are decoded regardless of the format of the request
+	for _, c := range cursors {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*sliceNode]{After: lo.ToPtr(c), First: lo.ToPtr(2)})
+		require.NoError(t, err)
+		require.Equal(t, []int{4, 5}, lo.Map(resp.Edges, func(edge relay.Edge[*sliceNode], _ int) int { return edge.Node.ID }))
+		require.Equal(t, `j{"ID":5}`, *resp.PageInfo.EndCursor)
+	}
+
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*sliceNode]{Before: lo.ToPtr(`{"ID":3}`), Last: lo.ToPtr(2)})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	require.ErrorContains(t, err, "invalid before cursor")
+
+	_, err = p.Paginate(context.WithValue(context.Background(), formatKey{}, "msgpack"), &relay.PaginateRequest[*sliceNode]{First: lo.ToPtr(2)})
+	require.ErrorContains(t, err, `codec "msgpack" is not registered`)
+}
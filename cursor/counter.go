@@ -1,7 +1,106 @@
 package cursor
 
-import "context"
+import (
+	"context"
+	"time"
+
+	relay "github.com/molon/gorelay"
+)
 
 type Counter interface {
 	Count(ctx context.Context) (int, error)
 }
+
+// ApproximateCounter is implemented by counters which may estimate the total count.
+type ApproximateCounter interface {
+	Counter
+	ApproximateCount(ctx context.Context) (totalCount int, approximate bool, err error)
+}
+
+// CountSkipper is implemented by counters which may deliberately skip counting, e.g. under high load.
+type CountSkipper interface {
+	SkipCount(ctx context.Context) bool
+}
+
+// KeysetWindowFinder is implemented by keyset finders which fetch the total count with the page in the same query,
+// e.g. by `COUNT(*) OVER ()`, the adapter uses it instead of counting separately.
+// FindWithCount returns a negative count if no row carries it, e.g. the page is beyond the end, the adapter then counts separately.
+type KeysetWindowFinder[T any] interface {
+	FindWithCount(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, int, error)
+}
+
+// KeysetExistChecker is implemented by keyset finders which check whether rows exist up to a keyset,
+// the adapter uses it under WithExactCursorExistence to tell whether the after cursor has a previous row and the before cursor a next one.
+type KeysetExistChecker interface {
+	// ExistsUpTo reports whether any row is at or before keyset by orderBys, or at or after it if reverse is true
+	ExistsUpTo(ctx context.Context, keyset map[string]any, orderBys []relay.OrderBy, reverse bool) (bool, error)
+}
+
+// KeysetConverter is implemented by keyset finders which convert the values of decoded keysets before comparing with them,
+// the adapter passes the converted keysets to the hook of WithKeysetDebugHook, so that it receives the exact boundaries of the queries.
+type KeysetConverter interface {
+	// ConvertKeyset returns a copy of keyset with the values converted as compared with, e.g. time strings to time.Time
+	ConvertKeyset(ctx context.Context, keyset map[string]any) (map[string]any, error)
+}
+
+// OffsetWindowFinder is implemented by offset finders which fetch the total count with the page in the same query,
+// e.g. by `COUNT(*) OVER ()`, the adapter uses it instead of counting separately unless the count is needed to locate the page.
+// FindWithCount returns a negative count if no row carries it, as KeysetWindowFinder does.
+type OffsetWindowFinder[T any] interface {
+	FindWithCount(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, int, error)
+}
+
+type countResult struct {
+	counted     bool
+	totalCount  int
+	approximate bool
+	skipped     bool
+}
+
+// countTotal counts with the finder if it implements Counter.
+// If required is true, e.g. to locate the page, the count is exact and never skipped, neither by the request nor by CountSkipper.
+func countTotal(ctx context.Context, finder any, req *relay.ApplyCursorsRequest, required bool) (*countResult, error) {
+	counter, ok := finder.(Counter)
+	if !ok {
+		return &countResult{}, nil
+	}
+	if !required && skipsCount(ctx, finder, req) {
+		return &countResult{skipped: true}, nil
+	}
+
+	r := &countResult{counted: true}
+	start := time.Now()
+	var err error
+	if c, ok := counter.(ApproximateCounter); ok && !required {
+		r.totalCount, r.approximate, err = c.ApproximateCount(ctx)
+	} else {
+		r.totalCount, err = counter.Count(ctx)
+	}
+	if err != nil {
+		req.Hooks.ReportError(ctx, relay.StageCount, err)
+		return nil, err
+	}
+	req.Hooks.ReportCount(ctx, time.Since(start), r.totalCount)
+	return r, nil
+}
+
+// skipsCount reports whether the count is skipped by the request or by CountSkipper
+func skipsCount(ctx context.Context, finder any, req *relay.ApplyCursorsRequest) bool {
+	if req.SkipTotalCount {
+		return true
+	}
+	skipper, ok := finder.(CountSkipper)
+	return ok && skipper.SkipCount(ctx)
+}
+
+// countFromLastPage is used if all rows are already fetched (e.g. the first page comes back short),
+// so the total count is known without counting.
+func countFromLastPage(finder any, req *relay.ApplyCursorsRequest, n int) *countResult {
+	if _, ok := finder.(Counter); !ok {
+		return &countResult{}
+	}
+	if req.SkipTotalCount {
+		return &countResult{skipped: true}
+	}
+	return &countResult{counted: true, totalCount: n}
+}
@@ -0,0 +1,75 @@
+package cursor
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// ErrCursorExpired is returned if a cursor is older than the TTL of WrapExpiry
+var ErrCursorExpired = errors.New("cursor expired")
+
+// WrapExpiry embeds the issued-at timestamp into cursors and rejects cursors older than ttl with ErrCursorExpired.
+// The timestamp is visible and can be tampered with by clients, so it should be wrapped by WrapAES, e.g.
+// WrapAES(WrapExpiry(next, ttl, nil), encryptionKey). If now is nil, time.Now is used.
+func WrapExpiry[T any](next relay.ApplyCursorsFunc[T], ttl time.Duration, now func() time.Time) relay.ApplyCursorsFunc[T] {
+	if now == nil {
+		now = time.Now
+	}
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		if req.After != nil {
+			cursor, err := checkExpiry(*req.After, ttl, now())
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid after cursor"))
+			}
+			req.After = lo.ToPtr(cursor)
+		}
+
+		if req.Before != nil {
+			cursor, err := checkExpiry(*req.Before, ttl, now())
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid before cursor"))
+			}
+			req.Before = lo.ToPtr(cursor)
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range resp.Edges {
+			edge := &resp.Edges[i]
+			originalCursor := edge.Cursor
+			edge.Cursor = func(ctx context.Context, node T) (string, error) {
+				cursor, err := originalCursor(ctx, node)
+				if err != nil {
+					return "", err
+				}
+				return strconv.FormatInt(now().UnixMilli(), 10) + ":" + cursor, nil
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+func checkExpiry(cursor string, ttl time.Duration, now time.Time) (string, error) {
+	issuedAt, cursor, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return "", errors.New("missing issued-at timestamp")
+	}
+	ms, err := strconv.ParseInt(issuedAt, 10, 64)
+	if err != nil {
+		return "", errors.Wrap(err, "parse issued-at timestamp")
+	}
+	if now.Sub(time.UnixMilli(ms)) > ttl {
+		return "", errors.WithStack(ErrCursorExpired)
+	}
+	return cursor, nil
+}
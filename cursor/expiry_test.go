@@ -0,0 +1,78 @@
+package cursor
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapExpiry(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+
+	var received *string
+	next := func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[string], error) {
+		received = req.After
+		return &relay.ApplyCursorsResponse[string]{
+			Edges: []relay.LazyEdge[string]{
+				{
+					Node: "node",
+					Cursor: func(ctx context.Context, node string) (string, error) {
+						return `{"ID":1}`, nil
+					},
+				},
+			},
+		}, nil
+	}
+
+	testCase := func(t *testing.T, f relay.ApplyCursorsFunc[string]) {
+		resp, err := f(context.Background(), &relay.ApplyCursorsRequest{Limit: 1})
+		require.NoError(t, err)
+		cursor, err := resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+		require.NoError(t, err)
+
+		clock = clock.Add(time.Minute)
+		_, err = f(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(cursor), Limit: 1})
+		require.NoError(t, err)
+		require.Equal(t, `{"ID":1}`, *received)
+
+		// exactly at the TTL
+		clock = clock.Add(4 * time.Minute)
+		_, err = f(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(cursor), Limit: 1})
+		require.NoError(t, err)
+
+		clock = clock.Add(time.Millisecond)
+		_, err = f(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(cursor), Limit: 1})
+		require.ErrorIs(t, err, ErrCursorExpired)
+		_, err = f(context.Background(), &relay.ApplyCursorsRequest{Before: lo.ToPtr(cursor), Limit: 1})
+		require.ErrorIs(t, err, ErrCursorExpired)
+		require.ErrorIs(t, err, ErrInvalidCursor)
+		require.ErrorContains(t, err, "invalid before cursor")
+	}
+
+	t.Run("Plain", func(t *testing.T) {
+		f := WrapExpiry(next, 5*time.Minute, now)
+		testCase(t, f)
+
+		_, err := f(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(`{"ID":1}`), Limit: 1})
+		require.ErrorContains(t, err, "parse issued-at timestamp")
+	})
+
+	t.Run("AES", func(t *testing.T) {
+		key := []byte(strings.Repeat("k", 32))
+		f := WrapAES(WrapExpiry(next, 5*time.Minute, now), key)
+		testCase(t, f)
+
+		// the timestamp is not visible to clients
+		resp, err := f(context.Background(), &relay.ApplyCursorsRequest{Limit: 1})
+		require.NoError(t, err)
+		cursor, err := resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+		require.NoError(t, err)
+		require.NotContains(t, cursor, ":")
+	})
+}
@@ -0,0 +1,36 @@
+package cursor
+
+import (
+	"context"
+	"time"
+
+	relay "github.com/molon/gorelay"
+)
+
+// hookFind calls find and reports it to the hooks of req
+func hookFind[T any](ctx context.Context, req *relay.ApplyCursorsRequest, find func() ([]T, error)) ([]T, error) {
+	start := time.Now()
+	nodes, err := find()
+	if err != nil {
+		req.Hooks.ReportError(ctx, relay.StageFind, err)
+		return nil, err
+	}
+	req.Hooks.ReportFind(ctx, time.Since(start), len(nodes))
+	return nodes, nil
+}
+
+// hookFindWithCount is hookFind for the window finders, both the find and the count are reported with the duration of the one query
+func hookFindWithCount[T any](ctx context.Context, req *relay.ApplyCursorsRequest, find func() ([]T, int, error)) ([]T, int, error) {
+	start := time.Now()
+	nodes, totalCount, err := find()
+	if err != nil {
+		req.Hooks.ReportError(ctx, relay.StageFind, err)
+		return nil, 0, err
+	}
+	dur := time.Since(start)
+	req.Hooks.ReportFind(ctx, dur, len(nodes))
+	if totalCount >= 0 {
+		req.Hooks.ReportCount(ctx, dur, totalCount)
+	}
+	return nodes, totalCount, nil
+}
@@ -1,7 +1,13 @@
 package cursor
 
 import (
+	"cmp"
 	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	relay "github.com/molon/gorelay"
@@ -19,60 +25,244 @@ func (f KeysetFinderFunc[T]) Find(ctx context.Context, after, before *map[string
 	return f(ctx, after, before, orderBys, limit, fromLast)
 }
 
-func NewKeysetAdapter[T any](finder KeysetFinder[T]) relay.ApplyCursorsFunc[T] {
+func NewKeysetAdapter[T any](finder KeysetFinder[T], opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	encoders := &keysetEncoders[T]{opts: opts}
 	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
 		keys := lo.Map(req.OrderBys, func(item relay.OrderBy, _ int) string {
 			return item.Field
 		})
 
-		after, before, err := decodeKeysetCursors[T](req.After, req.Before, keys)
+		afterCursor, beforeCursor := req.After, req.Before
+		var fingerprint string
+		if o.orderFingerprint {
+			fingerprint = orderFingerprint(req.OrderBys)
+			var err error
+			if afterCursor, err = stripOrderFingerprint(afterCursor, fingerprint, o.orderMigrations); err != nil {
+				err = errors.Wrap(err, "invalid after cursor")
+				req.Hooks.ReportError(ctx, relay.StageDecodeCursor, err)
+				return nil, err
+			}
+			if beforeCursor, err = stripOrderFingerprint(beforeCursor, fingerprint, o.orderMigrations); err != nil {
+				err = errors.Wrap(err, "invalid before cursor")
+				req.Hooks.ReportError(ctx, relay.StageDecodeCursor, err)
+				return nil, err
+			}
+		}
+
+		after, before, err := decodeKeysetCursors[T](afterCursor, beforeCursor, keys, o)
 		if err != nil {
+			req.Hooks.ReportError(ctx, relay.StageDecodeCursor, err)
 			return nil, err
 		}
+		if o.keysetDebugHook != nil {
+			if err := debugKeysets(ctx, finder, after, before, o); err != nil {
+				req.Hooks.ReportError(ctx, relay.StageDecodeCursor, err)
+				return nil, err
+			}
+		}
 
-		var totalCount int
-		counter, ok := finder.(Counter)
-		if ok {
-			var err error
-			totalCount, err = counter.Count(ctx)
+		// Only possible under WithEmptyRangeOnEqualCursors
+		emptyRange := afterCursor != nil && beforeCursor != nil && *afterCursor == *beforeCursor
+
+		if o.checkConsistency && after != nil && before != nil && !emptyRange {
+			if c, ok := compareKeysets(*after, *before, req.OrderBys, o.compareStrings); ok && c >= 0 {
+				return nil, invalidCursor(errors.Wrap(ErrInconsistentCursor, "after cursor must precede before cursor"))
+			}
+		}
+
+		var nodes []T
+		var counted *countResult
+		fetched := false
+		if windowFinder, ok := finder.(KeysetWindowFinder[T]); ok && req.Limit > 0 && !emptyRange && !skipsCount(ctx, finder, req) {
+			// The page and the total count are fetched by one query
+			var totalCount int
+			nodes, totalCount, err = hookFindWithCount(ctx, req, func() ([]T, int, error) {
+				return windowFinder.FindWithCount(ctx, after, before, req.OrderBys, req.Limit, req.FromLast)
+			})
 			if err != nil {
 				return nil, err
 			}
+			fetched = true
+			if totalCount >= 0 {
+				counted = &countResult{counted: true, totalCount: totalCount}
+			} else if counted, err = countTotal(ctx, finder, req, false); err != nil {
+				return nil, err
+			}
+		}
+		if !fetched && after == nil && before == nil && req.Limit > 0 {
+			// Fetch the first page before counting, if it comes back short it is also the last page
+			nodes, err = hookFind(ctx, req, func() ([]T, error) {
+				return finder.Find(ctx, after, before, req.OrderBys, req.Limit, req.FromLast)
+			})
+			if err != nil {
+				return nil, err
+			}
+			fetched = true
 		}
 
+		switch {
+		case counted != nil:
+		case fetched && len(nodes) < req.Limit:
+			counted = countFromLastPage(finder, req, len(nodes))
+		default:
+			counted, err = countTotal(ctx, finder, req, false)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		encoder, err := encoders.get(keys)
+		if err != nil {
+			return nil, err
+		}
 		cursorEncoder := func(_ context.Context, node T) (string, error) {
-			return EncodeKeysetCursor(node, keys)
+			cursor, err := encoder.Encode(node)
+			if err != nil || fingerprint == "" {
+				return cursor, err
+			}
+			return fingerprint + ":" + cursor, nil
+		}
+
+		sortValue := func(_ context.Context, node T) (any, error) {
+			if len(keys) == 0 {
+				return nil, nil
+			}
+			keyset, err := encoder.Keyset(node)
+			if err != nil {
+				return nil, err
+			}
+			return keyset[keys[0]], nil
 		}
 
 		var edges []relay.LazyEdge[T]
-		if req.Limit <= 0 || (counter != nil && totalCount <= 0) {
+		if !fetched && (req.Limit <= 0 || emptyRange || (counted.counted && counted.totalCount <= 0)) {
 			edges = make([]relay.LazyEdge[T], 0)
 		} else {
-			nodes, err := finder.Find(ctx, after, before, req.OrderBys, req.Limit, req.FromLast)
-			if err != nil {
-				return nil, err
+			if !fetched {
+				nodes, err = hookFind(ctx, req, func() ([]T, error) {
+					return finder.Find(ctx, after, before, req.OrderBys, req.Limit, req.FromLast)
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+			if o.checkKeysets {
+				if err := checkDistinctKeysets(encoder, nodes, keys); err != nil {
+					return nil, err
+				}
 			}
 			edges = make([]relay.LazyEdge[T], len(nodes))
 			for i, node := range nodes {
 				edges[i] = relay.LazyEdge[T]{
-					Node:   node,
-					Cursor: cursorEncoder,
+					Node:      node,
+					Cursor:    cursorEncoder,
+					SortValue: sortValue,
+				}
+			}
+		}
+
+		// It costs a query per cursor to check whether after and before really exist,
+		// So it is usually not worth it. Normally, checking that it is not nil is sufficient.
+		hasAfterOrPrevious, hasBeforeOrNext := after != nil, before != nil
+		if checker, ok := finder.(KeysetExistChecker); ok && o.exactExistence && !emptyRange {
+			if after != nil {
+				if hasAfterOrPrevious, err = checker.ExistsUpTo(ctx, *after, req.OrderBys, false); err != nil {
+					req.Hooks.ReportError(ctx, relay.StageFind, err)
+					return nil, err
+				}
+			}
+			if before != nil {
+				if hasBeforeOrNext, err = checker.ExistsUpTo(ctx, *before, req.OrderBys, true); err != nil {
+					req.Hooks.ReportError(ctx, relay.StageFind, err)
+					return nil, err
 				}
 			}
 		}
 
 		resp := &relay.ApplyCursorsResponse[T]{
-			Edges:      edges,
-			TotalCount: totalCount,
-			// If we don't have a counter, it would be very costly to check whether after and before really exist,
-			// So it is usually not worth it. Normally, checking that it is not nil is sufficient.
-			HasAfterOrPrevious: after != nil,
-			HasBeforeOrNext:    before != nil,
+			Edges:                 edges,
+			TotalCount:            counted.totalCount,
+			TotalCountApproximate: counted.approximate,
+			TotalCountSkipped:     counted.skipped,
+			HasAfterOrPrevious:    hasAfterOrPrevious,
+			HasBeforeOrNext:       hasBeforeOrNext,
 		}
 		return resp, nil
 	}
 }
 
+// ErrInconsistentCursor is returned under WithConsistencyCheck if the keyset cursors contradict the order bys
+var ErrInconsistentCursor = errors.New("inconsistent cursor")
+
+// ErrAmbiguousKeyset is returned under WithKeysetIntegrityCheck if rows of a page have the same keyset
+var ErrAmbiguousKeyset = errors.New("ambiguous keyset")
+
+// checkDistinctKeysets checks that consecutive nodes have different keysets, nodes ordered by the keys have the same keysets adjacent
+func checkDistinctKeysets[T any](encoder *KeysetEncoder[T], nodes []T, keys []string) error {
+	var prev string
+	for i, node := range nodes {
+		cursor, err := encoder.Encode(node)
+		if err != nil {
+			return err
+		}
+		if i > 0 && cursor == prev {
+			return errors.Wrapf(ErrAmbiguousKeyset, "rows %d and %d of the page have the same values of the order by fields %v, add a unique tiebreak, e.g. the primary key", i-1, i, keys)
+		}
+		prev = cursor
+	}
+	return nil
+}
+
+// ErrInvalidCursor is relay.ErrInvalidCursor, which errors.Is matches the errors of the cursors from clients with, e.g. malformed or expired ones
+var ErrInvalidCursor = relay.ErrInvalidCursor
+
+// invalidCursor marks err with ErrInvalidCursor, keeping its message
+func invalidCursor(err error) error {
+	return relay.MarkError(err, ErrInvalidCursor)
+}
+
+// compareKeysets compares keysets decoded from cursors by orderBys, it reports false if any value can't be compared
+func compareKeysets(a, b map[string]any, orderBys []relay.OrderBy, compareStrings func(a, b string) int) (int, bool) {
+	for _, orderBy := range orderBys {
+		c, ok := compareDecodedValues(a[orderBy.Field], b[orderBy.Field], compareStrings)
+		if !ok {
+			return 0, false
+		}
+		if c != 0 {
+			if orderBy.Desc {
+				return -c, true
+			}
+			return c, true
+		}
+	}
+	return 0, true
+}
+
+// compareDecodedValues compares numbers and bools by value, RFC 3339 strings as times and other strings by compareStrings
+func compareDecodedValues(a, b any, compareStrings func(a, b string) int) (int, bool) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return cmp.Compare(av, bv), true
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			at, aErr := time.Parse(time.RFC3339Nano, av)
+			bt, bErr := time.Parse(time.RFC3339Nano, bv)
+			if aErr == nil && bErr == nil {
+				return at.Compare(bt), true
+			}
+			return compareStrings(av, bv), true
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			return cmp.Compare(lo.Ternary(av, 1, 0), lo.Ternary(bv, 1, 0)), true
+		}
+	}
+	return 0, false
+}
+
 const KeysetTagKey = "relay"
 
 // use strcut field name as key and force emit empty
@@ -84,7 +274,14 @@ var jsoniterForKeyset = jsoniter.Config{
 }.Froze()
 
 func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
-	b, err := jsoniterForKeyset.Marshal(node)
+	// Marshal through a pointer, so that T and *T produce the same cursor even with pointer receiver marshalers
+	var v any = node
+	if rv := reflect.ValueOf(node); rv.IsValid() && rv.Kind() != reflect.Ptr {
+		ptr := reflect.New(rv.Type())
+		ptr.Elem().Set(rv)
+		v = ptr.Interface()
+	}
+	b, err := jsoniterForKeyset.Marshal(v)
 	if err != nil {
 		return "", errors.Wrap(err, "marshal cursor")
 	}
@@ -98,9 +295,14 @@ func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
 		return key, true
 	})
 	for k := range keysMap {
-		if _, ok := m[k]; !ok {
+		if _, ok := m[k]; ok {
+			continue
+		}
+		v, ok := nestedValue(m, k)
+		if !ok {
 			return "", errors.Errorf("key %q not found in node", k)
 		}
+		m[k] = v
 	}
 	for k := range m {
 		if _, ok := keysMap[k]; !ok {
@@ -115,14 +317,74 @@ func EncodeKeysetCursor[T any](node T, keys []string) (string, error) {
 	return string(b), nil
 }
 
-func DecodeKeysetCursor[T any](cursor string, keys []string) (map[string]any, error) {
-	var m map[string]any
-	if err := jsoniterForKeyset.Unmarshal([]byte(cursor), &m); err != nil {
-		return nil, errors.Wrap(err, "unmarshal cursor")
+// nestedValue looks up a dotted key through the nested objects of m, e.g. `Customer.Name`, a null object on the way makes it null
+func nestedValue(m map[string]any, key string) (any, bool) {
+	names := strings.Split(key, ".")
+	if len(names) < 2 {
+		return nil, false
+	}
+	var v any = m
+	for _, name := range names {
+		if v == nil {
+			return nil, true
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func DecodeKeysetCursor[T any](cursor string, keys []string, opts ...Option) (map[string]any, error) {
+	return decodeKeysetCursor[T](cursor, keys, newOptions(opts))
+}
+
+func decodeKeysetCursor[T any](cursor string, keys []string, o *options) (map[string]any, error) {
+	var cursorKeys map[string]string
+	if o.cursorTagKey != "" {
+		var err error
+		cursorKeys, err = cursorKeysOf(reflect.TypeOf((*T)(nil)).Elem(), keys, o.cursorTagKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	m, err := decodeKeysetCursorUnmarked(cursor, keys, cursorKeys, o)
+	if err != nil {
+		return nil, invalidCursor(err)
+	}
+	return m, nil
+}
+
+func decodeKeysetCursorUnmarked(cursor string, keys []string, cursorKeys map[string]string, o *options) (map[string]any, error) {
+	unmarshal := unmarshalKeyset
+	if o.sparseEncoding && strings.HasPrefix(cursor, "[") {
+		unmarshal = func(cursor string, maxKeys int) (map[string]any, error) {
+			return unmarshalSparseKeyset(cursor, keys, maxKeys)
+		}
+		cursorKeys = nil // the values are assigned to the keys by their positions
+	}
+	m, err := unmarshal(cursor, o.maxKeysetKeys)
+	if err != nil {
+		return nil, o.redactDecodeError(err)
 	}
 	if len(m) != len(keys) {
 		return nil, errors.New("cursor length != keys length")
 	}
+	if cursorKeys != nil {
+		keyset := make(map[string]any, len(keys))
+		for _, key := range keys {
+			v, ok := m[cursorKeys[key]]
+			if !ok {
+				return nil, errors.Errorf("key %q not found in cursor", cursorKeys[key])
+			}
+			keyset[key] = v
+		}
+		return keyset, nil
+	}
 	for _, key := range keys {
 		if _, ok := m[key]; !ok {
 			return nil, errors.Errorf("key %q not found in cursor", key)
@@ -131,19 +393,19 @@ func DecodeKeysetCursor[T any](cursor string, keys []string) (map[string]any, er
 	return m, nil
 }
 
-func decodeKeysetCursors[T any](after, before *string, keys []string) (afterKeyset, beforeKeyset *map[string]any, err error) {
-	if after != nil && before != nil && *after == *before {
-		return nil, nil, errors.New("after == before")
+func decodeKeysetCursors[T any](after, before *string, keys []string, o *options) (afterKeyset, beforeKeyset *map[string]any, err error) {
+	if after != nil && before != nil && *after == *before && !o.emptyRangeOnEqual {
+		return nil, nil, invalidCursor(errors.New("after == before"))
 	}
 	if after != nil {
-		m, err := DecodeKeysetCursor[T](*after, keys)
+		m, err := decodeKeysetCursor[T](*after, keys, o)
 		if err != nil {
 			return nil, nil, err
 		}
 		afterKeyset = &m
 	}
 	if before != nil {
-		m, err := DecodeKeysetCursor[T](*before, keys)
+		m, err := decodeKeysetCursor[T](*before, keys, o)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -151,3 +413,75 @@ func decodeKeysetCursors[T any](after, before *string, keys []string) (afterKeys
 	}
 	return afterKeyset, beforeKeyset, nil
 }
+
+// unmarshalKeyset is like unmarshaling the cursor into a map, but stops once it has more than maxKeys keys
+func unmarshalKeyset(cursor string, maxKeys int) (map[string]any, error) {
+	iter := jsoniterForKeyset.BorrowIterator([]byte(cursor))
+	defer jsoniterForKeyset.ReturnIterator(iter)
+
+	var m map[string]any
+	if iter.WhatIsNext() == jsoniter.NilValue {
+		iter.Skip()
+	} else {
+		m = map[string]any{}
+		n := 0 // duplicated keys count as well
+		iter.ReadMapCB(func(iter *jsoniter.Iterator, key string) bool {
+			n++
+			if n > maxKeys {
+				iter.ReportError("ReadMapCB", fmt.Sprintf("too many keys, max is %d", maxKeys))
+				return false
+			}
+			m[key] = iter.Read()
+			return true
+		})
+	}
+	if iter.Error == nil && iter.WhatIsNext() != jsoniter.InvalidValue {
+		iter.ReportError("Unmarshal", "there are bytes left after unmarshal")
+	}
+	if iter.Error != nil && iter.Error != io.EOF {
+		return nil, errors.Wrap(iter.Error, "unmarshal cursor")
+	}
+	return m, nil
+}
+
+// unmarshalSparseKeyset unmarshals a cursor of WithSparseEncoding into the keyset of keys, and stops once it has more than maxKeys values.
+// It returns ErrOrderMismatch if the cursor was encoded for other keys.
+func unmarshalSparseKeyset(cursor string, keys []string, maxKeys int) (map[string]any, error) {
+	iter := jsoniterForKeyset.BorrowIterator([]byte(cursor))
+	defer jsoniterForKeyset.ReturnIterator(iter)
+
+	var fingerprint any
+	var values []any
+	read := false
+	iter.ReadArrayCB(func(iter *jsoniter.Iterator) bool {
+		if !read {
+			fingerprint, read = iter.Read(), true
+			return true
+		}
+		if len(values) >= maxKeys {
+			iter.ReportError("ReadArrayCB", fmt.Sprintf("too many keys, max is %d", maxKeys))
+			return false
+		}
+		values = append(values, iter.Read())
+		return true
+	})
+	if iter.Error == nil && iter.WhatIsNext() != jsoniter.InvalidValue {
+		iter.ReportError("Unmarshal", "there are bytes left after unmarshal")
+	}
+	if iter.Error != nil && iter.Error != io.EOF {
+		return nil, errors.Wrap(iter.Error, "unmarshal cursor")
+	}
+	if fp, ok := fingerprint.(string); !ok || !isFingerprint(fp) {
+		return nil, errors.New("missing order fingerprint")
+	} else if fp != keysFingerprint(keys) {
+		return nil, errors.WithStack(ErrOrderMismatch)
+	}
+	if len(values) != len(keys) {
+		return nil, errors.New("cursor length != keys length")
+	}
+	m := make(map[string]any, len(keys))
+	for i, key := range keys {
+		m[key] = values[i]
+	}
+	return m, nil
+}
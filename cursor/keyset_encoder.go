@@ -0,0 +1,480 @@
+package cursor
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// KeysetEncoder encodes keyset cursors with field accessors resolved once per node type,
+// instead of marshaling the whole node for every cursor like EncodeKeysetCursor does.
+type KeysetEncoder[T any] struct {
+	keys        []string
+	normalizers map[string]func(v any) (any, error)
+	sparse      bool
+	cursorKeys  map[string]string // key -> key in cursors, nil if they are the same
+	accessor    *keysetAccessor   // nil if T is an interface type
+	cache       sync.Map          // reflect.Type -> *keysetAccessor, used if T is an interface type
+}
+
+// NewKeysetEncoder creates a KeysetEncoder for keys.
+// If T is not an interface type, it returns an error if any key does not map to a field of T.
+func NewKeysetEncoder[T any](keys []string, opts ...Option) (*KeysetEncoder[T], error) {
+	o := newOptions(opts)
+	e := &KeysetEncoder[T]{keys: keys, normalizers: o.keysetNormalizers, sparse: o.sparseEncoding}
+	tType := reflect.TypeOf((*T)(nil)).Elem()
+	if tType.Kind() != reflect.Interface {
+		accessor, err := newKeysetAccessor(tType, keys)
+		if err != nil {
+			return nil, err
+		}
+		e.accessor = accessor
+	}
+	if o.cursorTagKey != "" {
+		cursorKeys, err := cursorKeysOf(tType, keys, o.cursorTagKey)
+		if err != nil {
+			return nil, err
+		}
+		e.cursorKeys = cursorKeys
+	}
+	return e, nil
+}
+
+func (e *KeysetEncoder[T]) Encode(node T) (string, error) {
+	accessor, err := e.accessorOf(node)
+	if err != nil {
+		return "", err
+	}
+	if accessor.marshalNode && len(e.normalizers) == 0 && !e.sparse && e.cursorKeys == nil {
+		return EncodeKeysetCursor(node, e.keys)
+	}
+	var m map[string]any
+	if accessor.marshalNode {
+		m, err = e.keysetOfMarshaledNode(node)
+	} else {
+		m, err = accessor.keyset(reflect.ValueOf(node), true)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := e.normalize(m); err != nil {
+		return "", err
+	}
+	if !accessor.marshalNode {
+		if err := accessor.canonicalize(m); err != nil {
+			return "", err
+		}
+	}
+	var v any = m
+	if e.sparse {
+		v = append([]any{keysFingerprint(e.keys)}, lo.Map(e.keys, func(key string, _ int) any { return m[key] })...)
+	} else if e.cursorKeys != nil {
+		v = lo.MapKeys(m, func(_ any, key string) string { return e.cursorKeys[key] })
+	}
+	b, err := jsoniterForKeyset.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal cursor")
+	}
+	return string(b), nil
+}
+
+// Keyset returns the values of the keys of the node.
+func (e *KeysetEncoder[T]) Keyset(node T) (map[string]any, error) {
+	accessor, err := e.accessorOf(node)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if accessor.marshalNode {
+		m, err = e.keysetOfMarshaledNode(node)
+	} else {
+		m, err = accessor.keyset(reflect.ValueOf(node), false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := e.normalize(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// keysetOfMarshaledNode returns the keyset of a node which marshals itself, it is only known from its cursor
+func (e *KeysetEncoder[T]) keysetOfMarshaledNode(node T) (map[string]any, error) {
+	cursor, err := EncodeKeysetCursor(node, e.keys)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeKeysetCursor[T](cursor, e.keys)
+}
+
+func (e *KeysetEncoder[T]) normalize(m map[string]any) error {
+	for key, normalize := range e.normalizers {
+		v, ok := m[key]
+		if !ok {
+			continue
+		}
+		normalized, err := normalize(v)
+		if err != nil {
+			return errors.Wrapf(err, "normalize key %q", key)
+		}
+		m[key] = normalized
+	}
+	return nil
+}
+
+func (e *KeysetEncoder[T]) accessorOf(node T) (*keysetAccessor, error) {
+	if e.accessor != nil {
+		return e.accessor, nil
+	}
+	rv := reflect.ValueOf(node)
+	if !rv.IsValid() {
+		return nil, errors.New("node is nil")
+	}
+	if v, ok := e.cache.Load(rv.Type()); ok {
+		return v.(*keysetAccessor), nil
+	}
+	accessor, err := newKeysetAccessor(rv.Type(), e.keys)
+	if err != nil {
+		return nil, err
+	}
+	e.cache.Store(rv.Type(), accessor)
+	return accessor, nil
+}
+
+// keysetEncoders caches the KeysetEncoder of each keys of an adapter, so that the fields are resolved once rather than per request
+type keysetEncoders[T any] struct {
+	opts     []Option
+	encoders sync.Map // keys joined by "\x00" -> *KeysetEncoder[T]
+}
+
+func (c *keysetEncoders[T]) get(keys []string) (*KeysetEncoder[T], error) {
+	id := strings.Join(keys, "\x00")
+	if e, ok := c.encoders.Load(id); ok {
+		return e.(*KeysetEncoder[T]), nil
+	}
+	e, err := NewKeysetEncoder[T](keys, c.opts...)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := c.encoders.LoadOrStore(id, e)
+	return actual.(*KeysetEncoder[T]), nil
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func implementsMarshaler(typ reflect.Type) bool {
+	return typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType)
+}
+
+type keysetAccessor struct {
+	keys    []string
+	indexes [][]int
+	// marshalNode is true if the node marshals itself or is a map, then the fields can't be used
+	marshalNode bool
+	// addrs reports whether the field only marshals itself through a pointer receiver
+	addrs []bool
+	// composites reports whether the field may be marshaled as a JSON object, whose keys follow the field order of structs
+	composites []bool
+	// nested is the index of each level of a dotted key, e.g. `Customer.Name` of a joined relation, nil for the others
+	nested [][][]int
+}
+
+func newKeysetAccessor(typ reflect.Type, keys []string) (*keysetAccessor, error) {
+	structType := typ
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if structType.Kind() == reflect.Map {
+		// e.g. map[string]any, the keys are only known from the marshaled node as EncodeKeysetCursor does
+		return &keysetAccessor{keys: keys, marshalNode: true}, nil
+	}
+	if structType.Kind() != reflect.Struct {
+		return nil, errors.Errorf("node type %s is not a struct or struct pointer", typ)
+	}
+
+	a := &keysetAccessor{
+		keys:       keys,
+		indexes:    make([][]int, len(keys)),
+		addrs:      make([]bool, len(keys)),
+		composites: make([]bool, len(keys)),
+		nested:     make([][][]int, len(keys)),
+	}
+	// EncodeKeysetCursor always marshals through a pointer
+	if implementsMarshaler(reflect.PointerTo(structType)) {
+		a.marshalNode = true
+		return a, nil
+	}
+
+	fields := keysetFields(structType)
+	for i, key := range keys {
+		var ft reflect.Type
+		if index := fields[key]; index != nil {
+			a.indexes[i] = index
+			ft = structType.FieldByIndex(index).Type
+		} else if path, typ, ok := nestedField(structType, key); ok {
+			a.nested[i] = path
+			ft = typ
+		} else {
+			return nil, errors.Errorf("key %q not found in node", key)
+		}
+		a.addrs[i] = !implementsMarshaler(ft) && implementsMarshaler(reflect.PointerTo(ft))
+		a.composites[i] = isComposite(ft)
+	}
+	return a, nil
+}
+
+// isComposite reports whether values of typ may be marshaled as JSON objects or arrays, text marshalers are always strings
+func isComposite(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Implements(textMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType) {
+		return false
+	}
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// canonicalize round-trips the composite values of m through JSON as EncodeKeysetCursor does,
+// so that the keys of nested objects are sorted, and cursors don't change if the fields of a struct are reordered.
+func (a *keysetAccessor) canonicalize(m map[string]any) error {
+	for i, key := range a.keys {
+		if !a.composites[i] {
+			continue
+		}
+		b, err := jsoniterForKeyset.Marshal(m[key])
+		if err != nil {
+			return errors.Wrap(err, "marshal cursor")
+		}
+		var v any
+		if err := jsoniterForKeyset.Unmarshal(b, &v); err != nil {
+			return errors.Wrap(err, "unmarshal cursor")
+		}
+		m[key] = v
+	}
+	return nil
+}
+
+// keyset returns the values of the keys, if forMarshal is true,
+// the fields with pointer receiver marshalers are returned as pointers to be marshaled as EncodeKeysetCursor does.
+func (a *keysetAccessor) keyset(rv reflect.Value, forMarshal bool) (map[string]any, error) {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, errors.New("node is nil")
+		}
+		rv = rv.Elem()
+	}
+	if forMarshal && !rv.CanAddr() && lo.Contains(a.addrs, true) {
+		addressable := reflect.New(rv.Type()).Elem()
+		addressable.Set(rv)
+		rv = addressable
+	}
+	m := make(map[string]any, len(a.keys))
+	for i, key := range a.keys {
+		var fv reflect.Value
+		var ok bool
+		if a.nested[i] != nil {
+			fv, ok = nestedFieldByIndex(rv, a.nested[i])
+		} else {
+			fv, ok = fieldByIndex(rv, a.indexes[i])
+		}
+		if !ok {
+			return nil, errors.Errorf("key %q not found in node", key)
+		}
+		if !fv.IsValid() {
+			// under a nil struct pointer, e.g. a relation without a joined row
+			m[key] = nil
+			continue
+		}
+		if forMarshal && a.addrs[i] && fv.CanAddr() {
+			m[key] = fv.Addr().Interface()
+		} else {
+			m[key] = fv.Interface()
+		}
+	}
+	return m, nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports false if it meets a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// nestedField resolves a dotted key through the fields of nested structs, e.g. `Customer.Name`
+func nestedField(typ reflect.Type, key string) ([][]int, reflect.Type, bool) {
+	names := strings.Split(key, ".")
+	if len(names) < 2 {
+		return nil, nil, false
+	}
+	path := make([][]int, 0, len(names))
+	for i, name := range names {
+		if i > 0 {
+			for typ.Kind() == reflect.Ptr {
+				typ = typ.Elem()
+			}
+			if typ.Kind() != reflect.Struct {
+				return nil, nil, false
+			}
+		}
+		index := keysetFields(typ)[name]
+		if index == nil {
+			return nil, nil, false
+		}
+		path = append(path, index)
+		typ = typ.FieldByIndex(index).Type
+	}
+	return path, typ, true
+}
+
+// nestedFieldByIndex returns the field of a path of nestedField, which is the invalid value if it meets a nil struct pointer on the way
+func nestedFieldByIndex(v reflect.Value, path [][]int) (reflect.Value, bool) {
+	for i, index := range path {
+		if i > 0 {
+			for v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}, true
+				}
+				v = v.Elem()
+			}
+		}
+		var ok bool
+		if v, ok = fieldByIndex(v, index); !ok {
+			return reflect.Value{}, false
+		}
+	}
+	return v, true
+}
+
+// keysetFields resolves the cursor keys of a struct type the same way as jsoniterForKeyset does,
+// struct field names (or names from KeysetTagKey) and fields of embedded structs are promoted.
+func keysetFields(typ reflect.Type) map[string][]int {
+	type candidate struct {
+		index []int
+		count int
+	}
+	result := map[string][]int{}
+	visited := map[reflect.Type]bool{}
+	current := []candidate{{index: nil}}
+	currentTypes := []reflect.Type{typ}
+	for len(current) > 0 {
+		found := map[string]*candidate{}
+		var next []candidate
+		var nextTypes []reflect.Type
+		for i, c := range current {
+			t := currentTypes[i]
+			if visited[t] {
+				continue
+			}
+			visited[t] = true
+			for j := 0; j < t.NumField(); j++ {
+				sf := t.Field(j)
+				tag := sf.Tag.Get(KeysetTagKey)
+				if tag == "-" {
+					continue
+				}
+				name, _, _ := strings.Cut(tag, ",")
+				index := make([]int, len(c.index)+1)
+				copy(index, c.index)
+				index[len(c.index)] = j
+
+				ft := sf.Type
+				if ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+				if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+					next = append(next, candidate{index: index})
+					nextTypes = append(nextTypes, ft)
+					continue
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+				if _, ok := result[name]; ok {
+					continue // shallower field wins
+				}
+				if f, ok := found[name]; ok {
+					f.count++
+					continue
+				}
+				found[name] = &candidate{index: index, count: 1}
+			}
+		}
+		for name, f := range found {
+			if f.count == 1 {
+				result[name] = f.index
+			} else {
+				result[name] = nil // ambiguous fields at the same depth are omitted
+			}
+		}
+		current, currentTypes = next, nextTypes
+	}
+	return result
+}
+
+// cursorKeysOf names keys by the tag tagKey of their fields of typ, each level of a nested key by its own field,
+// a field without the tag or tagged with `-` keeps its name.
+func cursorKeysOf(typ reflect.Type, keys []string, tagKey string) (map[string]string, error) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil, errors.Errorf("node type %s is not a struct or struct pointer, which is required by the cursor tag key", typ)
+	}
+	cursorKeys := make(map[string]string, len(keys))
+	keysByCursorKey := make(map[string]string, len(keys))
+	for _, key := range keys {
+		names := strings.Split(key, ".")
+		if _, ok := keysetFields(typ)[key]; ok {
+			names = []string{key} // a field whose relay tag has dots
+		}
+		t := typ
+		for i, name := range names {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			var index []int
+			if t.Kind() == reflect.Struct {
+				index = keysetFields(t)[name]
+			}
+			if index == nil {
+				return nil, errors.Errorf("key %q not found in node", key)
+			}
+			sf := t.FieldByIndex(index)
+			if tag, _, _ := strings.Cut(sf.Tag.Get(tagKey), ","); tag != "" && tag != "-" {
+				names[i] = tag
+			}
+			t = sf.Type
+		}
+		cursorKey := strings.Join(names, ".")
+		if other, ok := keysByCursorKey[cursorKey]; ok {
+			return nil, errors.Errorf("keys %q and %q have the same cursor key %q", other, key, cursorKey)
+		}
+		keysByCursorKey[cursorKey] = key
+		cursorKeys[key] = cursorKey
+	}
+	return cursorKeys, nil
+}
@@ -1,9 +1,15 @@
 package cursor
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
@@ -85,3 +91,608 @@ func TestEncodeKeysetCursor(t *testing.T) {
 	require.ErrorContains(t, err, `func() is unsupported type`)
 	require.Empty(t, cursor)
 }
+
+func TestDecodeKeysetCursor(t *testing.T) {
+	type User struct {
+		ID   int
+		Name string
+	}
+	{
+		m, err := DecodeKeysetCursor[*User](`{"ID":1,"Name":"molon"}`, []string{"ID", "Name"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"ID": float64(1), "Name": "molon"}, m)
+	}
+	{
+		_, err := DecodeKeysetCursor[*User](`{"ID":1}`, []string{"ID", "Name"})
+		require.ErrorContains(t, err, "cursor length != keys length")
+	}
+	{
+		_, err := DecodeKeysetCursor[*User](`{"ID":1} {}`, []string{"ID"})
+		require.ErrorContains(t, err, "unmarshal cursor")
+	}
+
+	excessive := func(n int, key func(i int) string) string {
+		var sb strings.Builder
+		sb.WriteString("{")
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				sb.WriteString(",")
+			}
+			fmt.Fprintf(&sb, "%q:%d", key(i), i)
+		}
+		sb.WriteString("}")
+		return sb.String()
+	}
+	{
+		_, err := DecodeKeysetCursor[*User](excessive(10000, func(i int) string { return fmt.Sprintf("k%d", i) }), []string{"ID"})
+		require.ErrorContains(t, err, "too many keys, max is 32")
+	}
+	{
+		// duplicated keys are counted as well
+		_, err := DecodeKeysetCursor[*User](excessive(10000, func(int) string { return "ID" }), []string{"ID"})
+		require.ErrorContains(t, err, "too many keys, max is 32")
+	}
+	{
+		cursor := excessive(3, func(i int) string { return fmt.Sprintf("k%d", i) })
+		_, err := DecodeKeysetCursor[*User](cursor, []string{"k0", "k1", "k2"}, WithMaxKeysetKeys(2))
+		require.ErrorContains(t, err, "too many keys, max is 2")
+		m, err := DecodeKeysetCursor[*User](cursor, []string{"k0", "k1", "k2"}, WithMaxKeysetKeys(3))
+		require.NoError(t, err)
+		require.Len(t, m, 3)
+
+		require.PanicsWithValue(t, "max keyset keys must be greater than 0", func() { WithMaxKeysetKeys(0) })
+		require.PanicsWithValue(t, "max keyset keys must be greater than 0", func() { WithMaxKeysetKeys(-1) })
+	}
+}
+
+type cents int
+
+func (c *cents) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`"%d.%02d"`, int(*c)/100, int(*c)%100)), nil
+}
+
+type selfMarshaledUser struct {
+	ID int
+}
+
+func (u *selfMarshaledUser) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"ID":%d,"Marshaled":true}`, u.ID)), nil
+}
+
+func TestKeysetCursorPointerValue(t *testing.T) {
+	type Base struct {
+		Tenant string
+	}
+	type User struct {
+		gorm.Model
+		*Base
+		Name  string
+		Price cents // marshaled with a pointer receiver
+		Ptr   *int
+	}
+
+	testCase := func(t *testing.T, value, ptr any, keys []string, expected string) {
+		cursor, err := EncodeKeysetCursor(value, keys)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+		cursor, err = EncodeKeysetCursor(ptr, keys)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+
+		encoder, err := NewKeysetEncoder[any](keys)
+		require.NoError(t, err)
+		cursor, err = encoder.Encode(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+		cursor, err = encoder.Encode(ptr)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+	}
+
+	user := User{Model: gorm.Model{ID: 5}, Name: "molon", Price: 1250}
+	testCase(t, user, &user, []string{"ID"}, `{"ID":5}`)
+	testCase(t, user, &user, []string{"ID", "Name", "Ptr"}, `{"ID":5,"Name":"molon","Ptr":null}`)
+	testCase(t, user, &user, []string{"ID", "Price"}, `{"ID":5,"Price":"12.50"}`)
+	user.Base = &Base{Tenant: "t1"}
+	testCase(t, user, &user, []string{"ID", "Tenant"}, `{"ID":5,"Tenant":"t1"}`)
+
+	node := selfMarshaledUser{ID: 5}
+	testCase(t, node, &node, []string{"ID", "Marshaled"}, `{"ID":5,"Marshaled":true}`)
+
+	// generic value and pointer types
+	{
+		encoder, err := NewKeysetEncoder[User]([]string{"ID", "Price"})
+		require.NoError(t, err)
+		cursor, err := encoder.Encode(user)
+		require.NoError(t, err)
+		require.Equal(t, `{"ID":5,"Price":"12.50"}`, cursor)
+
+		ptrEncoder, err := NewKeysetEncoder[*User]([]string{"ID", "Price"})
+		require.NoError(t, err)
+		cursor, err = ptrEncoder.Encode(&user)
+		require.NoError(t, err)
+		require.Equal(t, `{"ID":5,"Price":"12.50"}`, cursor)
+
+		// Keyset keeps the raw values
+		keyset, err := encoder.Keyset(user)
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"ID": uint(5), "Price": cents(1250)}, keyset)
+	}
+}
+
+func TestKeysetEncoder(t *testing.T) {
+	type User struct {
+		gorm.Model
+		Name           string `json:"name,omitempty"`
+		Description    string `json:"description,omitempty"`
+		IgnoredByJson  string `json:"-"`
+		IgnoredByRelay func() `json:"-" relay:"-"`
+		age            int
+	}
+	user := User{
+		Model: gorm.Model{ID: 1},
+		Name:  "molon",
+	}
+
+	for _, keys := range [][]string{
+		{"ID"},
+		{"ID", "Description"},
+		{"ID", "Name", "Description"},
+		{"Name", "Description", "IgnoredByJson"},
+		{"ID", "CreatedAt", "DeletedAt"},
+	} {
+		expected, err := EncodeKeysetCursor(user, keys)
+		require.NoError(t, err)
+
+		encoder, err := NewKeysetEncoder[User](keys)
+		require.NoError(t, err)
+		cursor, err := encoder.Encode(user)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+
+		ptrEncoder, err := NewKeysetEncoder[*User](keys)
+		require.NoError(t, err)
+		cursor, err = ptrEncoder.Encode(&user)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+
+		anyEncoder, err := NewKeysetEncoder[any](keys)
+		require.NoError(t, err)
+		cursor, err = anyEncoder.Encode(&user)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+	}
+
+	{
+		encoder, err := NewKeysetEncoder[*User]([]string{"Name", "FieldNotExists"})
+		require.ErrorContains(t, err, `key "FieldNotExists" not found in node`)
+		require.Nil(t, encoder)
+
+		encoder, err = NewKeysetEncoder[*User]([]string{"age"})
+		require.ErrorContains(t, err, `key "age" not found in node`)
+		require.Nil(t, encoder)
+
+		encoder, err = NewKeysetEncoder[*User]([]string{"IgnoredByRelay"})
+		require.ErrorContains(t, err, `key "IgnoredByRelay" not found in node`)
+		require.Nil(t, encoder)
+	}
+
+	{
+		// T is an interface type, so the keys can only be validated when encoding
+		encoder, err := NewKeysetEncoder[any]([]string{"FieldNotExists"})
+		require.NoError(t, err)
+		cursor, err := encoder.Encode(&user)
+		require.ErrorContains(t, err, `key "FieldNotExists" not found in node`)
+		require.Empty(t, cursor)
+	}
+
+	{
+		// maps are encoded by their marshaled keys as EncodeKeysetCursor does
+		node := map[string]any{"ID": 1, "Name": "molon", "Customer": map[string]any{"Name": "bob"}}
+		keys := []string{"Name", "Customer.Name", "ID"}
+		expected, err := EncodeKeysetCursor(node, keys)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"Customer.Name":"bob","ID":1,"Name":"molon"}`, expected)
+
+		encoder, err := NewKeysetEncoder[map[string]any](keys)
+		require.NoError(t, err)
+		cursor, err := encoder.Encode(node)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor)
+		keyset, err := encoder.Keyset(node)
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"Customer.Name": "bob", "ID": float64(1), "Name": "molon"}, keyset)
+
+		encoder, err = NewKeysetEncoder[map[string]any]([]string{"FieldNotExists"})
+		require.NoError(t, err)
+		_, err = encoder.Encode(node)
+		require.ErrorContains(t, err, `key "FieldNotExists" not found in node`)
+
+		// through the adapter
+		adapter := NewKeysetAdapter(KeysetFinderFunc[map[string]any](func(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]map[string]any, error) {
+			return []map[string]any{node}, nil
+		}))
+		for _, orderBys := range [][]relay.OrderBy{{{Field: "ID"}}, {{Field: "Name"}, {Field: "ID"}}, {{Field: "ID"}}} {
+			resp, err := adapter(context.Background(), &relay.ApplyCursorsRequest{OrderBys: orderBys, Limit: 10})
+			require.NoError(t, err)
+			cursor, err := resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+			require.NoError(t, err)
+			expected, err := EncodeKeysetCursor(node, lo.Map(orderBys, func(orderBy relay.OrderBy, _ int) string { return orderBy.Field }))
+			require.NoError(t, err)
+			require.Equal(t, expected, cursor)
+		}
+	}
+}
+
+func BenchmarkKeysetEncoder(b *testing.B) {
+	type User struct {
+		gorm.Model
+		Name        string
+		Description string
+		Age         int
+	}
+	user := &User{
+		Model:       gorm.Model{ID: 1},
+		Name:        "molon",
+		Description: "description",
+		Age:         18,
+	}
+	keys := []string{"ID", "Name", "Age"}
+
+	b.Run("EncodeKeysetCursor", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := EncodeKeysetCursor(user, keys); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("KeysetEncoder", func(b *testing.B) {
+		encoder, err := NewKeysetEncoder[*User](keys)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := encoder.Encode(user); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestKeysetNormalizer(t *testing.T) {
+	type Event struct {
+		ID         int
+		HappenedAt time.Time
+	}
+	shanghai := time.FixedZone("Asia/Shanghai", 8*3600)
+	normalizer := WithKeysetNormalizer("HappenedAt", func(v any) (any, error) {
+		return v.(time.Time).UTC(), nil
+	})
+
+	encoder, err := NewKeysetEncoder[*Event]([]string{"HappenedAt", "ID"}, normalizer)
+	require.NoError(t, err)
+	event := &Event{ID: 1, HappenedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, shanghai)}
+
+	cursor, err := encoder.Encode(event)
+	require.NoError(t, err)
+	require.Equal(t, `{"HappenedAt":"2024-01-01T19:04:05Z","ID":1}`, cursor)
+
+	keyset, err := encoder.Keyset(event)
+	require.NoError(t, err)
+	require.Equal(t, time.UTC, keyset["HappenedAt"].(time.Time).Location())
+
+	// nodes which marshal themselves are normalized by their JSON values
+	selfEncoder, err := NewKeysetEncoder[*selfMarshaledUser]([]string{"ID"}, WithKeysetNormalizer("ID", func(v any) (any, error) {
+		return v.(float64) * 10, nil
+	}))
+	require.NoError(t, err)
+	cursor, err = selfEncoder.Encode(&selfMarshaledUser{ID: 3})
+	require.NoError(t, err)
+	require.Equal(t, `{"ID":30}`, cursor)
+}
+
+func TestCompareKeysets(t *testing.T) {
+	orderBys := []relay.OrderBy{
+		{Field: "CreatedAt", Desc: false},
+		{Field: "Name", Desc: true},
+	}
+	keyset := func(createdAt, name any) map[string]any {
+		return map[string]any{"CreatedAt": createdAt, "Name": name}
+	}
+
+	// times are compared as times even with different offsets
+	c, ok := compareKeysets(keyset("2024-01-01T10:00:00+08:00", "a"), keyset("2024-01-01T03:00:00Z", "a"), orderBys, strings.Compare)
+	require.True(t, ok)
+	require.Equal(t, -1, c)
+
+	c, ok = compareKeysets(keyset("2024-01-01T10:00:00+08:00", "a"), keyset("2024-01-01T02:00:00Z", "b"), orderBys, strings.Compare)
+	require.True(t, ok)
+	require.Equal(t, 1, c)
+
+	c, ok = compareKeysets(keyset(1.0, true), keyset(1.0, false), orderBys, strings.Compare)
+	require.True(t, ok)
+	require.Equal(t, -1, c)
+
+	_, ok = compareKeysets(keyset(1.0, "a"), keyset("1", "a"), orderBys, strings.Compare)
+	require.False(t, ok)
+
+	// strings are compared bytewise unless collated
+	c, ok = compareKeysets(keyset(1.0, "B"), keyset(1.0, "a"), orderBys, strings.Compare)
+	require.True(t, ok)
+	require.Equal(t, 1, c)
+	c, ok = compareKeysets(keyset(1.0, "B"), keyset(1.0, "a"), orderBys, func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	})
+	require.True(t, ok)
+	require.Equal(t, -1, c)
+}
+
+func TestSparseEncoding(t *testing.T) {
+	type User struct {
+		gorm.Model
+		Name        string
+		Description string
+	}
+	user := User{
+		Model: gorm.Model{ID: 1, CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)},
+		Name:  "molon",
+	}
+
+	for _, keys := range [][]string{
+		{"ID"},
+		{"Name", "ID"},
+		{"Description", "Name", "CreatedAt", "DeletedAt", "ID"},
+	} {
+		full, err := EncodeKeysetCursor(user, keys)
+		require.NoError(t, err)
+		expected, err := DecodeKeysetCursor[User](full, keys)
+		require.NoError(t, err)
+
+		encoder, err := NewKeysetEncoder[User](keys, WithSparseEncoding())
+		require.NoError(t, err)
+		cursor, err := encoder.Encode(user)
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(cursor, `["`+keysFingerprint(keys)+`",`), cursor)
+		if len(keys) > 2 {
+			require.Less(t, len(cursor), len(full))
+		}
+
+		// decode reconstructs the full keyset by the keys
+		m, err := DecodeKeysetCursor[User](cursor, keys, WithSparseEncoding())
+		require.NoError(t, err)
+		require.Equal(t, expected, m)
+
+		// the object cursors are still accepted
+		m, err = DecodeKeysetCursor[User](full, keys, WithSparseEncoding())
+		require.NoError(t, err)
+		require.Equal(t, expected, m)
+
+		// but sparse cursors are not without the option
+		_, err = DecodeKeysetCursor[User](cursor, keys)
+		require.ErrorContains(t, err, "unmarshal cursor")
+	}
+
+	// the key names of repeated leading columns are saved from every cursor of a page
+	{
+		keys := []string{"Description", "Name", "CreatedAt", "ID"}
+		objects, err := NewKeysetEncoder[User](keys)
+		require.NoError(t, err)
+		sparse, err := NewKeysetEncoder[User](keys, WithSparseEncoding())
+		require.NoError(t, err)
+		for i := 1; i <= 3; i++ {
+			node := User{Model: gorm.Model{ID: uint(i), CreatedAt: user.CreatedAt}, Name: user.Name, Description: strings.Repeat("long ", 10)}
+			full, err := objects.Encode(node)
+			require.NoError(t, err)
+			cursor, err := sparse.Encode(node)
+			require.NoError(t, err)
+			keyNames := lo.SumBy(keys, func(key string) int { return len(`"":`) + len(key) })
+			require.Equal(t, len(full)-keyNames+len(`"`+keysFingerprint(keys)+`",`), len(cursor), cursor)
+			m, err := DecodeKeysetCursor[User](cursor, keys, WithSparseEncoding())
+			require.NoError(t, err)
+			expected, err := DecodeKeysetCursor[User](full, keys)
+			require.NoError(t, err)
+			require.Equal(t, expected, m)
+		}
+	}
+
+	keys := []string{"Name", "ID"}
+	fingerprint := keysFingerprint(keys)
+	encoder, err := NewKeysetEncoder[User](keys, WithSparseEncoding())
+	require.NoError(t, err)
+	cursor, err := encoder.Encode(user)
+	require.NoError(t, err)
+	require.Equal(t, `["`+fingerprint+`","molon",1]`, cursor)
+
+	// the values are never assigned to the keys of other positions, e.g. of reordered order bys
+	for _, keys := range [][]string{{"ID", "Name"}, {"Name", "Description"}, {"Name"}} {
+		_, err := DecodeKeysetCursor[User](cursor, keys, WithSparseEncoding())
+		require.ErrorIs(t, err, ErrOrderMismatch)
+		require.ErrorIs(t, err, ErrInvalidCursor)
+	}
+	// while the directions don't matter
+	require.Equal(t, keysFingerprint(keys), orderFingerprint([]relay.OrderBy{{Field: "Name"}, {Field: "ID"}}))
+
+	for cursor, expected := range map[string]string{
+		`["molon",1]`:                          "missing order fingerprint",
+		`[null,"molon",1]`:                     "missing order fingerprint",
+		`[]`:                                   "missing order fingerprint",
+		`["` + fingerprint + `","molon"]`:      "cursor length != keys length",
+		`["` + fingerprint + `","molon",1,2]`:  "cursor length != keys length",
+		`["` + fingerprint + `","molon",1] []`: "unmarshal cursor",
+		`["` + fingerprint + `","molon",1`:     "unmarshal cursor",
+		`["` + fingerprint + `",` + strings.Repeat(`1,`, 100) + `1]`: "too many keys, max is 32",
+	} {
+		_, err := DecodeKeysetCursor[User](cursor, keys, WithSparseEncoding())
+		require.ErrorContains(t, err, expected, cursor)
+	}
+
+	// through the adapter
+	var found *map[string]any
+	adapter := NewKeysetAdapter(KeysetFinderFunc[User](func(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]User, error) {
+		found = after
+		return []User{user}, nil
+	}), WithSparseEncoding())
+	orderBys := []relay.OrderBy{{Field: "Name"}, {Field: "ID"}}
+	resp, err := adapter(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(`["` + fingerprint + `","alice",7]`), OrderBys: orderBys, Limit: 10})
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"Name": "alice", "ID": float64(7)}, *found)
+	cursor, err = resp.Edges[0].Cursor(context.Background(), resp.Edges[0].Node)
+	require.NoError(t, err)
+	require.Equal(t, `["`+fingerprint+`","molon",1]`, cursor)
+
+	// a cursor held across a change of the order bys
+	_, err = adapter(context.Background(), &relay.ApplyCursorsRequest{After: lo.ToPtr(cursor), OrderBys: []relay.OrderBy{{Field: "ID"}, {Field: "Name"}}, Limit: 10})
+	require.ErrorIs(t, err, ErrOrderMismatch)
+}
+
+func TestFieldOrderStability(t *testing.T) {
+	type Point struct {
+		X int
+		Y int
+	}
+	type PointReordered struct {
+		Y int
+		X int
+	}
+	type User struct {
+		ID        uint
+		Name      string
+		Score     *float64
+		CreatedAt time.Time
+		Location  Point
+	}
+	// the same fields in another order, e.g. after a refactoring between deploys
+	type UserReordered struct {
+		Location  PointReordered
+		CreatedAt time.Time
+		Score     *float64
+		Name      string
+		ID        uint
+	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	user := User{ID: 1, Name: "molon", Score: lo.ToPtr(1.5), CreatedAt: createdAt, Location: Point{X: 1, Y: 2}}
+	reordered := UserReordered{ID: 1, Name: "molon", Score: lo.ToPtr(1.5), CreatedAt: createdAt, Location: PointReordered{X: 1, Y: 2}}
+
+	for _, keys := range [][]string{
+		{"ID"},
+		{"Name", "ID"},
+		{"CreatedAt", "Score", "Name", "ID"},
+		{"ID", "Name", "Score", "CreatedAt", "Location"},
+	} {
+		expected, err := EncodeKeysetCursor(user, keys)
+		require.NoError(t, err)
+		cursor, err := EncodeKeysetCursor(reordered, keys)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor, keys)
+
+		encoder, err := NewKeysetEncoder[*User](keys)
+		require.NoError(t, err)
+		cursor, err = encoder.Encode(&user)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor, keys)
+
+		reorderedEncoder, err := NewKeysetEncoder[*UserReordered](keys)
+		require.NoError(t, err)
+		cursor, err = reorderedEncoder.Encode(&reordered)
+		require.NoError(t, err)
+		require.Equal(t, expected, cursor, keys)
+
+		// a cursor issued by either deploy is decoded to the same keyset by the other
+		m, err := DecodeKeysetCursor[*User](expected, keys)
+		require.NoError(t, err)
+		mReordered, err := DecodeKeysetCursor[*UserReordered](expected, keys)
+		require.NoError(t, err)
+		require.Equal(t, m, mReordered)
+	}
+
+	cursor, err := EncodeKeysetCursor(reordered, []string{"Location", "ID"})
+	require.NoError(t, err)
+	require.Equal(t, `{"ID":1,"Location":{"X":1,"Y":2}}`, cursor)
+}
+
+func TestNestedKeys(t *testing.T) {
+	type Customer struct {
+		ID   int
+		Name string
+	}
+	type Order struct {
+		ID       int
+		Customer *Customer
+	}
+	keys := []string{"Customer.Name", "ID"}
+	encoder, err := NewKeysetEncoder[*Order](keys)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		order    *Order
+		expected string
+	}{
+		{&Order{ID: 1, Customer: &Customer{ID: 2, Name: "molon"}}, `{"Customer.Name":"molon","ID":1}`},
+		// a relation without a joined row
+		{&Order{ID: 3}, `{"Customer.Name":null,"ID":3}`},
+	} {
+		cursor, err := EncodeKeysetCursor(tc.order, keys)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, cursor)
+
+		cursor, err = encoder.Encode(tc.order)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, cursor)
+
+		keyset, err := DecodeKeysetCursor[*Order](cursor, keys)
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{"Customer.Name": lo.Ternary[any](tc.order.Customer != nil, "molon", nil), "ID": float64(tc.order.ID)}, keyset)
+	}
+
+	_, err = NewKeysetEncoder[*Order]([]string{"Customer.Missing"})
+	require.ErrorContains(t, err, `key "Customer.Missing" not found in node`)
+	_, err = EncodeKeysetCursor(&Order{ID: 1, Customer: &Customer{}}, []string{"ID.Name"})
+	require.ErrorContains(t, err, `key "ID.Name" not found in node`)
+}
+
+func TestCursorTagKey(t *testing.T) {
+	type Customer struct {
+		Name string `json:"name"`
+	}
+	type Base struct {
+		CreatedAt int `json:"created_at"`
+	}
+	type Order struct {
+		Base
+		ID       int       `json:"id,omitempty"`
+		Secret   string    `json:"-"`
+		Total    int       // without the tag
+		Customer *Customer `json:"customer"`
+	}
+	keys := []string{"CreatedAt", "Customer.Name", "Secret", "Total", "ID"}
+	order := &Order{Base: Base{CreatedAt: 5}, ID: 1, Secret: "s", Total: 7, Customer: &Customer{Name: "molon"}}
+	const expected = `{"Secret":"s","Total":7,"created_at":5,"customer.name":"molon","id":1}`
+
+	encoder, err := NewKeysetEncoder[*Order](keys, WithCursorTagKey("json"))
+	require.NoError(t, err)
+	cursor, err := encoder.Encode(order)
+	require.NoError(t, err)
+	require.Equal(t, expected, cursor)
+
+	// decoded by the same mapping, keyed by the fields
+	keyset, err := DecodeKeysetCursor[*Order](cursor, keys, WithCursorTagKey("json"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"CreatedAt": float64(5), "Customer.Name": "molon", "Secret": "s", "Total": float64(7), "ID": float64(1)}, keyset)
+
+	// the cursors keyed by the fields aren't accepted then
+	cursor, err = EncodeKeysetCursor(order, keys)
+	require.NoError(t, err)
+	_, err = DecodeKeysetCursor[*Order](cursor, keys, WithCursorTagKey("json"))
+	require.ErrorIs(t, err, relay.ErrInvalidCursor)
+	require.ErrorContains(t, err, `key "created_at" not found in cursor`)
+
+	type Conflict struct {
+		ID   int `api:"id"`
+		UUID int `api:"id"`
+	}
+	_, err = NewKeysetEncoder[*Conflict]([]string{"ID", "UUID"}, WithCursorTagKey("api"))
+	require.ErrorContains(t, err, `keys "ID" and "UUID" have the same cursor key "id"`)
+	_, err = NewKeysetEncoder[any]([]string{"ID"}, WithCursorTagKey("json"))
+	require.ErrorContains(t, err, "node type interface {} is not a struct or struct pointer")
+}
@@ -6,6 +6,7 @@ import (
 
 	relay "github.com/molon/gorelay"
 	"github.com/pkg/errors"
+	"github.com/samber/lo"
 )
 
 type OffsetFinder[T any] interface {
@@ -18,61 +19,104 @@ func (f OffsetFinderFunc[T]) Find(ctx context.Context, orderBys []relay.OrderBy,
 	return f(ctx, orderBys, skip, limit)
 }
 
+// OffsetParser encodes offsets into cursors and decodes them back, see WithOffsetParser.
+type OffsetParser interface {
+	Encode(offset int) string
+	Decode(cursor string) (int, error)
+}
+
+type offsetParser struct{}
+
+func (offsetParser) Encode(offset int) string {
+	return EncodeOffsetCursor(offset)
+}
+
+func (offsetParser) Decode(cursor string) (int, error) {
+	return DecodeOffsetCursor(cursor)
+}
+
 // NewOffsetAdapter creates a relay.ApplyCursorsFunc from an OffsetFinder.
-// If you want to use `last!=nil&&before==nil`, the finder must implement Counter.
-func NewOffsetAdapter[T any](finder OffsetFinder[T]) relay.ApplyCursorsFunc[T] {
+// If you want to use `last!=nil&&before==nil`, the finder must implement Counter,
+// the tail is then read directly by skipping `totalCount - limit` rows.
+func NewOffsetAdapter[T any](finder OffsetFinder[T], opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
 	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
-		after, before, err := decodeOffsetCursors(req.After, req.Before)
+		after, before, err := decodeOffsetCursors(o.offsetParser, req.After, req.Before)
 		if err != nil {
+			req.Hooks.ReportError(ctx, relay.StageDecodeCursor, err)
 			return nil, err
 		}
 
-		var totalCount int
-		counter, hasCounter := finder.(Counter)
-		if hasCounter {
-			var err error
-			totalCount, err = counter.Count(ctx)
+		var nodes []T
+		var counted *countResult
+		fetched := false
+		if windowFinder, ok := finder.(OffsetWindowFinder[T]); ok && !(req.FromLast && before == nil) && !skipsCount(ctx, finder, req) {
+			if skip, limit := locateOffsets(after, before, req.Limit, req.FromLast); limit > 0 {
+				// The page and the total count are fetched by one query
+				var totalCount int
+				nodes, totalCount, err = hookFindWithCount(ctx, req, func() ([]T, int, error) {
+					return windowFinder.FindWithCount(ctx, req.OrderBys, skip, limit)
+				})
+				if err != nil {
+					return nil, err
+				}
+				fetched = true
+				if totalCount >= 0 {
+					counted = &countResult{counted: true, totalCount: totalCount}
+				} else if counted, err = countTotal(ctx, finder, req, false); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !fetched && after == nil && before == nil && !req.FromLast && req.Limit > 0 {
+			// Fetch the first page before counting, if it comes back short it is also the last page
+			nodes, err = hookFind(ctx, req, func() ([]T, error) {
+				return finder.Find(ctx, req.OrderBys, 0, req.Limit)
+			})
 			if err != nil {
 				return nil, err
 			}
+			fetched = true
 		}
 
+		switch {
+		case counted != nil:
+		case fetched && len(nodes) < req.Limit:
+			counted = countFromLastPage(finder, req, len(nodes))
+		default:
+			// The count can't be skipped if we need it to locate the last page
+			counted, err = countTotal(ctx, finder, req, req.FromLast && before == nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+		hasCounter, totalCount := counted.counted, counted.totalCount
+		// An approximate count, e.g. by gormrelay.WithMaxPKCount, only reports the total but doesn't bound the rows
+		exact := hasCounter && !counted.approximate
+
 		if req.FromLast && before == nil {
 			if !hasCounter {
 				return nil, errors.New("counter is required for fromLast and nil before")
 			}
 			before = &totalCount
 		}
-
-		limit, skip := req.Limit, 0
-		if after != nil {
-			skip = *after + 1
-		} else if before != nil {
-			skip = *before - limit
-		}
-		if skip < 0 {
-			skip = 0
-		}
-		if before != nil {
-			rangeLen := *before - skip
-			if rangeLen <= 0 {
-				rangeLen = 0
-			}
-			if limit > rangeLen {
-				limit = rangeLen
-			}
-			if req.FromLast && limit < rangeLen {
-				skip = *before - limit
-			}
+		if o.offsetDebugHook != nil {
+			o.offsetDebugHook(ctx, after, before)
 		}
 
+		skip, limit := locateOffsets(after, before, req.Limit, req.FromLast)
+
 		var edges []relay.LazyEdge[T]
-		if limit <= 0 || (hasCounter && (skip >= totalCount || totalCount <= 0)) {
+		if !fetched && (limit <= 0 || (exact && (skip >= totalCount || totalCount <= 0))) {
 			edges = make([]relay.LazyEdge[T], 0)
 		} else {
-			nodes, err := finder.Find(ctx, req.OrderBys, skip, limit)
-			if err != nil {
-				return nil, err
+			if !fetched {
+				nodes, err = hookFind(ctx, req, func() ([]T, error) {
+					return finder.Find(ctx, req.OrderBys, skip, limit)
+				})
+				if err != nil {
+					return nil, err
+				}
 			}
 			edges = make([]relay.LazyEdge[T], len(nodes))
 			for i, node := range nodes {
@@ -80,18 +124,22 @@ func NewOffsetAdapter[T any](finder OffsetFinder[T]) relay.ApplyCursorsFunc[T] {
 				edges[i] = relay.LazyEdge[T]{
 					Node: node,
 					Cursor: func(_ context.Context, _ T) (string, error) {
-						return EncodeOffsetCursor(skip + i), nil
+						return o.offsetParser.Encode(skip + i), nil
 					},
+					Index: lo.ToPtr(skip + i + 1),
 				}
 			}
 		}
 
 		resp := &relay.ApplyCursorsResponse[T]{
-			Edges:      edges,
-			TotalCount: totalCount,
+			Edges:                 edges,
+			TotalCount:            totalCount,
+			TotalCountApproximate: counted.approximate,
+			TotalCountSkipped:     counted.skipped,
+			Paged:                 hasCounter,
 		}
 
-		if hasCounter {
+		if exact {
 			resp.HasAfterOrPrevious = after != nil && *after < totalCount
 			resp.HasBeforeOrNext = before != nil && *before < totalCount
 		} else {
@@ -105,6 +153,27 @@ func NewOffsetAdapter[T any](finder OffsetFinder[T]) relay.ApplyCursorsFunc[T] {
 	}
 }
 
+// locateOffsets returns the skip and limit of the page between after and before
+func locateOffsets(after, before *int, limit int, fromLast bool) (int, int) {
+	skip := 0
+	if after != nil {
+		skip = *after + 1
+	}
+	if before != nil {
+		rangeLen := *before - skip
+		if rangeLen <= 0 {
+			rangeLen = 0
+		}
+		if limit > rangeLen {
+			limit = rangeLen
+		}
+		if fromLast && limit < rangeLen {
+			skip = *before - limit
+		}
+	}
+	return skip, limit
+}
+
 func EncodeOffsetCursor(offset int) string {
 	return strconv.Itoa(offset)
 }
@@ -117,29 +186,29 @@ func DecodeOffsetCursor(cursor string) (int, error) {
 	return offset, nil
 }
 
-func decodeOffsetCursors(after, before *string) (afterOffset, beforeOffset *int, err error) {
+func decodeOffsetCursors(parser OffsetParser, after, before *string) (afterOffset, beforeOffset *int, err error) {
 	if after != nil {
-		offset, err := DecodeOffsetCursor(*after)
+		offset, err := parser.Decode(*after)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, invalidCursor(err)
 		}
 		afterOffset = &offset
 	}
 	if before != nil {
-		offset, err := DecodeOffsetCursor(*before)
+		offset, err := parser.Decode(*before)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, invalidCursor(err)
 		}
 		beforeOffset = &offset
 	}
 	if afterOffset != nil && *afterOffset < 0 {
-		return nil, nil, errors.New("after < 0")
+		return nil, nil, invalidCursor(errors.New("after < 0"))
 	}
 	if beforeOffset != nil && *beforeOffset < 0 {
-		return nil, nil, errors.New("before < 0")
+		return nil, nil, invalidCursor(errors.New("before < 0"))
 	}
 	if afterOffset != nil && before != nil && *afterOffset >= *beforeOffset {
-		return nil, nil, errors.New("after >= before")
+		return nil, nil, invalidCursor(errors.New("after >= before"))
 	}
 	return afterOffset, beforeOffset, nil
 }
@@ -0,0 +1,156 @@
+package cursor
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultMaxKeysetKeys is the default maximum number of keys accepted in a keyset cursor
+const DefaultMaxKeysetKeys = 32
+
+type options struct {
+	maxKeysetKeys     int
+	keysetNormalizers map[string]func(v any) (any, error)
+	checkConsistency  bool
+	checkKeysets      bool
+	orderMigrations   []orderMigration
+	orderFingerprint  bool
+	emptyRangeOnEqual bool
+	redactedKeys      map[string]bool
+	keysetDebugHook   func(ctx context.Context, after, before map[string]any)
+	offsetParser      OffsetParser
+	offsetDebugHook   func(ctx context.Context, after, before *int)
+	sparseEncoding    bool
+	compareStrings    func(a, b string) int
+	cursorTagKey      string
+	exactExistence    bool
+	tolerantBase64    bool
+}
+
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		maxKeysetKeys:  DefaultMaxKeysetKeys,
+		offsetParser:   offsetParser{},
+		compareStrings: strings.Compare,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMaxKeysetKeys bounds the number of keys a keyset cursor may contain,
+// decoding stops with an error as soon as it is exceeded, so crafted cursors can't make the decoder do much work.
+func WithMaxKeysetKeys(n int) Option {
+	if n <= 0 {
+		panic("max keyset keys must be greater than 0")
+	}
+	return func(o *options) {
+		o.maxKeysetKeys = n
+	}
+}
+
+// WithKeysetNormalizer normalizes the value of key before it is encoded into keyset cursors, e.g. converting timestamps to UTC.
+// v is the value of the field, or its JSON value if the node marshals itself.
+func WithKeysetNormalizer(key string, normalize func(v any) (any, error)) Option {
+	return func(o *options) {
+		if o.keysetNormalizers == nil {
+			o.keysetNormalizers = map[string]func(v any) (any, error){}
+		}
+		o.keysetNormalizers[key] = normalize
+	}
+}
+
+// WithEmptyRangeOnEqualCursors treats keyset cursors with `after == before` as an empty range, which returns no edges,
+// e.g. for clients polling the rows between X and X. By default it is rejected as an error.
+func WithEmptyRangeOnEqualCursors() Option {
+	return func(o *options) {
+		o.emptyRangeOnEqual = true
+	}
+}
+
+// WithConsistencyCheck rejects keyset cursors with ErrInconsistentCursor if the after cursor is not before the before cursor by the order bys,
+// which would only produce an empty or nonsense page. Values are compared in memory, numbers and bools by value,
+// RFC 3339 strings as times and other strings bytewise (see WithStringCollation), the check is skipped if the values can't be compared, e.g. NULLs.
+// It's opt-in, since the in memory order doesn't know the collations, order exprs and normalizers of the fields,
+// and numbers are compared as float64, so only enable it for order bys whose values compare the same in memory as in the database.
+func WithConsistencyCheck() Option {
+	return func(o *options) {
+		o.checkConsistency = true
+	}
+}
+
+// WithKeysetIntegrityCheck fails the pagination with ErrAmbiguousKeyset if consecutive rows of a page have the same keyset,
+// i.e. the order bys can't tell them apart, so they may be skipped or repeated across pages. Every cursor of a page is encoded eagerly for it,
+// so it's meant for tests and debugging, to find an order that lacks a unique tiebreak against the real data.
+func WithKeysetIntegrityCheck() Option {
+	return func(o *options) {
+		o.checkKeysets = true
+	}
+}
+
+// WithStringCollation compares strings in memory by compare instead of bytewise, which only matches the C collation of the database,
+// e.g. case-insensitively for a column of a case-insensitive collation. It's used by WithConsistencyCheck and the slice finders,
+// so that their orders and the boundaries of pages match the database's.
+func WithStringCollation(compare func(a, b string) int) Option {
+	return func(o *options) {
+		o.compareStrings = compare
+	}
+}
+
+// WithOffsetParser replaces the format of offset cursors, which are the plain offsets by default,
+// e.g. RelayArrayConnectionParser for the clients expecting the cursors of graphql-relay-js.
+func WithOffsetParser(parser OffsetParser) Option {
+	return func(o *options) {
+		o.offsetParser = parser
+	}
+}
+
+// WithOffsetDebugHook calls hook with the offsets of the after and before cursors (nil if not set) each page is bounded by, e.g. to audit them.
+// The before of `last` without `before` is the total count, which the last page is located by.
+func WithOffsetDebugHook(hook func(ctx context.Context, after, before *int)) Option {
+	return func(o *options) {
+		o.offsetDebugHook = hook
+	}
+}
+
+// WithSparseEncoding is experimental, it encodes keyset cursors as JSON arrays of the fingerprint of the order by fields
+// followed by their values in the same order, e.g. `["1c2b3a4d",30,"name",7]` instead of `{"Age":30,"ID":7,"Name":"name"}`.
+// It only elides the key names, which are repeated in every cursor of deep composite keysets, the values are still encoded in full.
+// Leading values are neither shared between cursors nor delta encoded, since every cursor has to decode on its own without the others of its page.
+// The values are assigned to the keys by their positions, so a cursor decoded against other order by fields, e.g. reordered,
+// is rejected with ErrOrderMismatch instead of being misread. The object cursors issued before are still accepted.
+func WithSparseEncoding() Option {
+	return func(o *options) {
+		o.sparseEncoding = true
+	}
+}
+
+// WithCursorTagKey names the keys of keyset cursors by the struct tag tagKey of their fields, e.g. "json",
+// so that cursors read like the API, e.g. `{"created_at":...}` instead of `{"CreatedAt":...}`, while the order bys still refer to the fields.
+// Each level of a nested key is named by its field, and the fields without the tag or tagged with `-` keep their keys.
+func WithCursorTagKey(tagKey string) Option {
+	return func(o *options) {
+		o.cursorTagKey = tagKey
+	}
+}
+
+// WithExactCursorExistence makes the keyset adapter check whether the after cursor has a previous row and the before cursor a next one,
+// if the finder implements KeysetExistChecker, so HasPreviousPage and HasNextPage of a cursor beyond the rows are false,
+// e.g. an after cursor preceding the first row. It costs a query per cursor, by default a cursor is assumed to have them.
+func WithExactCursorExistence() Option {
+	return func(o *options) {
+		o.exactExistence = true
+	}
+}
+
+// WithTolerantBase64 makes WrapBase64 accept the cursors mangled by clients, i.e. unpadded, URL-safe,
+// with `+` decoded as a space by a form, or URL-encoded once more, so that they aren't rejected as invalid cursors.
+// The issued cursors are unchanged.
+func WithTolerantBase64() Option {
+	return func(o *options) {
+		o.tolerantBase64 = true
+	}
+}
@@ -0,0 +1,149 @@
+package cursor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// ErrOrderMismatch is returned by WrapOrderFingerprint if a cursor was issued under other order bys,
+// e.g. before the default order is changed, clients should restart the pagination without cursors.
+var ErrOrderMismatch = errors.New("cursor was issued under a different order, restart the pagination without cursors")
+
+type orderMigration struct {
+	from, to string
+}
+
+// WithOrderMigration makes WrapOrderFingerprint accept cursors issued under from for requests ordered by to,
+// e.g. after the default direction is flipped. from and to must be of the same fields and only differ in directions,
+// then a keyset cursor is still the position of the same row, and the pagination continues from it in the new directions.
+// It's not meaningful for offset cursors, whose positions depend on the directions.
+func WithOrderMigration(from, to []relay.OrderBy) Option {
+	fields := func(orderBys []relay.OrderBy) []string {
+		return lo.Map(orderBys, func(item relay.OrderBy, _ int) string { return item.Field })
+	}
+	if !slices.Equal(fields(from), fields(to)) {
+		panic("order migration must be between order bys of the same fields")
+	}
+	return func(o *options) {
+		o.orderMigrations = append(o.orderMigrations, orderMigration{from: orderFingerprint(from), to: orderFingerprint(to)})
+	}
+}
+
+// WrapOrderFingerprint embeds the fingerprint of the order bys, i.e. their fields and directions, into cursors,
+// and rejects cursors issued under other order bys with ErrOrderMismatch, unless migrated by WithOrderMigration.
+func WrapOrderFingerprint[T any](next relay.ApplyCursorsFunc[T], opts ...Option) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		fingerprint := orderFingerprint(req.OrderBys)
+
+		if req.After != nil {
+			cursor, err := checkOrderFingerprint(*req.After, fingerprint, o.orderMigrations)
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid after cursor"))
+			}
+			req.After = lo.ToPtr(cursor)
+		}
+
+		if req.Before != nil {
+			cursor, err := checkOrderFingerprint(*req.Before, fingerprint, o.orderMigrations)
+			if err != nil {
+				return nil, invalidCursor(errors.Wrap(err, "invalid before cursor"))
+			}
+			req.Before = lo.ToPtr(cursor)
+		}
+
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range resp.Edges {
+			edge := &resp.Edges[i]
+			originalCursor := edge.Cursor
+			edge.Cursor = func(ctx context.Context, node T) (string, error) {
+				cursor, err := originalCursor(ctx, node)
+				if err != nil {
+					return "", err
+				}
+				return fingerprint + ":" + cursor, nil
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// WithOrderFingerprint makes NewKeysetAdapter embed the fingerprint of the order bys into keyset cursors as WrapOrderFingerprint does,
+// and reject cursors issued under other order bys with ErrOrderMismatch, unless migrated by WithOrderMigration.
+// Cursors without a fingerprint, e.g. issued before it's enabled, are still accepted.
+func WithOrderFingerprint() Option {
+	return func(o *options) {
+		o.orderFingerprint = true
+	}
+}
+
+// stripOrderFingerprint checks the fingerprint of cursor if any, and returns the cursor without it
+func stripOrderFingerprint(cursor *string, fingerprint string, migrations []orderMigration) (*string, error) {
+	if cursor == nil {
+		return nil, nil
+	}
+	if issuedUnder, _, ok := strings.Cut(*cursor, ":"); !ok || !isFingerprint(issuedUnder) {
+		// keyset cursors are JSON objects or arrays, so it's one issued without a fingerprint
+		return cursor, nil
+	}
+	stripped, err := checkOrderFingerprint(*cursor, fingerprint, migrations)
+	if err != nil {
+		return nil, invalidCursor(errors.Wrap(err, "order by mismatch"))
+	}
+	return &stripped, nil
+}
+
+func orderFingerprint(orderBys []relay.OrderBy) string {
+	h := fnv.New32a()
+	for _, orderBy := range orderBys {
+		fmt.Fprintf(h, "%s:%t;", orderBy.Field, orderBy.Desc)
+		if orderBy.Nulls != relay.NullsDefault {
+			// only if set, so the fingerprints of the existing cursors don't change
+			fmt.Fprintf(h, "nulls:%s;", orderBy.Nulls)
+		}
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// keysFingerprint is the fingerprint of the fields of keys in order, regardless of the directions,
+// which a cursor of WithSparseEncoding is positioned by
+func keysFingerprint(keys []string) string {
+	return orderFingerprint(lo.Map(keys, func(key string, _ int) relay.OrderBy { return relay.OrderBy{Field: key} }))
+}
+
+func checkOrderFingerprint(cursor string, fingerprint string, migrations []orderMigration) (string, error) {
+	issuedUnder, cursor, ok := strings.Cut(cursor, ":")
+	if !ok || !isFingerprint(issuedUnder) {
+		return "", errors.New("missing order fingerprint")
+	}
+	if issuedUnder == fingerprint {
+		return cursor, nil
+	}
+	for _, migration := range migrations {
+		if migration.from == issuedUnder && migration.to == fingerprint {
+			return cursor, nil
+		}
+	}
+	return "", errors.WithStack(ErrOrderMismatch)
+}
+
+func isFingerprint(s string) bool {
+	if len(s) != 8 {
+		return false
+	}
+	_, err := strconv.ParseUint(s, 16, 32)
+	return err == nil
+}
@@ -0,0 +1,85 @@
+package cursor
+
+import (
+	"context"
+	"maps"
+
+	"github.com/pkg/errors"
+)
+
+// RedactedValue replaces the values of redacted keys in debug output and error messages
+const RedactedValue = "[REDACTED]"
+
+// WithRedactedKeys masks the values of keys (e.g. PII) with RedactedValue in the keysets passed to the hook of WithKeysetDebugHook,
+// and keeps the contents of malformed cursors out of decoding errors, as does gormrelay for the invalid values of the keys.
+// The real values are still used for the queries.
+func WithRedactedKeys(keys ...string) Option {
+	return func(o *options) {
+		if o.redactedKeys == nil {
+			o.redactedKeys = map[string]bool{}
+		}
+		for _, key := range keys {
+			o.redactedKeys[key] = true
+		}
+	}
+}
+
+// RedactedKeys returns the keys of WithRedactedKeys in opts, e.g. for finders to keep their values out of errors as well
+func RedactedKeys(opts ...Option) map[string]bool {
+	return newOptions(opts).redactedKeys
+}
+
+// WithKeysetDebugHook calls hook with the keysets decoded from the after and before cursors (nil if not set) before finding,
+// e.g. to log or audit them, with the values converted by the finder if it's a KeysetConverter, and those of WithRedactedKeys masked.
+func WithKeysetDebugHook(hook func(ctx context.Context, after, before map[string]any)) Option {
+	return func(o *options) {
+		o.keysetDebugHook = hook
+	}
+}
+
+// debugKeysets calls the hook of WithKeysetDebugHook with after and before converted by finder if it's a KeysetConverter
+func debugKeysets(ctx context.Context, finder any, after, before *map[string]any, o *options) error {
+	convert := func(keyset *map[string]any) (*map[string]any, error) {
+		converter, ok := finder.(KeysetConverter)
+		if !ok || keyset == nil {
+			return keyset, nil
+		}
+		m, err := converter.ConvertKeyset(ctx, *keyset)
+		if err != nil {
+			return nil, err
+		}
+		return &m, nil
+	}
+	after, err := convert(after)
+	if err != nil {
+		return err
+	}
+	before, err = convert(before)
+	if err != nil {
+		return err
+	}
+	o.keysetDebugHook(ctx, o.redactKeyset(after), o.redactKeyset(before))
+	return nil
+}
+
+// redactKeyset returns a copy of keyset with the values of the redacted keys masked
+func (o *options) redactKeyset(keyset *map[string]any) map[string]any {
+	if keyset == nil {
+		return nil
+	}
+	m := maps.Clone(*keyset)
+	for key := range m {
+		if o.redactedKeys[key] {
+			m[key] = RedactedValue
+		}
+	}
+	return m
+}
+
+// redactDecodeError drops the message of err, which may quote the cursor, if any key is redacted
+func (o *options) redactDecodeError(err error) error {
+	if err == nil || len(o.redactedKeys) == 0 {
+		return err
+	}
+	return errors.New("unmarshal cursor: malformed cursor")
+}
@@ -0,0 +1,54 @@
+package cursor
+
+import (
+	"context"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactedKeys(t *testing.T) {
+	type Customer struct {
+		Email string
+		ID    int
+	}
+
+	var found []map[string]any
+	finder := KeysetFinderFunc[*Customer](func(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]*Customer, error) {
+		found = append(found, *after, *before)
+		return []*Customer{}, nil
+	})
+
+	var debugged []map[string]any
+	adapter := NewKeysetAdapter(finder,
+		WithRedactedKeys("Email"),
+		WithKeysetDebugHook(func(ctx context.Context, after, before map[string]any) {
+			debugged = append(debugged, after, before)
+		}),
+	)
+
+	orderBys := []relay.OrderBy{{Field: "Email"}, {Field: "ID"}}
+	after, before := `{"Email":"alice@example.com","ID":1}`, `{"Email":"bob@example.com","ID":2}`
+	_, err := adapter(context.Background(), &relay.ApplyCursorsRequest{After: &after, Before: &before, OrderBys: orderBys, Limit: 10})
+	require.NoError(t, err)
+
+	// the query uses the real values
+	require.Equal(t, []map[string]any{
+		{"Email": "alice@example.com", "ID": float64(1)},
+		{"Email": "bob@example.com", "ID": float64(2)},
+	}, found)
+	require.Equal(t, []map[string]any{
+		{"Email": RedactedValue, "ID": float64(1)},
+		{"Email": RedactedValue, "ID": float64(2)},
+	}, debugged)
+
+	// malformed cursors are not quoted in errors
+	malformed := `{"Email":"alice@example.com","ID":1`
+	_, err = adapter(context.Background(), &relay.ApplyCursorsRequest{After: &malformed, OrderBys: orderBys, Limit: 10})
+	require.ErrorContains(t, err, "malformed cursor")
+	require.NotContains(t, err.Error(), "alice")
+
+	_, err = NewKeysetAdapter(finder)(context.Background(), &relay.ApplyCursorsRequest{After: &malformed, OrderBys: orderBys, Limit: 10})
+	require.ErrorContains(t, err, "alice")
+}
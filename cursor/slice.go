@@ -0,0 +1,174 @@
+package cursor
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+)
+
+// SliceKeysetFinder is a KeysetFinder and Counter over an in-memory slice, e.g. cached or static data, or results from an external API.
+// The order bys, the seek of after/before, the limit and fromLast are applied in Go by the values of the fields as encoded in cursors,
+// which must be numbers, strings, bools or times. Strings are compared bytewise unless WithStringCollation is set,
+// and NULLs are the smallest unless the position is specified by the order by.
+// The items are sorted for every Find, so it's meant for small slices.
+type SliceKeysetFinder[T any] struct {
+	items []T
+	opts  []Option
+}
+
+// NewSliceKeysetFinder creates a SliceKeysetFinder, opts should be the same as the adapter's, so that the values are compared as encoded.
+func NewSliceKeysetFinder[T any](items []T, opts ...Option) *SliceKeysetFinder[T] {
+	return &SliceKeysetFinder[T]{items: items, opts: opts}
+}
+
+func (f *SliceKeysetFinder[T]) Find(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sorted, err := sortSlice(f.items, orderBys, f.opts)
+	if err != nil {
+		return nil, err
+	}
+	compareStrings := newOptions(f.opts).compareStrings
+
+	// sort.Search can't fail, so the first error of the comparisons is kept
+	var searchErr error
+	search := func(keyset map[string]any, found func(c int) bool) int {
+		return sort.Search(len(sorted), func(i int) bool {
+			c, err := compareSliceKeysets(sorted[i].keyset, keyset, orderBys, compareStrings)
+			if err != nil && searchErr == nil {
+				searchErr = invalidCursor(err)
+			}
+			return found(c)
+		})
+	}
+	start, end := 0, len(sorted)
+	if after != nil {
+		start = search(*after, func(c int) bool { return c > 0 })
+	}
+	if before != nil {
+		end = search(*before, func(c int) bool { return c >= 0 })
+	}
+	if searchErr != nil {
+		return nil, searchErr
+	}
+
+	items := sorted[min(start, end):end]
+	if limit < len(items) {
+		if fromLast {
+			items = items[len(items)-max(limit, 0):]
+		} else {
+			items = items[:max(limit, 0)]
+		}
+	}
+	return lo.Map(items, func(item sliceItem[T], _ int) T { return item.node }), nil
+}
+
+func (f *SliceKeysetFinder[T]) Count(ctx context.Context) (int, error) {
+	return len(f.items), nil
+}
+
+// SliceOffsetFinder is an OffsetFinder and Counter over an in-memory slice, the items are sorted as SliceKeysetFinder does.
+type SliceOffsetFinder[T any] struct {
+	items []T
+	opts  []Option
+}
+
+func NewSliceOffsetFinder[T any](items []T, opts ...Option) *SliceOffsetFinder[T] {
+	return &SliceOffsetFinder[T]{items: items, opts: opts}
+}
+
+func (f *SliceOffsetFinder[T]) Find(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sorted, err := sortSlice(f.items, orderBys, f.opts)
+	if err != nil {
+		return nil, err
+	}
+	start := min(max(skip, 0), len(sorted))
+	end := min(start+max(limit, 0), len(sorted))
+	return lo.Map(sorted[start:end], func(item sliceItem[T], _ int) T { return item.node }), nil
+}
+
+func (f *SliceOffsetFinder[T]) Count(ctx context.Context) (int, error) {
+	return len(f.items), nil
+}
+
+type sliceItem[T any] struct {
+	node   T
+	keyset map[string]any
+}
+
+// sortSlice returns the items with their keysets decoded from their cursors, sorted by orderBys
+func sortSlice[T any](items []T, orderBys []relay.OrderBy, opts []Option) ([]sliceItem[T], error) {
+	keys := lo.Map(orderBys, func(item relay.OrderBy, _ int) string { return item.Field })
+	encoder, err := NewKeysetEncoder[T](keys, opts...)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	sorted := make([]sliceItem[T], len(items))
+	for i, node := range items {
+		cursor, err := encoder.Encode(node)
+		if err != nil {
+			return nil, err
+		}
+		// decoded as the cursors of after and before are, which are keyed by the fields
+		keyset, err := decodeKeysetCursorUnmarked(cursor, keys, encoder.cursorKeys, o)
+		if err != nil {
+			return nil, err
+		}
+		sorted[i] = sliceItem[T]{node: node, keyset: keyset}
+	}
+
+	compareStrings := o.compareStrings
+	var sortErr error
+	slices.SortStableFunc(sorted, func(a, b sliceItem[T]) int {
+		c, err := compareSliceKeysets(a.keyset, b.keyset, orderBys, compareStrings)
+		if err != nil && sortErr == nil {
+			sortErr = err
+		}
+		return c
+	})
+	if sortErr != nil {
+		return nil, sortErr
+	}
+	return sorted, nil
+}
+
+// compareSliceKeysets is like compareKeysets, but NULLs are ordered as well, and values which can't be compared are errors
+func compareSliceKeysets(a, b map[string]any, orderBys []relay.OrderBy, compareStrings func(a, b string) int) (int, error) {
+	for _, orderBy := range orderBys {
+		av, bv := a[orderBy.Field], b[orderBy.Field]
+		if av == nil || bv == nil {
+			if av == nil && bv == nil {
+				continue
+			}
+			c := lo.Ternary(av == nil, -1, 1)
+			switch {
+			case orderBy.Nulls == relay.NullsFirst:
+			case orderBy.Nulls == relay.NullsLast:
+				c = -c
+			case orderBy.Desc:
+				c = -c
+			}
+			return c, nil
+		}
+		c, ok := compareDecodedValues(av, bv, compareStrings)
+		if !ok {
+			return 0, errors.Errorf("field %q of %T and %T can't be compared", orderBy.Field, av, bv)
+		}
+		if c != 0 {
+			if orderBy.Desc {
+				return -c, nil
+			}
+			return c, nil
+		}
+	}
+	return 0, nil
+}
@@ -0,0 +1,130 @@
+package cursor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type sliceNode struct {
+	ID        int       `api:"id"`
+	Score     *float64  `api:"score"`
+	CreatedAt time.Time `api:"createdAt"`
+}
+
+func TestSliceFinders(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var nodes []*sliceNode
+	for i := 1; i <= 10; i++ {
+		node := &sliceNode{ID: i, CreatedAt: base.Add(time.Duration(i%3) * time.Hour)}
+		if i%4 != 0 {
+			node.Score = lo.ToPtr(float64(i % 5))
+		}
+		nodes = append(nodes, node)
+	}
+
+	ids := func(resp *relay.PaginateResponse[*sliceNode]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*sliceNode], _ int) int { return edge.Node.ID })
+	}
+	walk := func(t *testing.T, p relay.Pagination[*sliceNode], orderBys []relay.OrderBy, backward bool) []int {
+		var all []int
+		var cursor *string
+		for {
+			req := &relay.PaginateRequest[*sliceNode]{OrderBys: orderBys}
+			if backward {
+				req.Before, req.Last = cursor, lo.ToPtr(3)
+			} else {
+				req.After, req.First = cursor, lo.ToPtr(3)
+			}
+			resp, err := p.Paginate(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, 10, resp.PageInfo.TotalCount)
+			if backward {
+				all = append(ids(resp), all...)
+				if !resp.PageInfo.HasPreviousPage {
+					return all
+				}
+				cursor = resp.PageInfo.StartCursor
+			} else {
+				all = append(all, ids(resp)...)
+				if !resp.PageInfo.HasNextPage {
+					return all
+				}
+				cursor = resp.PageInfo.EndCursor
+			}
+		}
+	}
+
+	defaultOrderBys := []relay.OrderBy{{Field: "ID"}}
+	paginations := map[string]relay.Pagination[*sliceNode]{
+		"Keyset": relay.New(false, 10, 10, defaultOrderBys, NewKeysetAdapter[*sliceNode](NewSliceKeysetFinder(nodes))),
+		"Offset": relay.New(false, 10, 10, defaultOrderBys, NewOffsetAdapter[*sliceNode](NewSliceOffsetFinder(nodes))),
+	}
+	// the items are compared by the values of the fields however the cursors are encoded
+	for name, opts := range map[string][]Option{
+		"Sparse": {WithSparseEncoding()},
+		"TagKey": {WithCursorTagKey("api")},
+	} {
+		paginations["Keyset"+name] = relay.New(false, 10, 10, defaultOrderBys, NewKeysetAdapter[*sliceNode](NewSliceKeysetFinder(nodes, opts...), opts...))
+		paginations["Offset"+name] = relay.New(false, 10, 10, defaultOrderBys, NewOffsetAdapter[*sliceNode](NewSliceOffsetFinder(nodes, opts...), opts...))
+	}
+	for _, tc := range []struct {
+		name     string
+		orderBys []relay.OrderBy
+		expected []int
+	}{
+		{
+			name:     "Desc",
+			orderBys: []relay.OrderBy{{Field: "ID", Desc: true}},
+			expected: []int{10, 9, 8, 7, 6, 5, 4, 3, 2, 1},
+		},
+		{
+			name:     "Time",
+			orderBys: []relay.OrderBy{{Field: "CreatedAt"}, {Field: "ID", Desc: true}},
+			expected: []int{9, 6, 3, 10, 7, 4, 1, 8, 5, 2},
+		},
+		{
+			name:     "NullsDefault",
+			orderBys: []relay.OrderBy{{Field: "Score", Desc: true}, {Field: "ID"}},
+			expected: []int{9, 3, 2, 7, 1, 6, 5, 10, 4, 8},
+		},
+		{
+			name:     "NullsFirst",
+			orderBys: []relay.OrderBy{{Field: "Score", Desc: true, Nulls: relay.NullsFirst}, {Field: "ID"}},
+			expected: []int{4, 8, 9, 3, 2, 7, 1, 6, 5, 10},
+		},
+		{
+			name:     "NullsLast",
+			orderBys: []relay.OrderBy{{Field: "Score", Nulls: relay.NullsLast}, {Field: "ID"}},
+			expected: []int{5, 10, 1, 6, 2, 7, 3, 9, 4, 8},
+		},
+	} {
+		for name, p := range paginations {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				require.Equal(t, tc.expected, walk(t, p, tc.orderBys, false))
+				require.Equal(t, tc.expected, walk(t, p, tc.orderBys, true))
+			})
+		}
+	}
+
+	// the items are not reordered in place
+	require.Equal(t, 1, nodes[0].ID)
+
+	// a cursor value which can't be compared
+	_, err := paginations["Keyset"].Paginate(context.Background(), &relay.PaginateRequest[*sliceNode]{
+		After:    lo.ToPtr(`{"ID":1,"Score":"1"}`),
+		First:    lo.ToPtr(3),
+		OrderBys: []relay.OrderBy{{Field: "Score"}, {Field: "ID"}},
+	})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+	require.ErrorContains(t, err, `field "Score" of float64 and string can't be compared`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = paginations["Offset"].Paginate(ctx, &relay.PaginateRequest[*sliceNode]{First: lo.ToPtr(3)})
+	require.ErrorIs(t, err, context.Canceled)
+}
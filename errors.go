@@ -0,0 +1,32 @@
+package relay
+
+import (
+	"fmt"
+)
+
+// MarkError returns an error with the message of err, which errors.Is matches with both err and sentinel,
+// e.g. for custom ApplyCursorsFuncs to mark their errors with ErrInvalidCursor.
+func MarkError(err error, sentinel error) error {
+	if err == nil {
+		return nil
+	}
+	return &markedError{err: err, sentinel: sentinel}
+}
+
+type markedError struct {
+	err      error
+	sentinel error
+}
+
+func (e *markedError) Error() string { return e.err.Error() }
+
+func (e *markedError) Unwrap() []error { return []error{e.err, e.sentinel} }
+
+// Format keeps the stack trace of err for `%+v`
+func (e *markedError) Format(s fmt.State, verb rune) {
+	if f, ok := e.err.(fmt.Formatter); ok {
+		f.Format(s, verb)
+		return
+	}
+	fmt.Fprintf(s, fmt.FormatString(s, verb), e.err)
+}
@@ -0,0 +1,77 @@
+package gormrelay
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Batch runs multiple paginations within one transaction and shares the count results between them,
+// e.g. several widgets of a dashboard against the same base table with different filters.
+type Batch struct {
+	db     *gorm.DB
+	mu     sync.Mutex
+	counts map[batchCountKey]batchCount
+}
+
+type batchCountKey struct {
+	sql        string
+	maxPKCount bool
+}
+
+type batchCount struct {
+	totalCount  int
+	approximate bool
+}
+
+// RunBatch starts a transaction and calls fc with a Batch bound to it.
+// Paginations created with b.DB() and WithBatch(b) count each distinct query only once.
+func RunBatch(ctx context.Context, db *gorm.DB, fc func(b *Batch) error, opts ...*sql.TxOptions) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fc(&Batch{
+			db:     tx,
+			counts: map[batchCountKey]batchCount{},
+		})
+	}, opts...)
+}
+
+// DB returns the transaction of the batch.
+func (b *Batch) DB() *gorm.DB {
+	return b.db
+}
+
+// WithBatch makes the counter share count results with the other paginations of b.
+func WithBatch[T any](b *Batch) Option[T] {
+	return func(o *options[T]) {
+		o.batch = b
+	}
+}
+
+func (b *Batch) count(db *gorm.DB, maxPKCount bool, fc func() (int, bool, error)) (int, bool, error) {
+	// The rendered count query (with vars) identifies the filter
+	var n int64
+	tx := db.Session(&gorm.Session{DryRun: true, Logger: logger.Discard}).Count(&n)
+	if tx.Error != nil {
+		return 0, false, errors.Wrap(tx.Error, "count")
+	}
+	key := batchCountKey{
+		sql:        tx.Dialector.Explain(tx.Statement.SQL.String(), tx.Statement.Vars...),
+		maxPKCount: maxPKCount,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.counts[key]; ok {
+		return c.totalCount, c.approximate, nil
+	}
+	totalCount, approximate, err := fc()
+	if err != nil {
+		return 0, false, err
+	}
+	b.counts[key] = batchCount{totalCount: totalCount, approximate: approximate}
+	return totalCount, approximate, nil
+}
@@ -0,0 +1,58 @@
+package gormrelay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBatch(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	err := RunBatch(context.Background(), db.Session(&gorm.Session{Logger: recorder}), func(b *Batch) error {
+		_, ok := b.DB().Statement.ConnPool.(gorm.TxCommitter)
+		require.True(t, ok, "batch should run in a transaction")
+
+		paginate := func(where string, orderBys []relay.OrderBy) *relay.PaginateResponse[*User] {
+			p := relay.New(
+				false,
+				10, 10,
+				orderBys,
+				NewKeysetAdapter(b.DB().Where(where), WithBatch[*User](b)),
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(5),
+			})
+			require.NoError(t, err)
+			return resp
+		}
+
+		resp := paginate("age > 50", []relay.OrderBy{{Field: "ID", Desc: false}})
+		require.Equal(t, 50, resp.PageInfo.TotalCount)
+		require.Equal(t, 1, resp.Edges[0].Node.ID)
+
+		// same filter with a different order shares the count
+		resp = paginate("age > 50", []relay.OrderBy{{Field: "Age", Desc: false}})
+		require.Equal(t, 50, resp.PageInfo.TotalCount)
+		require.Equal(t, 50, resp.Edges[0].Node.ID)
+
+		resp = paginate("age <= 50", []relay.OrderBy{{Field: "ID", Desc: false}})
+		require.Equal(t, 50, resp.PageInfo.TotalCount)
+		require.Equal(t, 51, resp.Edges[0].Node.ID)
+		return nil
+	})
+	require.NoError(t, err)
+
+	counts := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool {
+		return strings.Contains(sql, "count(*)")
+	})
+	require.Len(t, counts, 2)
+	require.Contains(t, counts[0], "age > 50")
+	require.Contains(t, counts[1], "age <= 50")
+}
@@ -0,0 +1,81 @@
+package gormrelay
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// timeBucketUnits are the units of date_trunc
+var timeBucketUnits = []string{"microseconds", "milliseconds", "second", "minute", "hour", "day", "week", "month", "quarter", "year"}
+
+type timeBucket struct {
+	column string
+	unit   string
+}
+
+// timeBucketExprs resolves the expressions of timeBuckets (field -> bucket) with the quoting and dialect of db
+func timeBucketExprs(db *gorm.DB, timeBuckets map[string]timeBucket) (map[string]string, error) {
+	if name := db.Dialector.Name(); name != "postgres" {
+		return nil, errors.Errorf("time buckets are not supported by %s", name)
+	}
+	exprs := make(map[string]string, len(timeBuckets))
+	for field, bucket := range timeBuckets {
+		exprs[field] = fmt.Sprintf("date_trunc('%s', %s)", bucket.unit, db.Statement.Quote(clause.Column{Name: bucket.column}))
+	}
+	return exprs, nil
+}
+
+// computeFields wraps db as `SELECT table.*, (expr) AS column ... FROM table` subquery for each of computedFields (field -> expr),
+// so that the expressions are computed once and referenced by their columns in the SELECT, ORDER BY and keyset conditions of the outer query.
+func computeFields(db *gorm.DB, computedFields map[string]string, timeBuckets map[string]timeBucket) (*gorm.DB, error) {
+	if len(timeBuckets) > 0 {
+		exprs, err := timeBucketExprs(db, timeBuckets)
+		if err != nil {
+			return nil, err
+		}
+		for field, expr := range computedFields {
+			exprs[field] = expr
+		}
+		computedFields = exprs
+	}
+
+	model := db.Statement.Model
+	if model == nil {
+		return nil, errors.New("model is nil")
+	}
+	if rv := reflect.ValueOf(model); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// A non-nil model is required for the subquery
+		model = reflect.New(rv.Type().Elem()).Interface()
+	}
+
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(computedFields))
+	for field := range computedFields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields) // stable SQL for prepared statements
+
+	selects := []string{db.Statement.Quote(s.Table) + ".*"}
+	for _, name := range fields {
+		field, ok := s.FieldsByName[name]
+		if !ok || field.DBName == "" {
+			return nil, errors.Errorf("missing field %q in schema", name)
+		}
+		selects = append(selects, "("+computedFields[name]+") AS "+db.Statement.Quote(field.DBName))
+	}
+
+	inner := db.Session(&gorm.Session{}).Model(model).Select(strings.Join(selects, ", "))
+
+	// Alias the subquery with the table name, so that references to the current table keep working
+	return db.Session(&gorm.Session{NewDB: true}).Model(model).Table("(?) AS "+db.Statement.Quote(s.Table), inner), nil
+}
@@ -0,0 +1,133 @@
+package gormrelay
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type scoredUser struct {
+	ID    int    `gorm:"primarykey;not null;" json:"id"`
+	Name  string `gorm:"not null;" json:"name"`
+	Age   int    `gorm:"not null;" json:"age"`
+	Score int    `gorm:"->;-:migration" json:"score"`
+}
+
+func (scoredUser) TableName() string { return "users" }
+
+func TestComputedField(t *testing.T) {
+	resetDB(t)
+
+	const expr = "age * 3 + id % 7"
+
+	expected := lo.RangeFrom(1, 100)
+	score := func(id int) int { return (101-id)*3 + id%7 }
+	sort.SliceStable(expected, func(i, j int) bool { return score(expected[i]) > score(expected[j]) })
+
+	orderBys := []relay.OrderBy{
+		{Field: "Score", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*scoredUser]) relay.ApplyCursorsFunc[*scoredUser]{
+		"Keyset": NewKeysetAdapter[*scoredUser],
+		"Offset": NewOffsetAdapter[*scoredUser],
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := newSQLRecorder()
+			p := relay.New(false, 10, 10, orderBys, f(
+				db.Session(&gorm.Session{Logger: recorder}).Where("age > ?", 20),
+				WithComputedField[*scoredUser]("Score", expr),
+			))
+
+			var ids []int
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*scoredUser]{First: lo.ToPtr(7)}) {
+				require.NoError(t, err)
+				require.Equal(t, 80, page.PageInfo.TotalCount)
+				for _, edge := range page.Edges {
+					require.Equal(t, score(edge.Node.ID), edge.Node.Score)
+					ids = append(ids, edge.Node.ID)
+				}
+			}
+			require.Equal(t, lo.Filter(expected, func(id int, _ int) bool { return id <= 80 }), ids)
+
+			finds := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(sql, "count(") })
+			require.NotEmpty(t, finds)
+			for _, sql := range finds {
+				// computed once in the subquery, referenced by the column in the outer query
+				require.Equal(t, 1, strings.Count(sql, expr), sql)
+			}
+		})
+	}
+
+	t.Run("MissingField", func(t *testing.T) {
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*scoredUser](db, WithComputedField[*scoredUser]("Rank", expr)))
+		_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*scoredUser]{First: lo.ToPtr(5)})
+		require.ErrorContains(t, err, `missing field "Rank" in schema`)
+	})
+}
+
+type hourlyEvent struct {
+	ID        int       `gorm:"primarykey;not null;"`
+	CreatedAt time.Time `gorm:"not null;"`
+	Hour      time.Time `gorm:"->;-:migration"`
+}
+
+func TestTimeBucket(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS hourly_events").Error)
+	require.NoError(t, db.AutoMigrate(&hourlyEvent{}))
+
+	// the ids don't follow the timestamps, so that rows are ordered by their buckets rather than the timestamps
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := make([]*hourlyEvent, 0, 50)
+	for i := 0; i < 50; i++ {
+		events = append(events, &hourlyEvent{ID: i + 1, CreatedAt: base.Add(time.Duration(i*37%300) * time.Minute)})
+	}
+	require.NoError(t, db.Create(events).Error)
+
+	orderBys := []relay.OrderBy{{Field: "Hour", Desc: true}, {Field: "ID", Desc: true}}
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*hourlyEvent](db, WithTimeBucket[*hourlyEvent]("Hour", "created_at", "hour")))
+	if db.Dialector.Name() != "postgres" {
+		_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*hourlyEvent]{First: lo.ToPtr(7)})
+		require.ErrorContains(t, err, "time buckets are not supported by "+db.Dialector.Name())
+		return
+	}
+
+	expected := slices.Clone(events)
+	sort.SliceStable(expected, func(i, j int) bool {
+		hi, hj := expected[i].CreatedAt.Truncate(time.Hour), expected[j].CreatedAt.Truncate(time.Hour)
+		if !hi.Equal(hj) {
+			return hi.After(hj)
+		}
+		return expected[i].ID > expected[j].ID
+	})
+
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*hourlyEvent]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.Equal(t, 50, page.PageInfo.TotalCount)
+		for _, edge := range page.Edges {
+			require.True(t, edge.Node.CreatedAt.Truncate(time.Hour).Equal(edge.Node.Hour), edge.Node.ID)
+			ids = append(ids, edge.Node.ID)
+		}
+		// the cursor encodes the bucket boundary and the tiebreak
+		keyset, err := cursor.DecodeKeysetCursor[*hourlyEvent](*page.PageInfo.EndCursor, []string{"Hour", "ID"})
+		require.NoError(t, err)
+		last := page.Edges[len(page.Edges)-1].Node
+		hour, err := time.Parse(time.RFC3339Nano, keyset["Hour"].(string))
+		require.NoError(t, err)
+		require.True(t, hour.Equal(last.CreatedAt.Truncate(time.Hour)))
+		require.EqualValues(t, last.ID, keyset["ID"])
+	}
+	require.Equal(t, lo.Map(expected, func(e *hourlyEvent, _ int) int { return e.ID }), ids)
+
+	require.PanicsWithValue(t, `unsupported time bucket unit "fortnight"`, func() { WithTimeBucket[*hourlyEvent]("Hour", "created_at", "fortnight") })
+}
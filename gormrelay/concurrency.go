@@ -0,0 +1,37 @@
+package gormrelay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// WithConcurrencyLimit limits the find and count executions running concurrently to n,
+// so that many paginations at once don't exhaust the connection pool. The executions beyond the limit wait for a slot until ctx is done.
+// The slots belong to the returned option, so they are shared by all the adapters built with it, and only by them.
+// To bound the paginations on a database handle, build all its adapters with the same option.
+func WithConcurrencyLimit[T any](n int) Option[T] {
+	if n <= 0 {
+		panic("concurrency limit must be greater than 0")
+	}
+	sem := make(chan struct{}, n)
+	return func(o *options[T]) {
+		o.concurrencyLimiter = sem
+	}
+}
+
+// limitConcurrency calls fn while holding a slot of sem if it's not nil
+func limitConcurrency[R any](ctx context.Context, sem chan struct{}, fn func() (R, error)) (R, error) {
+	if sem == nil {
+		return fn()
+	}
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		var zero R
+		return zero, errors.Wrap(ctx.Err(), "wait for concurrency limit")
+	}
+	defer func() { <-sem }()
+	return fn()
+}
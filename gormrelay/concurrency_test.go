@@ -0,0 +1,120 @@
+package gormrelay
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// intervalRecorder records the time span of each execution
+type intervalRecorder struct {
+	logger.Interface
+	mu        sync.Mutex
+	intervals [][2]time.Time
+}
+
+func (r *intervalRecorder) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	r.mu.Lock()
+	r.intervals = append(r.intervals, [2]time.Time{begin, time.Now()})
+	r.mu.Unlock()
+}
+
+// maxOverlap returns the max number of executions running at the same time
+func (r *intervalRecorder) maxOverlap() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type event struct {
+		at    time.Time
+		delta int
+	}
+	var events []event
+	for _, interval := range r.intervals {
+		events = append(events, event{interval[0], 1}, event{interval[1], -1})
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].at.Equal(events[j].at) {
+			return events[i].delta < events[j].delta
+		}
+		return events[i].at.Before(events[j].at)
+	})
+	n, result := 0, 0
+	for _, e := range events {
+		n += e.delta
+		result = max(result, n)
+	}
+	return result
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	resetDB(t)
+
+	recorder := &intervalRecorder{Interface: logger.Discard}
+	tx := db.Session(&gorm.Session{Logger: recorder})
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	limit := WithConcurrencyLimit[*User](1)
+	paginations := []relay.Pagination[*User]{
+		relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](tx, limit)),
+		relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](tx.Where("age > ?", 10), limit)),
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			p := paginations[i%len(paginations)]
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(3)})
+			if err == nil && len(resp.Edges) != 3 {
+				err = errors.Errorf("unexpected %d edges", len(resp.Edges))
+			}
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	require.GreaterOrEqual(t, len(recorder.intervals), 80)
+	require.Equal(t, 1, recorder.maxOverlap())
+
+	// waits for a slot until the context is done
+	release := make(chan struct{})
+	acquired := make(chan struct{})
+	go func() {
+		_, _ = limitConcurrency(context.Background(), newOptions([]Option[*User]{limit}).concurrencyLimiter, func() (any, error) {
+			close(acquired)
+			<-release
+			return nil, nil
+		})
+	}()
+	<-acquired
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := paginations[0].Paginate(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "wait for concurrency limit")
+
+	// the slots of another option are not shared
+	other := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](tx, WithConcurrencyLimit[*User](1)))
+	resp, err := other.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 3)
+
+	close(release)
+	resp, err = paginations[0].Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 3)
+
+	require.PanicsWithValue(t, "concurrency limit must be greater than 0", func() { WithConcurrencyLimit[*User](0) })
+}
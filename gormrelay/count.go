@@ -0,0 +1,277 @@
+package gormrelay
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+func count[T any](ctx context.Context, db *gorm.DB, opts *options[T]) (totalCount int, approximate bool, err error) {
+	totalCount, err = limitConcurrency(ctx, opts.concurrencyLimiter, func() (int, error) {
+		return retryTransient(ctx, opts.transientRetry, func() (int, error) {
+			var n int
+			n, approximate, err = countOnce[T](ctx, db, opts)
+			return n, err
+		})
+	})
+	return totalCount, approximate, err
+}
+
+func countOnce[T any](ctx context.Context, db *gorm.DB, opts *options[T]) (totalCount int, approximate bool, err error) {
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return 0, false, err
+	}
+
+	db = prepareDB(ctx, db, opts)
+
+	if !basedOnModel && db.Statement.Model == nil {
+		// A non-nil model is required if the statement ends up being used as a subquery
+		tType := reflect.TypeOf((*T)(nil)).Elem()
+		if tType.Kind() == reflect.Ptr {
+			tType = tType.Elem()
+		}
+		db = db.Model(reflect.New(tType).Interface())
+	}
+
+	if len(opts.distinctOn) > 0 {
+		// The count of distinct keys doesn't depend on which row is kept for each key
+		db, err = distinctOn(db, opts.distinctOn, nil)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	if opts.batch != nil {
+		return opts.batch.count(db, opts.maxPKCount, func() (int, bool, error) {
+			return countPrepared(db, &opts.countOptions)
+		})
+	}
+	return countPrepared(db, &opts.countOptions)
+}
+
+func countPrepared(db *gorm.DB, opts *countOptions) (totalCount int, approximate bool, err error) {
+	if opts.maxPKCount {
+		totalCount, ok, err := countByMaxPK(db)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			return totalCount, true, nil
+		}
+	}
+
+	db = withoutPaging(db)
+
+	distinctColumn, err := many2ManyDistinctColumn(db)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if hasDistinctOn(db.Statement) {
+		// DISTINCT ON picks rows based on ORDER BY, so keep the ordering and count the result set as a subquery.
+		// Otherwise gorm's Count replaces the select list and drops ORDER BY, which is also what we want for plain counts.
+		db = db.Session(&gorm.Session{NewDB: true}).Table("(?) AS t", db)
+	} else if _, ok := db.Statement.Clauses["GROUP BY"]; ok {
+		// Count the groups in the database, instead of fetching a row per group as gorm's Count does,
+		// HAVING is a part of the GROUP BY clause, so it's kept in the subquery
+		db = db.Session(&gorm.Session{NewDB: true}).Table("(?) AS t", db)
+	} else if db.Statement.Distinct {
+		// gorm's Count ignores DISTINCT of no or multiple columns, and skips NULL of a single column,
+		// so count the distinct rows of the query as a subquery
+		db = db.Session(&gorm.Session{NewDB: true}).Table("(?) AS t", db)
+	} else if distinctColumn != "" {
+		// gorm's Count emits `COUNT(DISTINCT(column))` for a single distinct column
+		db = db.Session(&gorm.Session{}).Distinct(distinctColumn)
+	} else if opts.countColumn != "" && len(db.Statement.Selects) == 0 {
+		if err := checkCountColumn(db, opts.countColumn); err != nil {
+			return 0, false, err
+		}
+		// gorm's Count emits `COUNT(column)` for a single selected column
+		db = db.Session(&gorm.Session{}).Select(opts.countColumn)
+	}
+	var n int64
+	if err := db.Count(&n).Error; err != nil {
+		return 0, false, errors.Wrap(err, "count")
+	}
+	return int(n), false, nil
+}
+
+// withoutPaging clones db without the clauses which don't change the rows to count, but gorm's Count keeps,
+// i.e. LIMIT and OFFSET, ORDER BY unless DISTINCT ON picks rows by it, and the select list unless the rows are grouped or distinct,
+// e.g. `users.*` which gorm's Count would emit as `COUNT("users"."*")`. JOIN and WHERE are kept.
+func withoutPaging(db *gorm.DB) *gorm.DB {
+	db = db.Session(&gorm.Session{}).Clauses() // clones the statement, so that deleting clauses doesn't affect db
+	stmt := db.Statement
+	delete(stmt.Clauses, "LIMIT")
+	distinctOn := hasDistinctOn(stmt)
+	if !distinctOn {
+		delete(stmt.Clauses, "ORDER BY")
+	}
+	if _, grouped := stmt.Clauses["GROUP BY"]; grouped || distinctOn || stmt.Distinct || len(stmt.Selects) == 0 {
+		return db
+	}
+	if selects := strings.ToUpper(strings.TrimSpace(stmt.Selects[0])); strings.HasPrefix(selects, "DISTINCT") || strings.Contains(selects, "COUNT(") {
+		return db
+	}
+	stmt.Selects = nil
+	delete(stmt.Clauses, "SELECT")
+	return db
+}
+
+// checkCountColumn makes sure counting column doesn't skip rows with NULL
+func checkCountColumn(db *gorm.DB, column string) error {
+	s, err := parseSchema(db, db.Statement.Model)
+	if err != nil {
+		return err
+	}
+	field := s.LookUpField(column)
+	if field == nil {
+		return errors.Errorf("count column %q not found in schema", column)
+	}
+	if !field.NotNull && !field.PrimaryKey {
+		return errors.Errorf("count column %q must be NOT NULL", column)
+	}
+	return nil
+}
+
+// buildCountStatement builds the count statement without executing it,
+// to see all clauses, including those added by joins, scopes or soft delete
+func buildCountStatement(db *gorm.DB) (*gorm.Statement, error) {
+	var n int64
+	tx := db.Session(&gorm.Session{DryRun: true, Logger: logger.Discard}).Count(&n)
+	if tx.Error != nil {
+		return nil, errors.Wrap(tx.Error, "count")
+	}
+	return tx.Statement, nil
+}
+
+// many2ManyDistinctColumn returns the qualified primary key of the model if the query joins a many2many join table of it,
+// each row of the model is repeated for each associated row then, so the primary key has to be counted distinctly.
+// It returns "" if there is no such join or the query selects columns itself.
+func many2ManyDistinctColumn(db *gorm.DB) (string, error) {
+	stmt := db.Statement
+	if len(stmt.Selects) > 0 || stmt.Distinct || hasDistinctOn(stmt) {
+		return "", nil
+	}
+
+	var joins []clause.Join
+	if c, ok := stmt.Clauses["FROM"]; ok {
+		if from, ok := c.Expression.(clause.From); ok {
+			joins = append(joins, from.Joins...)
+		}
+	}
+	for _, join := range stmt.Joins {
+		// joins by association names are never many2many
+		joins = append(joins, clause.Join{Expression: clause.NamedExpr{SQL: join.Name}})
+	}
+	if len(joins) == 0 {
+		return "", nil
+	}
+
+	s, err := parseSchema(db, stmt.Model)
+	if err != nil {
+		return "", err
+	}
+	if len(s.Relationships.Many2Many) == 0 || s.PrioritizedPrimaryField == nil {
+		return "", nil
+	}
+	for _, join := range joins {
+		for _, rel := range s.Relationships.Many2Many {
+			if joinsTable(join, rel.JoinTable.Table) {
+				table := s.Table
+				if stmt.Table != "" {
+					table = stmt.Table
+				}
+				return table + "." + s.PrioritizedPrimaryField.DBName, nil
+			}
+		}
+	}
+	return "", nil
+}
+
+func joinsTable(join clause.Join, table string) bool {
+	if join.Table.Name == table {
+		return true
+	}
+	var sql string
+	switch expr := join.Expression.(type) {
+	case clause.NamedExpr:
+		sql = expr.SQL
+	case clause.Expr:
+		sql = expr.SQL
+	default:
+		return false
+	}
+	return slices.ContainsFunc(qualifiedIdentifierRegexp.FindAllString(sql, -1), func(identifier string) bool {
+		return strings.Contains("."+identifier+".", "."+table+".")
+	})
+}
+
+// qualifiedIdentifierRegexp matches the unquoted identifiers in SQL with their qualifiers, e.g. `user_roles.user_id`
+var qualifiedIdentifierRegexp = regexp.MustCompile(`[\w$]+(\.[\w$]+)*`)
+
+// countByMaxPK estimates the count with the range of the integer primary key.
+// It reports false if the query is not a plain full table query.
+func countByMaxPK(db *gorm.DB) (int, bool, error) {
+	var n int64
+	stmt, err := buildCountStatement(db)
+	if err != nil {
+		return 0, false, err
+	}
+	if stmt.Schema == nil || stmt.Distinct || hasDistinctOn(stmt) {
+		return 0, false, nil
+	}
+	if stmt.TableExpr != nil && stmt.TableExpr.SQL != stmt.Quote(stmt.Table) {
+		return 0, false, nil
+	}
+	for _, name := range []string{"WHERE", "GROUP BY"} {
+		if _, ok := stmt.Clauses[name]; ok {
+			return 0, false, nil
+		}
+	}
+	if c, ok := stmt.Clauses["FROM"]; ok {
+		if from, ok := c.Expression.(clause.From); ok && len(from.Joins) > 0 {
+			return 0, false, nil
+		}
+	}
+	pk := stmt.Schema.PrioritizedPrimaryField
+	if pk == nil || (pk.DataType != schema.Int && pk.DataType != schema.Uint) {
+		return 0, false, nil
+	}
+
+	column := clause.Column{Table: clause.CurrentTable, Name: pk.DBName}
+	db = db.Session(&gorm.Session{}).Select("COALESCE(MAX(?) - MIN(?) + 1, 0)", column, column)
+	delete(db.Statement.Clauses, "ORDER BY")
+	delete(db.Statement.Clauses, "LIMIT")
+	if err := db.Scan(&n).Error; err != nil {
+		return 0, false, errors.Wrap(err, "count by max pk")
+	}
+	return int(n), true, nil
+}
+
+func hasDistinctOn(stmt *gorm.Statement) bool {
+	isDistinctOn := func(sql string) bool {
+		return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(sql)), "DISTINCT ON")
+	}
+	if len(stmt.Selects) > 0 && isDistinctOn(stmt.Selects[0]) {
+		return true
+	}
+	if c, ok := stmt.Clauses["SELECT"]; ok {
+		switch expr := c.Expression.(type) {
+		case clause.Expr:
+			return isDistinctOn(expr.SQL)
+		case clause.NamedExpr:
+			return isDistinctOn(expr.SQL)
+		}
+	}
+	return false
+}
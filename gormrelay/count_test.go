@@ -0,0 +1,612 @@
+package gormrelay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+func TestCountDistinctOn(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET name = 'name' || (id % 10)").Error)
+
+	{
+		recorder := newSQLRecorder()
+		tx := db.Session(&gorm.Session{Logger: recorder}).Order("age DESC")
+		totalCount, err := NewKeysetCounter[*User](tx).Count(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 100, totalCount)
+		require.Equal(t, []string{`SELECT count(*) FROM "users"`}, recorder.SQLs())
+	}
+
+	{
+		recorder := newSQLRecorder()
+		tx := db.Session(&gorm.Session{Logger: recorder}).Select("DISTINCT ON (name) *").Order("name, age DESC")
+		totalCount, err := NewKeysetCounter[*User](tx).Count(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 10, totalCount)
+		require.Equal(t, []string{`SELECT count(*) FROM (SELECT DISTINCT ON (name) * FROM "users" ORDER BY name, age DESC) AS t`}, recorder.SQLs())
+
+		totalCount, err = NewOffsetCounter[*User](tx).Count(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 10, totalCount)
+	}
+}
+
+type ageBucket struct {
+	Bucket int
+	N      int
+}
+
+func TestCountGroupedAndDistinct(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET name = 'name' || (id % 3), age = id % 7").Error)
+
+	// ages 0-6 with 14 or 15 users each, and names 0-2
+	grouped := func() *gorm.DB { return db.Table("users").Select("age AS bucket, COUNT(*) AS n").Group("age") }
+	for _, tc := range []struct {
+		name     string
+		db       *gorm.DB
+		expected int
+		// kept in the subquery
+		inner string
+	}{
+		{
+			name:     "GroupBy",
+			db:       grouped(),
+			expected: 7,
+			inner:    "GROUP BY",
+		},
+		{
+			name:     "Having",
+			db:       grouped().Having("COUNT(*) > ?", 14),
+			expected: 2,
+			inner:    "HAVING COUNT(*) > 14",
+		},
+		{
+			name:     "DistinctColumns",
+			db:       db.Table("users").Distinct("age AS bucket", "name"),
+			expected: 21,
+			inner:    "SELECT DISTINCT age AS bucket,",
+		},
+		{
+			name:     "DistinctColumn",
+			db:       db.Table("users").Distinct("age AS bucket").Where("id > ?", 50),
+			expected: 7,
+			inner:    "SELECT DISTINCT age AS bucket FROM",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := newSQLRecorder()
+			tx := tc.db.Session(&gorm.Session{Logger: recorder})
+			totalCount, err := NewOffsetCounter[*ageBucket](tx).Count(context.Background())
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, totalCount)
+			sqls := recorder.SQLs()
+			require.Len(t, sqls, 1)
+			require.Regexp(t, `^SELECT count\(\*\) FROM \(SELECT .+\) AS t$`, sqls[0])
+			require.Contains(t, sqls[0], tc.inner)
+
+			// TotalCount is the number of result rows while paginating them
+			p := relay.New(false, 10, 3, []relay.OrderBy{{Field: "Bucket"}}, NewOffsetAdapter[*ageBucket](tc.db))
+			var n int
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*ageBucket]{First: lo.ToPtr(3)}) {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, page.PageInfo.TotalCount)
+				n += len(page.Edges)
+			}
+			require.Equal(t, tc.expected, n)
+		})
+	}
+}
+
+func TestMaxPKCount(t *testing.T) {
+	resetDB(t)
+
+	paginate := func(t *testing.T, db *gorm.DB, opts ...Option[*User]) (relay.PageInfo, []string) {
+		recorder := newSQLRecorder()
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			NewKeysetAdapter[*User](db.Session(&gorm.Session{Logger: recorder}), opts...),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		// the count queries only
+		return resp.PageInfo, lo.Filter(recorder.SQLs(), func(sql string, _ int) bool {
+			return !strings.HasPrefix(sql, "SELECT *")
+		})
+	}
+
+	t.Run("GapFree", func(t *testing.T) {
+		pageInfo, sqls := paginate(t, db)
+		require.Equal(t, 100, pageInfo.TotalCount)
+		require.False(t, pageInfo.TotalCountApproximate)
+		require.Contains(t, sqls[0], "count(*)")
+
+		pageInfo, sqls = paginate(t, db, WithMaxPKCount[*User]())
+		require.Equal(t, 100, pageInfo.TotalCount)
+		require.True(t, pageInfo.TotalCountApproximate)
+		require.Contains(t, sqls[0], "MAX(")
+		require.NotContains(t, sqls[0], "count(*)")
+	})
+
+	t.Run("Filtered", func(t *testing.T) {
+		pageInfo, sqls := paginate(t, db.Where("age > ?", 50), WithMaxPKCount[*User]())
+		require.Equal(t, 50, pageInfo.TotalCount)
+		require.False(t, pageInfo.TotalCountApproximate)
+		require.Contains(t, sqls[0], "count(*)")
+	})
+
+	t.Run("Gapped", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM users WHERE id > 10 AND id <= 20").Error)
+
+		pageInfo, _ := paginate(t, db)
+		require.Equal(t, 90, pageInfo.TotalCount)
+		require.False(t, pageInfo.TotalCountApproximate)
+
+		// gaps can't be detected cheaply, so the estimate is flagged as approximate
+		pageInfo, _ = paginate(t, db, WithMaxPKCount[*User]())
+		require.Equal(t, 100, pageInfo.TotalCount)
+		require.True(t, pageInfo.TotalCountApproximate)
+	})
+
+	t.Run("GappedOffset", func(t *testing.T) {
+		require.NoError(t, db.Exec("DELETE FROM users WHERE id > 10 AND id <= 20").Error)
+		ids := func(resp *relay.PaginateResponse[*User]) []int {
+			return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+		}
+
+		for _, windowed := range []bool{false, true} {
+			adapter := NewOffsetAdapter(db, WithMaxPKCount[*User]())
+			if windowed {
+				adapter = cursor.NewOffsetAdapter(NewOffsetCounterWindowed(db, WithMaxPKCount[*User]()))
+			}
+			p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, adapter)
+
+			// the last page is located by the exact count, not by the estimate
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(5)})
+			require.NoError(t, err)
+			require.Equal(t, []int{96, 97, 98, 99, 100}, ids(resp))
+			require.Equal(t, 90, resp.PageInfo.TotalCount)
+			require.False(t, resp.PageInfo.TotalCountApproximate)
+			require.True(t, resp.PageInfo.HasPreviousPage)
+			require.False(t, resp.PageInfo.HasNextPage)
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("84"), First: lo.ToPtr(10)})
+			require.NoError(t, err)
+			require.Equal(t, []int{96, 97, 98, 99, 100}, ids(resp))
+			require.False(t, resp.PageInfo.HasNextPage)
+			// the rows carry the exact count of the window
+			require.Equal(t, lo.Ternary(windowed, 90, 100), resp.PageInfo.TotalCount)
+			require.Equal(t, !windowed, resp.PageInfo.TotalCountApproximate)
+
+			// beyond the end, the estimate is still flagged as approximate
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("94"), First: lo.ToPtr(10)})
+			require.NoError(t, err)
+			require.Empty(t, resp.Edges)
+			require.Equal(t, 100, resp.PageInfo.TotalCount)
+			require.True(t, resp.PageInfo.TotalCountApproximate)
+		}
+	})
+}
+
+func TestCountLoadShedder(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]) {
+		overloaded := false
+		recorder := newSQLRecorder()
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			f(
+				db.Session(&gorm.Session{Logger: recorder}),
+				WithCountLoadShedder[*User](func() bool { return overloaded }),
+			),
+		)
+
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 100, resp.PageInfo.TotalCount)
+		require.False(t, resp.PageInfo.TotalCountSkipped)
+		require.Len(t, recorder.SQLs(), 2)
+
+		overloaded = true
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 1, resp.Edges[0].Node.ID)
+		require.True(t, resp.PageInfo.HasNextPage)
+		require.Zero(t, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.TotalCountSkipped)
+		require.Len(t, recorder.SQLs(), 3)
+		require.NotContains(t, recorder.SQLs()[2], "count(*)")
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	t.Run("offset: count is required for last page", func(t *testing.T) {
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			NewOffsetAdapter(db, WithCountLoadShedder[*User](func() bool { return true })),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 91, resp.Edges[0].Node.ID)
+		require.Equal(t, 100, resp.PageInfo.TotalCount)
+		require.False(t, resp.PageInfo.TotalCountSkipped)
+	})
+}
+
+func TestSkipTotalCountRequest(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User], after string) {
+		recorder := newSQLRecorder()
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			f(db.Session(&gorm.Session{Logger: recorder})),
+		)
+
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:          lo.ToPtr(after),
+			First:          lo.ToPtr(10),
+			SkipTotalCount: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 11, resp.Edges[0].Node.ID)
+		require.True(t, resp.PageInfo.HasNextPage)
+		require.True(t, resp.PageInfo.HasPreviousPage)
+		require.Zero(t, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.TotalCountSkipped)
+		require.Nil(t, resp.TotalPages)
+		require.Len(t, recorder.SQLs(), 1)
+		require.NotContains(t, recorder.SQLs()[0], "count(*)")
+
+		// the last page still knows there is no next page
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last:           lo.ToPtr(10),
+			SkipTotalCount: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 91, resp.Edges[0].Node.ID)
+		require.False(t, resp.PageInfo.HasNextPage)
+		require.True(t, resp.PageInfo.HasPreviousPage)
+
+		// unset, the count is queried as before
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(after),
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 100, resp.PageInfo.TotalCount)
+		require.False(t, resp.PageInfo.TotalCountSkipped)
+	}
+
+	t.Run("keyset", func(t *testing.T) {
+		testCase(t, NewKeysetAdapter, mustEncodeKeysetCursor(&User{ID: 10}, []string{"ID"}))
+	})
+	t.Run("offset", func(t *testing.T) {
+		testCase(t, NewOffsetAdapter, cursor.EncodeOffsetCursor(9))
+	})
+
+	t.Run("offset: count is required for last page", func(t *testing.T) {
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			NewOffsetAdapter[*User](db),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Last:           lo.ToPtr(10),
+			SkipTotalCount: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 100, resp.PageInfo.TotalCount)
+		require.False(t, resp.PageInfo.TotalCountSkipped)
+	})
+
+	t.Run("short first page", func(t *testing.T) {
+		resp, err := relay.New(
+			false,
+			200, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			NewKeysetAdapter[*User](db),
+		).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:          lo.ToPtr(200),
+			SkipTotalCount: true,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 100)
+		require.False(t, resp.PageInfo.HasNextPage)
+		require.Zero(t, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.TotalCountSkipped)
+	})
+}
+
+func TestCountSkippedOnShortFirstPage(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DELETE FROM users WHERE id > 7").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User], req *relay.PaginateRequest[*User], expectedCount bool) {
+		recorder := newSQLRecorder()
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			f(db.Session(&gorm.Session{Logger: recorder})),
+		)
+		resp, err := p.Paginate(context.Background(), req)
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 7)
+		require.Equal(t, 7, resp.PageInfo.TotalCount)
+		require.False(t, resp.PageInfo.HasNextPage)
+		require.False(t, resp.PageInfo.HasPreviousPage)
+
+		counts := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool {
+			return strings.Contains(sql, "count(*)")
+		})
+		if expectedCount {
+			require.Len(t, counts, 1)
+		} else {
+			require.Empty(t, counts)
+			require.Len(t, recorder.SQLs(), 1)
+		}
+	}
+
+	t.Run("keyset first", func(t *testing.T) {
+		testCase(t, NewKeysetAdapter, &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}, false)
+	})
+	t.Run("keyset last", func(t *testing.T) {
+		testCase(t, NewKeysetAdapter, &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)}, false)
+	})
+	t.Run("offset first", func(t *testing.T) {
+		testCase(t, NewOffsetAdapter, &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}, false)
+	})
+	t.Run("offset last", func(t *testing.T) {
+		// the count is required to locate the last page
+		testCase(t, NewOffsetAdapter, &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)}, true)
+	})
+	t.Run("keyset with after", func(t *testing.T) {
+		// not the first page, rows before after are unknown
+		recorder := newSQLRecorder()
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			NewKeysetAdapter[*User](db.Session(&gorm.Session{Logger: recorder})),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 2}, []string{"ID"})),
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 5)
+		require.Equal(t, 7, resp.PageInfo.TotalCount)
+		require.Contains(t, recorder.SQLs()[0], "count(*)")
+	})
+}
+
+type userWithNullableAge struct {
+	ID   int
+	Name string
+	Age  *int
+}
+
+func (userWithNullableAge) TableName() string {
+	return "users"
+}
+
+func TestCountColumn(t *testing.T) {
+	resetDB(t)
+
+	for _, tx := range []*gorm.DB{db, db.Where("age > ?", 50)} {
+		expected, err := NewKeysetCounter[*User](tx).Count(context.Background())
+		require.NoError(t, err)
+
+		for _, f := range []func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]{NewKeysetAdapter, NewOffsetAdapter} {
+			recorder := newSQLRecorder()
+			p := relay.New(
+				false,
+				10, 10,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				},
+				f(tx.Session(&gorm.Session{Logger: recorder}), WithCountColumn[*User]("age")),
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Equal(t, expected, resp.PageInfo.TotalCount)
+
+			counts := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool {
+				return strings.Contains(sql, "COUNT(")
+			})
+			require.Len(t, counts, 1)
+			require.Contains(t, counts[0], fmt.Sprintf("COUNT(%s)", db.Statement.Quote("age")))
+		}
+	}
+
+	// the field name works as well
+	totalCount, err := NewKeysetCounter[*User](db, WithCountColumn[*User]("Age")).Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 100, totalCount)
+
+	// NULLs would be undercounted
+	_, err = NewKeysetCounter[*userWithNullableAge](db, WithCountColumn[*userWithNullableAge]("age")).Count(context.Background())
+	require.ErrorContains(t, err, `count column "age" must be NOT NULL`)
+
+	_, err = NewKeysetCounter[*User](db, WithCountColumn[*User]("not_exists")).Count(context.Background())
+	require.ErrorContains(t, err, `count column "not_exists" not found in schema`)
+}
+
+type Role struct {
+	ID   int    `gorm:"primarykey;not null;" json:"id"`
+	Name string `gorm:"not null;" json:"name"`
+}
+
+type userWithRoles struct {
+	ID    int     `gorm:"primarykey;not null;"`
+	Name  string  `gorm:"not null;"`
+	Age   int     `gorm:"not null;"`
+	Roles []*Role `gorm:"many2many:user_roles;joinForeignKey:UserID;joinReferences:RoleID"`
+}
+
+func (userWithRoles) TableName() string {
+	return "users"
+}
+
+func TestCountMany2ManyJoin(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS user_roles").Error)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS roles").Error)
+	require.NoError(t, db.AutoMigrate(&userWithRoles{}))
+
+	roles := []*Role{{ID: 1, Name: "admin"}, {ID: 2, Name: "editor"}, {ID: 3, Name: "viewer"}}
+	require.NoError(t, db.Create(roles).Error)
+	var users []*userWithRoles
+	require.NoError(t, db.Find(&users).Error)
+	for _, u := range users {
+		// users have 1 to 3 roles
+		require.NoError(t, db.Model(u).Association("Roles").Append(roles[:u.ID%3+1]))
+	}
+
+	joined := func(roleNames ...string) *gorm.DB {
+		return db.Model(&userWithRoles{}).
+			Joins("JOIN user_roles ON user_roles.user_id = users.id").
+			Joins("JOIN roles ON roles.id = user_roles.role_id").
+			Where("roles.name IN ?", roleNames)
+	}
+
+	var pairs, distinct int64
+	require.NoError(t, joined("editor", "viewer").Count(&pairs).Error)
+	require.NoError(t, joined("editor", "viewer").Distinct("users.id").Count(&distinct).Error)
+	require.Greater(t, pairs, distinct)
+
+	recorder := newSQLRecorder()
+	totalCount, err := NewKeysetCounter[*userWithRoles](joined("editor", "viewer").Session(&gorm.Session{Logger: recorder})).Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int(distinct), totalCount)
+	require.Len(t, recorder.SQLs(), 1)
+	require.Contains(t, recorder.SQLs()[0], "DISTINCT")
+
+	// the many2many join table is detected in joins by table as well
+	totalCount, err = NewKeysetCounter[*userWithRoles](db.Model(&userWithRoles{}).Clauses(clause.From{
+		Joins: []clause.Join{{
+			Table: clause.Table{Name: "user_roles"},
+			ON:    clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "user_roles.user_id = users.id AND user_roles.role_id = ?", Vars: []any{2}}}},
+		}},
+	})).Count(context.Background())
+	require.NoError(t, err)
+	var expected int64
+	require.NoError(t, db.Table("user_roles").Where("role_id = ?", 2).Count(&expected).Error)
+	require.Equal(t, int(expected), totalCount)
+
+	// paginating users with the role editor, whose rows are not repeated
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*userWithRoles](
+		db.Joins("JOIN user_roles ON user_roles.user_id = users.id AND user_roles.role_id = ?", 2),
+	))
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*userWithRoles]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.Equal(t, int(expected), page.PageInfo.TotalCount)
+		for _, edge := range page.Edges {
+			ids = append(ids, edge.Node.ID)
+		}
+	}
+	require.Len(t, ids, int(expected))
+
+	// other joins are counted as they are
+	recorder = newSQLRecorder()
+	_, err = NewKeysetCounter[*User](db.Session(&gorm.Session{Logger: recorder}).Joins("JOIN roles ON roles.id = users.id")).Count(context.Background())
+	require.NoError(t, err)
+	require.NotContains(t, recorder.SQLs()[0], "DISTINCT")
+}
+
+func TestCountJoinFiltered(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS user_tags").Error)
+	require.NoError(t, db.AutoMigrate(&userTag{}))
+	var tags []*userTag
+	for id := 3; id <= 100; id += 3 {
+		tags = append(tags, &userTag{UserID: id, Tag: "b"})
+	}
+	require.NoError(t, db.Create(tags).Error)
+
+	// the users tagged with b and older than 20, selecting the columns of users only as usual with joins
+	joined := func(db *gorm.DB) *gorm.DB {
+		return db.Select("users.*").
+			Joins("JOIN user_tags ON user_tags.user_id = users.id AND user_tags.tag = ?", "b").
+			Where("users.age > ?", 20)
+	}
+	expected := lo.Filter(lo.RangeFrom(1, 100), func(id int, _ int) bool { return id%3 == 0 && 101-id > 20 })
+
+	recorder := newSQLRecorder()
+	totalCount, err := NewKeysetCounter[*User](joined(db.Session(&gorm.Session{Logger: recorder})).Order("users.age").Limit(5).Offset(3)).Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, len(expected), totalCount)
+	require.Len(t, recorder.SQLs(), 1)
+	sql := strings.ToUpper(recorder.SQLs()[0])
+	require.Contains(t, sql, "JOIN USER_TAGS")
+	require.Contains(t, sql, "WHERE")
+	for _, clause := range []string{"ORDER BY", "LIMIT", "OFFSET", "USERS.*"} {
+		require.NotContains(t, sql, clause)
+	}
+
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](joined(db)))
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.Equal(t, len(expected), page.PageInfo.TotalCount)
+		for _, edge := range page.Edges {
+			ids = append(ids, edge.Node.ID)
+		}
+	}
+	require.Equal(t, expected, ids)
+}
@@ -0,0 +1,34 @@
+package gormrelay
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// onlyDeleted narrows db to the soft deleted rows, i.e. `deleted_at IS NOT NULL` instead of the `deleted_at IS NULL` gorm adds by default
+func onlyDeleted[T any](db *gorm.DB) *gorm.DB {
+	s, err := schemaOf[T](db)
+	if err != nil {
+		_ = db.AddError(err)
+		return db
+	}
+	field := softDeleteField(s)
+	if field == nil {
+		_ = db.AddError(errors.Errorf("no soft delete field in schema %s", s.Name))
+		return db
+	}
+	column := clause.Column{Table: clause.CurrentTable, Name: field.DBName}
+	return db.Unscoped().Where(clause.Expr{SQL: "? IS NOT NULL", Vars: []any{column}})
+}
+
+// softDeleteField returns the field of gorm.DeletedAt, whose query clause excludes the soft deleted rows
+func softDeleteField(s *schema.Schema) *schema.Field {
+	for _, c := range s.QueryClauses {
+		if c, ok := c.(gorm.SoftDeleteQueryClause); ok {
+			return c.Field
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package gormrelay
+
+import (
+	"context"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type note struct {
+	ID        int    `gorm:"primarykey;not null;"`
+	Title     string `gorm:"not null;"`
+	DeletedAt gorm.DeletedAt
+}
+
+func TestOnlyDeleted(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS notes").Error)
+	require.NoError(t, db.AutoMigrate(&note{}))
+	var notes []*note
+	for id := 1; id <= 30; id++ {
+		notes = append(notes, &note{ID: id, Title: "note"})
+	}
+	require.NoError(t, db.Create(notes).Error)
+	require.NoError(t, db.Where("id % 4 = 0").Delete(&note{}).Error)
+
+	deleted := lo.Filter(lo.RangeFrom(1, 30), func(id int, _ int) bool { return id%4 == 0 })
+	kept := lo.Without(lo.RangeFrom(1, 30), deleted...)
+
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	paginate := func(t *testing.T, p *relay.Paginator[*note], expected []int) {
+		var ids []int
+		for page, err := range p.All(context.Background(), &relay.PaginateRequest[*note]{First: lo.ToPtr(3)}) {
+			require.NoError(t, err)
+			require.Equal(t, len(expected), page.PageInfo.TotalCount)
+			for _, edge := range page.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+		}
+		require.Equal(t, expected, ids)
+	}
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*note]) relay.ApplyCursorsFunc[*note]{
+		"Keyset": NewKeysetAdapter[*note],
+		"Offset": NewOffsetAdapter[*note],
+	} {
+		t.Run(name, func(t *testing.T) {
+			paginate(t, relay.New(false, 10, 10, orderBys, f(db, WithOnlyDeleted[*note]())), deleted)
+			// the filters of db still apply
+			paginate(t, relay.New(false, 10, 10, orderBys, f(db.Where("id > ?", 10), WithOnlyDeleted[*note]())), lo.Filter(deleted, func(id int, _ int) bool { return id > 10 }))
+			// and the soft deleted rows are excluded as usual without it
+			paginate(t, relay.New(false, 10, 10, orderBys, f(db)), kept)
+		})
+	}
+
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, WithOnlyDeleted[*User]()))
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.ErrorContains(t, err, "no soft delete field in schema User")
+}
@@ -0,0 +1,53 @@
+package gormrelay
+
+import (
+	"reflect"
+	"strings"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// distinctOn wraps db as `SELECT DISTINCT ON (columns) * ... ORDER BY columns, orderBys` subquery,
+// so that the row kept for each distinct key is the first one by orderBys,
+// and keyset conditions and ordering can be applied to the deduplicated rows by the outer query.
+func distinctOn(db *gorm.DB, columns []string, orderBys []relay.OrderBy) (*gorm.DB, error) {
+	model := db.Statement.Model
+	if model == nil {
+		return nil, errors.New("model is nil")
+	}
+	if rv := reflect.ValueOf(model); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// A non-nil model is required for the subquery
+		model = reflect.New(rv.Type().Elem()).Interface()
+	}
+
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	orderByColumns := make([]clause.OrderByColumn, 0, len(columns)+len(orderBys))
+	for _, column := range columns {
+		orderByColumns = append(orderByColumns, clause.OrderByColumn{Column: clause.Column{Name: column}})
+	}
+	for _, orderBy := range orderBys {
+		field, ok := s.FieldsByName[orderBy.Field]
+		if !ok {
+			return nil, errors.Errorf("missing field %q in schema", orderBy.Field)
+		}
+		orderByColumns = append(orderByColumns, orderByColumn(db.Statement, clause.Column{Name: field.DBName}, orderBy, false))
+	}
+
+	quoted := lo.Map(columns, func(column string, _ int) string {
+		return db.Statement.Quote(clause.Column{Name: column})
+	})
+	inner := db.Session(&gorm.Session{}).Model(model).
+		Select("DISTINCT ON (" + strings.Join(quoted, ",") + ") *").
+		Order(clause.OrderBy{Columns: orderByColumns})
+
+	// Alias the subquery with the table name, so that references to the current table keep working
+	return db.Session(&gorm.Session{NewDB: true}).Model(model).Table("(?) AS "+s.Table, inner), nil
+}
@@ -0,0 +1,84 @@
+package gormrelay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type Post struct {
+	ID        int       `gorm:"primarykey;not null;" json:"id"`
+	AuthorID  int       `gorm:"index;not null;" json:"authorId"`
+	CreatedAt time.Time `gorm:"not null;" json:"createdAt"`
+}
+
+func TestDistinctOn(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS posts").Error)
+	require.NoError(t, db.AutoMigrate(&Post{}))
+
+	// 5 authors with 4 posts each, the latest post of author i is created at base + i*10m + 3m
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	posts := []*Post{}
+	for i := 1; i <= 5; i++ {
+		for j := 0; j < 4; j++ {
+			posts = append(posts, &Post{
+				AuthorID:  i,
+				CreatedAt: base.Add(time.Duration(i*10+j) * time.Minute),
+			})
+		}
+	}
+	require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).Create(posts).Error)
+
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "CreatedAt", Desc: true},
+			{Field: "ID", Desc: true},
+		},
+		NewKeysetAdapter(db, WithDistinctOn[*Post]("author_id")),
+	)
+
+	var authorIDs []int
+	var createdAts []time.Time
+	var after *string
+	for {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*Post]{
+			After: after,
+			First: lo.ToPtr(2),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 5, resp.PageInfo.TotalCount)
+		for _, edge := range resp.Edges {
+			authorIDs = append(authorIDs, edge.Node.AuthorID)
+			createdAts = append(createdAts, edge.Node.CreatedAt)
+			// cursors only encode the ordering columns
+			_, err := cursor.DecodeKeysetCursor[*Post](edge.Cursor, []string{"CreatedAt", "ID"})
+			require.NoError(t, err)
+		}
+		if !resp.PageInfo.HasNextPage {
+			break
+		}
+		after = resp.PageInfo.EndCursor
+	}
+	require.Equal(t, []int{5, 4, 3, 2, 1}, authorIDs)
+	for i, createdAt := range createdAts {
+		require.True(t, createdAt.Equal(base.Add(time.Duration((5-i)*10+3)*time.Minute)))
+	}
+
+	// backward
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*Post]{
+		Before: after,
+		Last:   lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 2}, lo.Map(resp.Edges, func(edge relay.Edge[*Post], _ int) int { return edge.Node.AuthorID }))
+	require.True(t, resp.PageInfo.HasPreviousPage)
+}
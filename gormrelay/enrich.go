@@ -0,0 +1,31 @@
+package gormrelay
+
+import (
+	"context"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// enrichPages wraps next to call enrich with the nodes of each page, and puts the enriched nodes back to the edges,
+// before the paginator encodes the cursors of them. db is passed as a new session without the conditions of the pagination.
+func enrichPages[T any](db *gorm.DB, next relay.ApplyCursorsFunc[T], enrich func(ctx context.Context, db *gorm.DB, nodes []T) error) relay.ApplyCursorsFunc[T] {
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		resp, err := next(ctx, req)
+		if err != nil || len(resp.Edges) == 0 {
+			return resp, err
+		}
+		nodes := make([]T, len(resp.Edges))
+		for i, edge := range resp.Edges {
+			nodes[i] = edge.Node
+		}
+		if err := enrich(ctx, db.Session(&gorm.Session{NewDB: true, Context: ctx}), nodes); err != nil {
+			return nil, errors.Wrap(err, "enrich page")
+		}
+		for i := range resp.Edges {
+			resp.Edges[i].Node = nodes[i]
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,90 @@
+package gormrelay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type taggedUser struct {
+	ID   int      `gorm:"primarykey;not null;"`
+	Name string   `gorm:"not null;"`
+	Age  int      `gorm:"not null;"`
+	Tags []string `gorm:"-"`
+}
+
+func (taggedUser) TableName() string { return "users" }
+
+func TestPageEnricher(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS user_tags").Error)
+	require.NoError(t, db.AutoMigrate(&userTag{}))
+	var tags []*userTag
+	for id := 1; id <= 100; id++ {
+		tags = append(tags, &userTag{UserID: id, Tag: "a"})
+		if id%2 == 0 {
+			tags = append(tags, &userTag{UserID: id, Tag: "b"})
+		}
+	}
+	require.NoError(t, db.Create(tags).Error)
+
+	var calls int
+	enrich := func(ctx context.Context, db *gorm.DB, nodes []*taggedUser) error {
+		calls++
+		var tags []*userTag
+		if err := db.Where("user_id IN ?", lo.Map(nodes, func(u *taggedUser, _ int) int { return u.ID })).Order("tag").Find(&tags).Error; err != nil {
+			return err
+		}
+		byUser := lo.GroupBy(tags, func(tag *userTag) int { return tag.UserID })
+		for _, node := range nodes {
+			node.Tags = lo.Map(byUser[node.ID], func(tag *userTag, _ int) string { return tag.Tag })
+		}
+		return nil
+	}
+
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*taggedUser]) relay.ApplyCursorsFunc[*taggedUser]{
+		"Keyset": NewKeysetAdapter[*taggedUser],
+		"Offset": NewOffsetAdapter[*taggedUser],
+	} {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			recorder := newSQLRecorder()
+			p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, f(
+				db.Session(&gorm.Session{Logger: recorder}).Where("age > ?", 70),
+				WithPageEnricher(enrich),
+			))
+
+			var ids []int
+			pages := 0
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*taggedUser]{First: lo.ToPtr(7)}) {
+				require.NoError(t, err)
+				pages++
+				for _, edge := range page.Edges {
+					require.Equal(t, lo.Ternary(edge.Node.ID%2 == 0, []string{"a", "b"}, []string{"a"}), edge.Node.Tags)
+					ids = append(ids, edge.Node.ID)
+				}
+			}
+			require.Equal(t, lo.RangeFrom(1, 30), ids)
+			require.Equal(t, 5, pages)
+			// once per page, by one batched query without the conditions of the pagination
+			require.Equal(t, pages, calls)
+			enrichments := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return strings.Contains(sql, "user_tags") })
+			require.Len(t, enrichments, pages)
+			for _, sql := range enrichments {
+				require.NotContains(t, sql, "age")
+			}
+		})
+	}
+
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*taggedUser](db, WithPageEnricher(func(ctx context.Context, db *gorm.DB, nodes []*taggedUser) error {
+		return errors.New("boom")
+	})))
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*taggedUser]{First: lo.ToPtr(3)})
+	require.ErrorContains(t, err, "enrich page: boom")
+}
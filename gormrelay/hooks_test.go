@@ -0,0 +1,151 @@
+package gormrelay
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+// hookCounters records the calls of relay.Hooks
+type hookCounters struct {
+	mu     sync.Mutex
+	counts []int
+	finds  []int
+	durs   []time.Duration
+	stages []relay.Stage
+	errs   []error
+}
+
+func (c *hookCounters) hooks() relay.Hooks {
+	return relay.Hooks{
+		OnCount: func(ctx context.Context, dur time.Duration, count int) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.counts = append(c.counts, count)
+			c.durs = append(c.durs, dur)
+		},
+		OnFind: func(ctx context.Context, dur time.Duration, n int) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.finds = append(c.finds, n)
+			c.durs = append(c.durs, dur)
+		},
+		OnError: func(ctx context.Context, stage relay.Stage, err error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.stages = append(c.stages, stage)
+			c.errs = append(c.errs, err)
+		},
+	}
+}
+
+func TestHooks(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	for _, tc := range []struct {
+		name       string
+		newAdapter func() relay.ApplyCursorsFunc[*User]
+		after      string
+	}{
+		{
+			name:       "Keyset",
+			newAdapter: func() relay.ApplyCursorsFunc[*User] { return NewKeysetAdapter[*User](db) },
+			after:      mustEncodeKeysetCursor(&User{ID: 20}, []string{"ID"}),
+		},
+		{
+			name:       "Offset",
+			newAdapter: func() relay.ApplyCursorsFunc[*User] { return NewOffsetAdapter[*User](db) },
+			after:      "19",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &hookCounters{}
+			p := relay.New(false, 10, 10, orderBys, tc.newAdapter(), relay.WithHooks(c.hooks()))
+
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(tc.after), First: lo.ToPtr(10)})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, 10)
+			require.Equal(t, []int{100}, c.counts)
+			// one more is fetched to detect the next page
+			require.Equal(t, []int{11}, c.finds)
+			require.Len(t, c.durs, 2)
+			for _, dur := range c.durs {
+				require.Greater(t, dur, time.Duration(0))
+			}
+			require.Empty(t, c.stages)
+
+			_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("invalid"), First: lo.ToPtr(10)})
+			require.ErrorIs(t, err, relay.ErrInvalidCursor)
+			require.Equal(t, []relay.Stage{relay.StageDecodeCursor}, c.stages)
+			require.ErrorIs(t, c.errs[0], relay.ErrInvalidCursor)
+			require.Len(t, c.finds, 1)
+		})
+	}
+
+	t.Run("Unset", func(t *testing.T) {
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+	})
+}
+
+func TestResetOnInvalidCursor(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	valid := mustEncodeKeysetCursor(&User{ID: 20}, []string{"ID"})
+	nodeIDs := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	// strict by default
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("garbage"), First: lo.ToPtr(5)})
+	require.ErrorIs(t, err, relay.ErrInvalidCursor)
+
+	var resets []error
+	hooks := relay.Hooks{OnCursorReset: func(ctx context.Context, err error) { resets = append(resets, err) }}
+	for _, tc := range []struct {
+		name       string
+		newAdapter func() relay.ApplyCursorsFunc[*User]
+	}{
+		{name: "Keyset", newAdapter: func() relay.ApplyCursorsFunc[*User] { return NewKeysetAdapter[*User](db) }},
+		{name: "Offset", newAdapter: func() relay.ApplyCursorsFunc[*User] { return NewOffsetAdapter[*User](db) }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := relay.New(false, 10, 10, orderBys, tc.newAdapter(), relay.WithResetOnInvalidCursor(), relay.WithHooks(hooks))
+
+			resets = nil
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("garbage"), First: lo.ToPtr(5)})
+			require.NoError(t, err)
+			require.Equal(t, []int{1, 2, 3, 4, 5}, nodeIDs(resp))
+			require.False(t, resp.PageInfo.HasPreviousPage)
+			require.Len(t, resets, 1)
+			require.ErrorIs(t, resets[0], relay.ErrInvalidCursor)
+
+			// only the invalid side is reset
+			resets = nil
+			after := valid
+			if tc.name == "Offset" {
+				after = "19"
+			}
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(after), Before: lo.ToPtr("garbage"), Last: lo.ToPtr(5)})
+			require.NoError(t, err)
+			require.Equal(t, []int{96, 97, 98, 99, 100}, nodeIDs(resp))
+			require.Len(t, resets, 1)
+
+			resets = nil
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(after), First: lo.ToPtr(5)})
+			require.NoError(t, err)
+			require.Equal(t, []int{21, 22, 23, 24, 25}, nodeIDs(resp))
+			require.Empty(t, resets)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package gormrelay
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// indexHint is `USE INDEX (name)` of MySQL right after the table name, so it precedes the joins of the FROM clause as MySQL requires
+type indexHint struct {
+	name string
+	// table is the table expression of the statement before the hint, e.g. of db.Table, nil if it's the table of the model
+	table *clause.Expr
+}
+
+func (h indexHint) ModifyStatement(stmt *gorm.Statement) {
+	h.table = stmt.TableExpr
+	stmt.TableExpr = &clause.Expr{SQL: "?", Vars: []any{h}}
+}
+
+func (h indexHint) Build(builder clause.Builder) {
+	if h.table != nil {
+		h.table.Build(builder)
+	} else if stmt, ok := builder.(*gorm.Statement); ok {
+		builder.WriteQuoted(stmt.Table)
+	}
+	builder.WriteString(" USE INDEX (")
+	builder.WriteQuoted(h.name)
+	builder.WriteByte(')')
+}
+
+// applyIndexHint adds the index hint to the find query of db, it's a no-op for databases other than MySQL
+func applyIndexHint(db *gorm.DB, name string) *gorm.DB {
+	if name == "" || db.Dialector.Name() != "mysql" {
+		return db
+	}
+	return db.Clauses(indexHint{name: name})
+}
@@ -0,0 +1,92 @@
+package gormrelay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// mysqlDialector renders statements as MySQL does, only for dry runs
+type mysqlDialector struct {
+	gorm.Dialector
+}
+
+func (mysqlDialector) Name() string { return "mysql" }
+
+func (mysqlDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v any) {
+	writer.WriteByte('?')
+}
+
+func (mysqlDialector) QuoteTo(writer clause.Writer, str string) {
+	for i, s := range strings.Split(str, ".") {
+		if i > 0 {
+			writer.WriteByte('.')
+		}
+		writer.WriteByte('`')
+		writer.WriteString(s)
+		writer.WriteByte('`')
+	}
+}
+
+func (mysqlDialector) Explain(sql string, vars ...any) string {
+	return logger.ExplainSQL(sql, nil, `'`, vars...)
+}
+
+func TestIndexHint(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	mysqlDB := db.Session(&gorm.Session{DryRun: true, Logger: recorder})
+	mysqlDB.Config.Dialector = mysqlDialector{Dialector: db.Dialector}
+
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: false}, {Field: "ID", Desc: false}}
+	after := &map[string]any{"Age": 85, "ID": 16}
+
+	_, err := NewKeysetFinder(mysqlDB, WithIndexHint[*User]("idx_users_age")).Find(context.Background(), after, nil, orderBys, 10, false)
+	require.NoError(t, err)
+	sqls := recorder.SQLs()
+	require.Equal(t, "SELECT * FROM `users` USE INDEX (`idx_users_age`) WHERE (`age` > 85 OR (`age` = 85 AND `id` > 16)) ORDER BY `age`,`id` LIMIT 10", sqls[len(sqls)-1])
+
+	_, err = NewOffsetFinder(mysqlDB, WithIndexHint[*User]("idx_users_age")).Find(context.Background(), orderBys, 20, 10)
+	require.NoError(t, err)
+	sqls = recorder.SQLs()
+	require.Contains(t, sqls[len(sqls)-1], "SELECT * FROM `users` USE INDEX (`idx_users_age`) ORDER BY")
+
+	// the hint precedes the joins
+	_, err = NewKeysetFinder(mysqlDB.Joins("JOIN orders ON orders.customer_id = users.id"), WithIndexHint[*User]("idx_users_age")).Find(context.Background(), after, nil, orderBys, 10, false)
+	require.NoError(t, err)
+	sqls = recorder.SQLs()
+	require.Contains(t, sqls[len(sqls)-1], "FROM `users` USE INDEX (`idx_users_age`) JOIN orders ON orders.customer_id = users.id WHERE")
+
+	// and follows the table of db.Table
+	_, err = NewOffsetFinder(mysqlDB.Table("users AS u").Joins("JOIN orders ON orders.customer_id = u.id"), WithIndexHint[*User]("idx_users_age")).Find(context.Background(), orderBys, 20, 10)
+	require.NoError(t, err)
+	sqls = recorder.SQLs()
+	require.Contains(t, sqls[len(sqls)-1], "FROM users AS u USE INDEX (`idx_users_age`) JOIN orders ON orders.customer_id = u.id ORDER BY")
+
+	// the count is not hinted
+	_, err = NewKeysetCounter(mysqlDB, WithIndexHint[*User]("idx_users_age")).Count(context.Background())
+	require.NoError(t, err)
+	sqls = recorder.SQLs()
+	require.Equal(t, "SELECT count(*) FROM `users`", sqls[len(sqls)-1])
+
+	// a no-op on other databases
+	recorder = newSQLRecorder()
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter(db.Session(&gorm.Session{Logger: recorder}), WithIndexHint[*User]("idx_users_age")))
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 16, Age: 85}, []string{"Age", "ID"})),
+		First: lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{15, 14}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+	for _, sql := range recorder.SQLs() {
+		require.NotContains(t, sql, "USE INDEX")
+	}
+}
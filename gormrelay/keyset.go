@@ -2,7 +2,16 @@ package gormrelay
 
 import (
 	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	relay "github.com/molon/gorelay"
 	"github.com/molon/gorelay/cursor"
@@ -13,7 +22,14 @@ import (
 	"gorm.io/gorm/schema"
 )
 
-func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[string]any, reverse bool) (clause.Expression, error) {
+func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[string]any, reverse bool, nullsLargest bool, opts *keysetOptions) (clause.Expression, error) {
+	if opts.rowValues {
+		expr, err := createRowValueExpr(s, orderBys, keyset, reverse, opts)
+		if err != nil || expr != nil {
+			return expr, err
+		}
+	}
+
 	ors := make([]clause.Expression, 0, len(orderBys))
 	eqs := make([]clause.Expression, 0, len(orderBys))
 	for i, orderBy := range orderBys {
@@ -22,9 +38,9 @@ func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[stri
 			return nil, errors.Errorf("missing field %q in keyset", orderBy.Field)
 		}
 
-		field, ok := s.FieldsByName[orderBy.Field]
-		if !ok {
-			return nil, errors.Errorf("missing field %q in schema", orderBy.Field)
+		column, nullable, err := keysetColumn(s, orderBy.Field, opts)
+		if err != nil {
+			return nil, err
 		}
 
 		desc := orderBy.Desc
@@ -32,25 +48,516 @@ func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[stri
 			desc = !desc
 		}
 
+		inclusive := opts.inclusiveLastColumn && i == len(orderBys)-1
+		null := nullable && isNull(v)
+
+		v, err = keysetValue(s, orderBy.Field, v, opts)
+		if err != nil {
+			return nil, err
+		}
+		// Whether NULLs are ordered after the non-NULL values in this direction
+		nullsAfter := nullsLargest != desc
+		switch orderBy.Nulls {
+		case relay.NullsFirst:
+			nullsAfter = reverse
+		case relay.NullsLast:
+			nullsAfter = !reverse
+		}
+
+		// e.g. @age0 for the after cursor and @age1 for the before cursor
+		var name string
+		if opts.namedParams {
+			name = column.Name
+			if column.Raw {
+				name = schema.NamingStrategy{}.ColumnName("", orderBy.Field)
+			}
+			name += lo.Ternary(reverse, "1", "0")
+		}
+
 		var expr clause.Expression
-		if desc {
-			expr = clause.Lt{Column: field.DBName, Value: v}
-		} else {
-			expr = clause.Gt{Column: field.DBName, Value: v}
+		switch {
+		case null && inclusive && nullsAfter:
+			expr = clause.Eq{Column: column, Value: nil}
+		case null && inclusive:
+			// every row is at or beyond NULLs in this column
+		case null && nullsAfter:
+			// no row is beyond NULLs in this column, only the tiebreak branches of the subsequent columns are left
+		case null:
+			expr = clause.Neq{Column: column, Value: nil}
+		case desc && inclusive:
+			expr = compareExpr("<=", column, v, name)
+		case desc:
+			expr = compareExpr("<", column, v, name)
+		case inclusive:
+			expr = compareExpr(">=", column, v, name)
+		default:
+			expr = compareExpr(">", column, v, name)
+		}
+		if nullable && !null && nullsAfter {
+			expr = clause.Or(expr, clause.Eq{Column: column, Value: nil})
 		}
 
-		ands := make([]clause.Expression, len(eqs)+1)
-		copy(ands, eqs)
-		ands[len(eqs)] = expr
-		ors = append(ors, clause.And(ands...))
+		if expr != nil {
+			ands := make([]clause.Expression, len(eqs)+1)
+			copy(ands, eqs)
+			ands[len(eqs)] = expr
+			ors = append(ors, clause.And(ands...))
+		} else if null && inclusive {
+			if len(eqs) == 0 {
+				return clause.Expr{SQL: "1 = 1"}, nil // all rows are beyond the keyset
+			}
+			ors = append(ors, clause.And(eqs...))
+		}
 
 		if i < len(orderBys)-1 {
-			eqs = append(eqs, clause.Eq{Column: field.DBName, Value: v})
+			if null {
+				// `= NULL` is never true
+				eqs = append(eqs, clause.Eq{Column: column, Value: nil})
+			} else {
+				eqs = append(eqs, compareExpr("=", column, v, name))
+			}
 		}
 	}
+	if len(ors) == 0 {
+		return clause.Expr{SQL: "1 <> 1"}, nil // no row is beyond the keyset
+	}
 	return clause.And(clause.Or(ors...)), nil
 }
 
+// createRowValueExpr builds the row value comparison, e.g. `("age","name") > (?,?)`, which is equivalent to the expansion
+// if all directions are the same and no column is nullable, otherwise it returns nil to fall back to the expansion.
+func createRowValueExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[string]any, reverse bool, opts *keysetOptions) (clause.Expression, error) {
+	if len(orderBys) == 0 || lo.SomeBy(orderBys, func(orderBy relay.OrderBy) bool { return orderBy.Desc != orderBys[0].Desc }) {
+		return nil, nil
+	}
+	columns := make([]any, 0, len(orderBys))
+	values := make([]any, 0, len(orderBys))
+	placeholders := make([]string, 0, len(orderBys))
+	for _, orderBy := range orderBys {
+		column, nullable, err := keysetColumn(s, orderBy.Field, opts)
+		if err != nil {
+			return nil, err
+		}
+		if nullable {
+			return nil, nil
+		}
+		v, ok := keyset[orderBy.Field]
+		if !ok {
+			return nil, errors.Errorf("missing field %q in keyset", orderBy.Field)
+		}
+		v, err = keysetValue(s, orderBy.Field, v, opts)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+		if opts.namedParams {
+			name := column.Name
+			if column.Raw {
+				name = schema.NamingStrategy{}.ColumnName("", orderBy.Field)
+			}
+			name += lo.Ternary(reverse, "1", "0")
+			placeholders = append(placeholders, "@"+name)
+			values = append(values, sql.Named(name, v))
+		} else {
+			placeholders = append(placeholders, "?")
+			values = append(values, v)
+		}
+	}
+
+	op := lo.Ternary(orderBys[0].Desc != reverse, "<", ">")
+	if opts.inclusiveLastColumn {
+		op += "="
+	}
+	rowValues := "(" + strings.TrimSuffix(strings.Repeat("?,", len(columns)), ",") + ") " + op + " (" + strings.Join(placeholders, ",") + ")"
+	if opts.namedParams {
+		return clause.NamedExpr{SQL: rowValues, Vars: append(columns, values...)}, nil
+	}
+	return clause.Expr{SQL: rowValues, Vars: append(columns, values...)}, nil
+}
+
+// keysetValue returns the value of field to compare with
+func keysetValue(s *schema.Schema, field string, v any, opts *keysetOptions) (any, error) {
+	if loc, ok := opts.timestampLocations[field]; ok && !isNull(v) {
+		t, err := toTime(v)
+		if err != nil {
+			return nil, keysetValueError(field, err, opts)
+		}
+		// A literal without time zone, so that it's not converted by the session time zone
+		return t.In(loc).Format(timestampLayout), nil
+	}
+	if str, ok := v.(string); ok && isTimeField(s, field) {
+		// Decoded from a cursor, the database may not compare the RFC 3339 string as the stored timestamp exactly,
+		// e.g. by its format or precision, so it's bound as the time.Time of the field with the full precision of the cursor
+		t, err := toTime(str)
+		if err != nil {
+			return nil, keysetValueError(field, err, opts)
+		}
+		return t, nil
+	}
+	if !isNull(v) && isBoolField(s, field) {
+		// Bound as a bool, e.g. 0/1 of a cursor from a driver scanning booleans as integers doesn't compare with a boolean column on Postgres
+		b, err := toBool(v)
+		if err != nil {
+			return nil, keysetValueError(field, err, opts)
+		}
+		return b, nil
+	}
+	return v, nil
+}
+
+// keysetValueError marks err of converting the value of field as an invalid cursor,
+// with the message replaced if the field is redacted, since it may quote the value
+func keysetValueError(field string, err error, opts *keysetOptions) error {
+	if opts.redactedKeys[field] {
+		err = errors.Errorf("invalid value %s", cursor.RedactedValue)
+	}
+	return relay.MarkError(errors.Wrapf(err, "field %q", field), relay.ErrInvalidCursor)
+}
+
+// isBoolField reports whether field is a bool or *bool field of s
+func isBoolField(s *schema.Schema, field string) bool {
+	f, _, ok := lookUpField(s, field)
+	if !ok {
+		return false
+	}
+	typ := f.FieldType
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.Bool
+}
+
+func toBool(v any) (bool, error) {
+	switch v := v.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	}
+	return false, errors.Errorf("invalid bool %v", v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// checkScalarOrder rejects the order by fields of s whose values are not scalars, e.g. nested structs,
+// which are encoded into cursors as JSON objects and can't be compared in SQL.
+// Fields not in s are left to keysetColumn, e.g. aggregates.
+func checkScalarOrder(s *schema.Schema, orderBys []relay.OrderBy) error {
+	for _, orderBy := range orderBys {
+		f, _, ok := lookUpField(s, orderBy.Field)
+		if ok && !isScalarType(f.FieldType) {
+			return relay.MarkError(errors.Errorf("order-by field %q must be a scalar/comparable type, not %s", orderBy.Field, f.FieldType), relay.ErrInvalidOrderBy)
+		}
+	}
+	return nil
+}
+
+// isScalarType reports whether values of typ are encoded as JSON scalars, types marshaling themselves are assumed to be, e.g. time.Time
+func isScalarType(typ reflect.Type) bool {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Implements(jsonMarshalerType) || typ.Implements(textMarshalerType) ||
+		reflect.PointerTo(typ).Implements(jsonMarshalerType) || reflect.PointerTo(typ).Implements(textMarshalerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		return typ.Elem().Kind() == reflect.Uint8
+	case reflect.Struct, reflect.Map, reflect.Array, reflect.Interface, reflect.Func, reflect.Chan:
+		return false
+	}
+	return true
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	valuerType        = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType       = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// isTimeField reports whether field is a time.Time or *time.Time field of s
+func isTimeField(s *schema.Schema, field string) bool {
+	f, _, ok := lookUpField(s, field)
+	if !ok {
+		return false
+	}
+	typ := f.FieldType
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ == timeType
+}
+
+// lookUpField returns the field of s by name, or else the field of a has one or belongs to relation of s by a dotted name,
+// e.g. `Customer.Name`, with the relation.
+func lookUpField(s *schema.Schema, name string) (*schema.Field, *schema.Relationship, bool) {
+	if f, ok := s.FieldsByName[name]; ok {
+		return f, nil, true
+	}
+	relName, fieldName, ok := strings.Cut(name, ".")
+	if !ok {
+		return nil, nil, false
+	}
+	rel, ok := s.Relationships.Relations[relName]
+	if !ok || rel.FieldSchema == nil || (rel.Type != schema.HasOne && rel.Type != schema.BelongsTo) {
+		return nil, nil, false
+	}
+	f, ok := rel.FieldSchema.FieldsByName[fieldName]
+	if !ok {
+		return nil, nil, false
+	}
+	return f, rel, true
+}
+
+// relatedKeysetOptions resolves the columns of the order by fields of relations, see lookUpField.
+// A column is qualified by the relation name if the relation is joined by name, e.g. `Joins("Customer")`,
+// or else by the table of the relation, e.g. `Joins("JOIN customers ON ...")`.
+// If db has joins, the columns of s are qualified as well, since the joined tables may have the same columns.
+func relatedKeysetOptions(db *gorm.DB, s *schema.Schema, orderBys []relay.OrderBy, opts *keysetOptions) *keysetOptions {
+	if len(db.Statement.Joins) > 0 {
+		o := *opts
+		o.qualifyColumns = true
+		opts = &o
+	}
+	var columns map[string]clause.Column
+	for _, orderBy := range orderBys {
+		f, rel, ok := lookUpField(s, orderBy.Field)
+		if !ok || rel == nil {
+			continue
+		}
+		table := rel.FieldSchema.Table
+		for _, join := range db.Statement.Joins {
+			if join.Name == rel.Name {
+				table = rel.Name
+				break
+			}
+		}
+		if columns == nil {
+			columns = map[string]clause.Column{}
+		}
+		columns[orderBy.Field] = clause.Column{Table: table, Name: f.DBName}
+	}
+	if columns == nil {
+		return opts
+	}
+	o := *opts
+	o.relatedColumns = columns
+	return &o
+}
+
+// collatedKeysetOptions resolves the columns of the order bys with Collate, e.g. `"name" COLLATE "C"`,
+// so that the keyset is compared by the same collation as it is ordered by, otherwise pages may overlap or skip rows.
+func collatedKeysetOptions(db *gorm.DB, s *schema.Schema, orderBys []relay.OrderBy, opts *keysetOptions) (*keysetOptions, error) {
+	var columns map[string]clause.Column
+	for _, orderBy := range orderBys {
+		if orderBy.Collate == "" {
+			continue
+		}
+		column, _, err := keysetColumn(s, orderBy.Field, opts)
+		if err != nil {
+			return nil, err
+		}
+		if columns == nil {
+			columns = map[string]clause.Column{}
+		}
+		columns[orderBy.Field] = collateColumn(db, column, orderBy.Collate)
+	}
+	if columns == nil {
+		return opts, nil
+	}
+	o := *opts
+	o.collatedColumns = columns
+	return &o, nil
+}
+
+// collateColumn returns the raw column of `column COLLATE collation`.
+// The collation is quoted as a whole rather than by Statement.Quote, which would split it by dots, e.g. "en_US.utf8".
+func collateColumn(db *gorm.DB, column clause.Column, collation string) clause.Column {
+	quote := lo.Ternary(db.Dialector.Name() == "mysql", "`", `"`)
+	return clause.Column{Name: db.Statement.Quote(column) + " COLLATE " + quote + collation + quote, Raw: true}
+}
+
+// keysetColumn returns the column of field to compare and order by, and whether it is nullable.
+// An aggregate field is its raw expression, which is assumed to be not NULL,
+// and an order expression is nullable as the field is, or else assumed to be not NULL.
+// A field of a relation is always nullable, since the relation may be outer joined.
+// The column of a field ordered by a collation has the collation, see collatedKeysetOptions.
+func keysetColumn(s *schema.Schema, field string, opts *keysetOptions) (clause.Column, bool, error) {
+	column, nullable, err := uncollatedKeysetColumn(s, field, opts)
+	if collated, ok := opts.collatedColumns[field]; ok && err == nil {
+		column = collated
+	}
+	return column, nullable, err
+}
+
+func uncollatedKeysetColumn(s *schema.Schema, field string, opts *keysetOptions) (clause.Column, bool, error) {
+	if expr, ok := opts.aggregateExprs[field]; ok {
+		return clause.Column{Name: expr, Raw: true}, false, nil
+	}
+	if column, ok := opts.relatedColumns[field]; ok {
+		return column, true, nil
+	}
+	f, ok := s.FieldsByName[field]
+	if expr, exprOK := opts.orderExprs[field]; exprOK {
+		return clause.Column{Name: expr, Raw: true}, ok && isNullableField(f), nil
+	}
+	if !ok {
+		return clause.Column{}, false, errors.Errorf("missing field %q in schema", field)
+	}
+	if expr, ok := opts.roundedExprs[field]; ok {
+		return clause.Column{Name: expr, Raw: true}, isNullableField(f), nil
+	}
+	if opts.qualifyColumns {
+		return clause.Column{Table: clause.CurrentTable, Name: f.DBName}, isNullableField(f), nil
+	}
+	return clause.Column{Name: f.DBName}, isNullableField(f), nil
+}
+
+// isNullableField reports whether the column of f can hold NULL, which is only assumed if it's neither a primary key nor not null,
+// and its Go type can hold NULL too, i.e. a pointer, an interface or a type scanning or valuing itself, e.g. sql.NullString,
+// since a column scanned into a plain type, e.g. a string, can't have NULLs to paginate over anyway.
+func isNullableField(f *schema.Field) bool {
+	if f.NotNull || f.PrimaryKey {
+		return false
+	}
+	t := f.FieldType
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return true
+	}
+	return t.Implements(valuerType) || t.Implements(scannerType) || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// roundedKeysetOptions resolves the expressions of WithFloatPrecision with the quoting and dialect of db
+func roundedKeysetOptions(db *gorm.DB, s *schema.Schema, opts *keysetOptions) (*keysetOptions, error) {
+	if len(opts.floatPrecisions) == 0 {
+		return opts, nil
+	}
+	o := *opts
+	o.roundedExprs = make(map[string]string, len(opts.floatPrecisions))
+	for field, places := range opts.floatPrecisions {
+		f, ok := s.FieldsByName[field]
+		if !ok {
+			return nil, errors.Errorf("missing field %q in schema", field)
+		}
+		column := db.Statement.Quote(clause.Column{Name: f.DBName})
+		if db.Dialector.Name() == "postgres" {
+			// There is no round(double precision, integer)
+			column = "CAST(" + column + " AS numeric)"
+		}
+		o.roundedExprs[field] = fmt.Sprintf("round(%s, %d)", column, places)
+	}
+	return &o, nil
+}
+
+// roundFloat rounds a float value of a keyset to places decimal places
+func roundFloat(v any, places int) (any, error) {
+	var f float64
+	switch n := v.(type) {
+	case float64:
+		f = n
+	case float32:
+		f = float64(n)
+	case *float64:
+		f = *n
+	case *float32:
+		f = float64(*n)
+	default:
+		return nil, errors.Errorf("unsupported float value %T", v)
+	}
+	// Rounded by the decimal representation, scaling by powers of 10 is inexact
+	return strconv.ParseFloat(strconv.FormatFloat(f, 'f', places, 64), 64)
+}
+
+// orderByColumn builds the ORDER BY column of orderBy, reversed if reverse is true, with `NULLS FIRST`/`NULLS LAST` if orderBy.Nulls is set
+func orderByColumn(stmt *gorm.Statement, column clause.Column, orderBy relay.OrderBy, reverse bool) clause.OrderByColumn {
+	desc := orderBy.Desc
+	if reverse {
+		desc = !desc
+	}
+	if orderBy.Nulls == relay.NullsDefault {
+		return clause.OrderByColumn{Column: column, Desc: desc}
+	}
+	nulls := orderBy.Nulls
+	if reverse {
+		nulls = lo.Ternary(nulls == relay.NullsFirst, relay.NullsLast, relay.NullsFirst)
+	}
+	// The direction has to precede NULLS, so it's part of the raw column
+	sql := stmt.Quote(column)
+	if desc {
+		sql += " DESC"
+	}
+	return clause.OrderByColumn{Column: clause.Column{Name: sql + " NULLS " + string(nulls), Raw: true}}
+}
+
+// compareExpr builds `column op ?`, or `column op @name` with the value bound by name if name is not empty
+func compareExpr(op string, column clause.Column, v any, name string) clause.Expression {
+	if name != "" {
+		return clause.NamedExpr{SQL: "? " + op + " @" + name, Vars: []any{column, sql.Named(name, v)}}
+	}
+	switch op {
+	case "=":
+		return clause.Eq{Column: column, Value: v}
+	case "<":
+		return clause.Lt{Column: column, Value: v}
+	case "<=":
+		return clause.Lte{Column: column, Value: v}
+	case ">":
+		return clause.Gt{Column: column, Value: v}
+	case ">=":
+		return clause.Gte{Column: column, Value: v}
+	}
+	panic("unsupported operator " + op)
+}
+
+const timestampLayout = "2006-01-02 15:04:05.999999"
+
+// toTime converts a time value of a keyset, which is a string if it is decoded from a cursor
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case *time.Time:
+		return *t, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return time.Time{}, errors.Wrap(err, "parse time")
+		}
+		return parsed, nil
+	}
+	return time.Time{}, errors.Errorf("unsupported time value %T", v)
+}
+
+// isNull reports whether v is written as NULL, e.g. nil, a nil pointer or a driver.Valuer of nil
+func isNull(v any) bool {
+	if v == nil {
+		return true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return true
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		dv, err := valuer.Value()
+		return err == nil && dv == nil
+	}
+	return false
+}
+
+// nullsLargest reports whether the database orders NULLs as larger than any non-NULL value by default,
+// which is the case of Postgres and Oracle, while MySQL, SQLite and SQL Server order them as the smallest.
+func nullsLargest(db *gorm.DB) bool {
+	switch db.Dialector.Name() {
+	case "mysql", "sqlite", "sqlite3", "sqlserver":
+		return false
+	}
+	return true
+}
+
 // Example:
 // db.Clauses(
 //
@@ -86,7 +593,10 @@ func createWhereExpr(s *schema.Schema, orderBys []relay.OrderBy, keyset map[stri
 //		clause.Limit{Limit: &limit},
 //
 // )
-func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) func(db *gorm.DB) *gorm.DB {
+func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool, opts *keysetOptions) func(db *gorm.DB) *gorm.DB {
+	if opts == nil {
+		opts = &keysetOptions{}
+	}
 	return func(db *gorm.DB) *gorm.DB {
 		if db.Statement.Model == nil {
 			db.AddError(errors.New("model is nil"))
@@ -99,43 +609,64 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 			return db
 		}
 
-		var exprs []clause.Expression
+		if err := checkScalarOrder(s, orderBys); err != nil {
+			db.AddError(err)
+			return db
+		}
+
+		opts, err := roundedKeysetOptions(db, s, opts)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		opts = relatedKeysetOptions(db, s, orderBys, opts)
+		opts, err = collatedKeysetOptions(db, s, orderBys, opts)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+
+		var exprs, boundaries []clause.Expression
 
 		if after != nil {
-			expr, err := createWhereExpr(s, orderBys, *after, false)
+			expr, err := createWhereExpr(s, orderBys, *after, false, nullsLargest(db), opts)
 			if err != nil {
 				db.AddError(err)
 				return db
 			}
-			exprs = append(exprs, expr)
+			boundaries = append(boundaries, expr)
 		}
 
 		if before != nil {
-			expr, err := createWhereExpr(s, orderBys, *before, true)
+			expr, err := createWhereExpr(s, orderBys, *before, true, nullsLargest(db), opts)
 			if err != nil {
 				db.AddError(err)
 				return db
 			}
-			exprs = append(exprs, expr)
+			boundaries = append(boundaries, expr)
+		}
+
+		aggregated := lo.SomeBy(orderBys, func(orderBy relay.OrderBy) bool {
+			_, ok := opts.aggregateExprs[orderBy.Field]
+			return ok
+		})
+		if aggregated && len(boundaries) > 0 {
+			// Aggregates can only be compared after grouping
+			exprs = append(exprs, clause.GroupBy{Having: boundaries})
+		} else {
+			exprs = append(exprs, boundaries...)
 		}
 
 		if len(orderBys) > 0 {
 			orderByColumns := make([]clause.OrderByColumn, 0, len(orderBys))
 			for _, orderBy := range orderBys {
-				field, ok := s.FieldsByName[orderBy.Field]
-				if !ok {
-					db.AddError(errors.Errorf("missing field %q in schema", orderBy.Field))
+				column, _, err := keysetColumn(s, orderBy.Field, opts)
+				if err != nil {
+					db.AddError(err)
 					return db
 				}
 
-				desc := orderBy.Desc
-				if fromLast {
-					desc = !desc
-				}
-				orderByColumns = append(orderByColumns, clause.OrderByColumn{
-					Column: clause.Column{Name: field.DBName},
-					Desc:   desc,
-				})
+				orderByColumns = append(orderByColumns, orderByColumn(db.Statement, column, orderBy, fromLast))
 			}
 			exprs = append(exprs, clause.OrderBy{Columns: orderByColumns})
 		}
@@ -148,25 +679,66 @@ func scopeKeyset(after, before *map[string]any, orderBys []relay.OrderBy, limit
 	}
 }
 
-func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, error) {
+// findByKeyset also selects the total count into total by a window function, if total is not nil
+func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool, opts *options[T], total *int) ([]T, error) {
 	var nodes []T
 	if limit == 0 {
 		return nodes, nil
 	}
 
+	if err := checkNoOrderBy(db); err != nil {
+		return nil, err
+	}
+
 	basedOnModel, err := shouldBasedOnModel[T](db)
 	if err != nil {
 		return nil, err
 	}
 
+	if !basedOnModel && db.Statement.Model == nil {
+		var t T
+		db = db.Model(t)
+	}
+
+	// Before wrapping into subqueries, so that the hint is of the table
+	db = applyIndexHint(db, opts.indexHint)
+
+	if len(opts.computedFields) > 0 || len(opts.timeBuckets) > 0 {
+		db, err = computeFields(db, opts.computedFields, opts.timeBuckets)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(opts.distinctOn) > 0 {
+		db, err = distinctOn(db, opts.distinctOn, orderBys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if total != nil {
+		db, err = selectWindowTotal(db, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	find := func(dest any) error {
+		db := db.Scopes(scopeKeyset(after, before, orderBys, limit, fromLast, &opts.keysetOptions))
+		if total != nil {
+			return findWithWindowTotal(db, dest, total)
+		}
+		return errors.Wrap(db.Find(dest).Error, "find")
+	}
+
 	if basedOnModel {
 		modelType := reflect.TypeOf(db.Statement.Model)
 		sliceType := reflect.SliceOf(modelType)
 		nodesVal := reflect.New(sliceType).Elem()
 
-		err := db.Scopes(scopeKeyset(after, before, orderBys, limit, fromLast)).Find(nodesVal.Addr().Interface()).Error
-		if err != nil {
-			return nil, errors.Wrap(err, "find")
+		if err := find(nodesVal.Addr().Interface()); err != nil {
+			return nil, err
 		}
 
 		nodes := make([]T, nodesVal.Len())
@@ -180,14 +752,8 @@ func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []
 		return nodes, nil
 	}
 
-	if db.Statement.Model == nil {
-		var t T
-		db = db.Model(t)
-	}
-
-	err = db.Scopes(scopeKeyset(after, before, orderBys, limit, fromLast)).Find(&nodes).Error
-	if err != nil {
-		return nil, errors.Wrap(err, "find")
+	if err := find(&nodes); err != nil {
+		return nil, err
 	}
 	if fromLast {
 		lo.Reverse(nodes)
@@ -195,35 +761,149 @@ func findByKeyset[T any](db *gorm.DB, after, before *map[string]any, orderBys []
 	return nodes, nil
 }
 
-func NewKeysetFinder[T any](db *gorm.DB) cursor.KeysetFinder[T] {
+// NewKeysetFinder creates a finder without counting, which also implements cursor.KeysetExistChecker,
+// so cursor.WithExactCursorExistence tells the cursors without rows beyond them without a counter, e.g. on an empty table.
+func NewKeysetFinder[T any](db *gorm.DB, opts ...Option[T]) cursor.KeysetFinder[T] {
+	return newKeysetFinder(db, opts)
+}
+
+func newKeysetFinder[T any](db *gorm.DB, opts []Option[T]) *keysetFinder[T] {
+	return &keysetFinder[T]{
+		finder:          newKeysetFinderFunc[T](db, opts),
+		inclusiveFinder: newKeysetFinderFunc[T](db, append(slices.Clone(opts), WithInclusiveLastColumn[T]())),
+	}
+}
+
+type keysetFinder[T any] struct {
+	finder cursor.KeysetFinder[T]
+	// inclusiveFinder finds the rows at the cursors as well, for ExistsUpTo
+	inclusiveFinder cursor.KeysetFinder[T]
+}
+
+func (f *keysetFinder[T]) Find(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, error) {
+	return f.finder.Find(ctx, after, before, orderBys, limit, fromLast)
+}
+
+// ExistsUpTo implements cursor.KeysetExistChecker by finding a row before keyset inclusively, or after it if reverse is true
+func (f *keysetFinder[T]) ExistsUpTo(ctx context.Context, keyset map[string]any, orderBys []relay.OrderBy, reverse bool) (bool, error) {
+	after, before := (*map[string]any)(nil), &keyset
+	if reverse {
+		after, before = &keyset, nil
+	}
+	nodes, err := f.inclusiveFinder.Find(ctx, after, before, orderBys, 1, false)
+	if err != nil {
+		return false, err
+	}
+	return len(nodes) > 0, nil
+}
+
+func newKeysetFinderFunc[T any](db *gorm.DB, opts []Option[T]) cursor.KeysetFinderFunc[T] {
+	o := newOptions(opts)
 	return cursor.KeysetFinderFunc[T](func(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, error) {
 		if limit == 0 {
 			return []T{}, nil
 		}
 
-		db := db
-		if db.Statement.Context != ctx {
-			db = db.WithContext(ctx)
+		return limitConcurrency(ctx, o.concurrencyLimiter, func() ([]T, error) {
+			return retryTransient(ctx, o.transientRetry, func() ([]T, error) {
+				db := prepareDB(ctx, db, o)
+
+				if o.dropsNodes() {
+					return findByKeysetWithPostFilter[T](db, after, before, orderBys, limit, fromLast, o)
+				}
+
+				return findByKeyset[T](db, after, before, orderBys, limit, fromLast, o, nil)
+			})
+		})
+	})
+}
+
+// ErrTooManyRefills is returned if a page isn't filled after the max refills of WithMaxRefills
+var ErrTooManyRefills = errors.New("too many refills of the post-filtered page")
+
+// findByKeysetWithPostFilter keeps fetching from the last fetched row until limit nodes pass the post filter and deduplication or no more rows
+func findByKeysetWithPostFilter[T any](db *gorm.DB, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool, opts *options[T]) ([]T, error) {
+	encoder, err := cursor.NewKeysetEncoder[T](lo.Map(orderBys, func(item relay.OrderBy, _ int) string {
+		return item.Field
+	}), opts.cursorOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered, result []T
+	for refills := 0; ; refills++ {
+		if refills > opts.maxRefills {
+			return nil, errors.Wrapf(ErrTooManyRefills, "%d nodes of %d kept after %d refills", len(result), limit, opts.maxRefills)
+		}
+		nodes, err := findByKeyset[T](db, after, before, orderBys, limit, fromLast, opts, nil)
+		if err != nil {
+			return nil, err
 		}
 
-		nodes, err := findByKeyset[T](db, after, before, orderBys, limit, fromLast)
+		kept := nodes
+		if opts.postFilter != nil {
+			kept = lo.Filter(nodes, func(node T, _ int) bool {
+				return opts.postFilter(node)
+			})
+		}
+		if fromLast {
+			filtered = append(kept, filtered...)
+		} else {
+			filtered = append(filtered, kept...)
+		}
+		result = filtered
+		if opts.dedupBy != nil {
+			// In the page order, so the first one is kept whichever direction the rows are fetched in
+			result = lo.UniqBy(filtered, opts.dedupBy)
+		}
+		if len(result) >= limit || len(nodes) < limit {
+			break
+		}
+
+		// nodes of fromLast are reversed, so the last fetched row is the first one
+		last := nodes[len(nodes)-1]
+		if fromLast {
+			last = nodes[0]
+		}
+		keyset, err := encoder.Keyset(last)
 		if err != nil {
 			return nil, err
 		}
+		if fromLast {
+			before = &keyset
+		} else {
+			after = &keyset
+		}
 
-		return nodes, nil
-	})
+		// Continue from the last fetched row exclusively, it has been fetched already
+		if opts.inclusiveLastColumn {
+			o := *opts
+			o.inclusiveLastColumn = false
+			opts = &o
+		}
+	}
+
+	if len(result) > limit {
+		if fromLast {
+			result = result[len(result)-limit:]
+		} else {
+			result = result[:limit]
+		}
+	}
+	return result, nil
 }
 
 type KeysetCounter[T any] struct {
 	db     *gorm.DB
-	finder cursor.KeysetFinder[T]
+	finder *keysetFinder[T]
+	opts   *options[T]
 }
 
-func NewKeysetCounter[T any](db *gorm.DB) *KeysetCounter[T] {
+func NewKeysetCounter[T any](db *gorm.DB, opts ...Option[T]) *KeysetCounter[T] {
 	return &KeysetCounter[T]{
 		db:     db,
-		finder: NewKeysetFinder[T](db),
+		finder: newKeysetFinder(db, opts),
+		opts:   newOptions(opts),
 	}
 }
 
@@ -231,32 +911,191 @@ func (a *KeysetCounter[T]) Find(ctx context.Context, after, before *map[string]a
 	return a.finder.Find(ctx, after, before, orderBys, limit, fromLast)
 }
 
+// ExistsUpTo implements cursor.KeysetExistChecker, see keysetFinder.ExistsUpTo
+func (a *KeysetCounter[T]) ExistsUpTo(ctx context.Context, keyset map[string]any, orderBys []relay.OrderBy, reverse bool) (bool, error) {
+	return a.finder.ExistsUpTo(ctx, keyset, orderBys, reverse)
+}
+
+// ConvertKeyset implements cursor.KeysetConverter by the values bound to the keyset conditions
+func (a *KeysetCounter[T]) ConvertKeyset(_ context.Context, keyset map[string]any) (map[string]any, error) {
+	s, err := schemaOf[T](a.db)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]any, len(keyset))
+	for field, v := range keyset {
+		if m[field], err = keysetValue(s, field, v, &a.opts.keysetOptions); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Count counts exactly, even under WithMaxPKCount, e.g. to locate the last page
 func (a *KeysetCounter[T]) Count(ctx context.Context) (int, error) {
-	db := a.db
+	opts := *a.opts
+	opts.maxPKCount = false
+	totalCount, _, err := count[T](ctx, a.db, &opts)
+	return totalCount, err
+}
 
-	basedOnModel, err := shouldBasedOnModel[T](db)
+func (a *KeysetCounter[T]) ApproximateCount(ctx context.Context) (int, bool, error) {
+	return count[T](ctx, a.db, a.opts)
+}
+
+func (a *KeysetCounter[T]) SkipCount(_ context.Context) bool {
+	return a.opts.skipCount()
+}
+
+// KeysetCounterWindowed is a KeysetCounter which also selects the total count with the page by `COUNT(*) OVER ()` in a subquery,
+// so that cursor.NewKeysetAdapter fetches both in one round trip, it requires window functions, e.g. Postgres, MySQL 8 or SQLite 3.25.
+type KeysetCounterWindowed[T any] struct {
+	*KeysetCounter[T]
+}
+
+func NewKeysetCounterWindowed[T any](db *gorm.DB, opts ...Option[T]) *KeysetCounterWindowed[T] {
+	return &KeysetCounterWindowed[T]{KeysetCounter: NewKeysetCounter[T](db, opts...)}
+}
+
+func (a *KeysetCounterWindowed[T]) FindWithCount(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]T, int, error) {
+	var nodes []T
+	var totalCount int
+	var err error
+	if a.opts.dropsNodes() {
+		// The rows are fetched in rounds, which doesn't fit the window
+		nodes, err = a.Find(ctx, after, before, orderBys, limit, fromLast)
+	} else {
+		nodes, err = limitConcurrency(ctx, a.opts.concurrencyLimiter, func() ([]T, error) {
+			return retryTransient(ctx, a.opts.transientRetry, func() ([]T, error) {
+				return findByKeyset[T](prepareDB(ctx, a.db, a.opts), after, before, orderBys, limit, fromLast, a.opts, &totalCount)
+			})
+		})
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(nodes) == 0 || a.opts.dropsNodes() {
+		// No row carries the total, e.g. if the page is beyond the end, the adapter counts separately
+		return nodes, -1, nil
+	}
+	return nodes, totalCount, nil
+}
+
+// NewKeysetAdapter creates a relay.ApplyCursorsFunc of keyset pagination,
+// requests without order bys are ordered by PrimaryKeyOrderBys.
+func NewKeysetAdapter[T any](db *gorm.DB, opts ...Option[T]) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	var finder cursor.KeysetFinder[T] = NewKeysetCounter[T](db, opts...)
+	if o.windowCount {
+		finder = NewKeysetCounterWindowed[T](db, opts...)
+	}
+	next := cursor.NewKeysetAdapter(finder, o.cursorOptions...)
+	if o.pageEnricher != nil {
+		next = enrichPages(db, next, o.pageEnricher)
+	}
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		var applied []relay.OrderBy // nil if the order bys of req are applied as they are
+		if len(req.OrderBys) == 0 || o.primaryKeyTiebreak != nil {
+			orderBys, err := keysetOrderBys[T](ctx, db, req.OrderBys, o)
+			if err != nil {
+				return nil, err
+			}
+			if len(orderBys) != len(req.OrderBys) {
+				applied = orderBys
+			}
+			r := *req
+			r.OrderBys = orderBys
+			req = &r
+		}
+		if o.uniqueOrderCheck {
+			s, err := schemaOf[T](db)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkUniqueOrder(s, req.OrderBys); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := next(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if applied != nil {
+			resp.OrderBys = applied
+		}
+		return resp, nil
+	}
+}
+
+// keysetOrderBys falls back to the primary key if orderBys is empty,
+// and appends the primary key fields missing from orderBys under WithPrimaryKeyTiebreak.
+func keysetOrderBys[T any](ctx context.Context, db *gorm.DB, orderBys []relay.OrderBy, o *options[T]) ([]relay.OrderBy, error) {
+	pk, err := PrimaryKeyOrderBys[T](db)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if len(orderBys) == 0 {
+		return pk, nil
 	}
 
-	if db.Statement.Context != ctx {
-		db = db.WithContext(ctx)
+	missing := lo.Filter(pk, func(item relay.OrderBy, _ int) bool {
+		return !lo.ContainsBy(orderBys, func(orderBy relay.OrderBy) bool { return orderBy.Field == item.Field })
+	})
+	if len(missing) == 0 {
+		return orderBys, nil
+	}
+	if warn := *o.primaryKeyTiebreak; warn != nil {
+		warn(ctx, orderBys)
 	}
 
-	if !basedOnModel && db.Statement.Model == nil {
-		var t T
-		db = db.Model(t)
+	// In the same direction, so that the row value comparison still applies
+	desc := orderBys[len(orderBys)-1].Desc
+	result := make([]relay.OrderBy, 0, len(orderBys)+len(missing))
+	result = append(result, orderBys...)
+	for _, item := range missing {
+		item.Desc = desc
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// PrimaryKeyOrderBys returns the ascending order bys of all the primary key fields of T (or db.Statement.Model) in declaration order,
+// which is a deterministic and unique order even for composite primary keys, e.g. as orderBysIfNotSet of relay.New.
+func PrimaryKeyOrderBys[T any](db *gorm.DB) ([]relay.OrderBy, error) {
+	s, err := schemaOf[T](db)
+	if err != nil {
+		return nil, err
+	}
+	if len(s.PrimaryFields) == 0 {
+		return nil, errors.Errorf("no primary key in schema %q", s.Name)
+	}
+	return lo.Map(s.PrimaryFields, func(field *schema.Field, _ int) relay.OrderBy {
+		return relay.OrderBy{Field: field.Name}
+	}), nil
+}
+
+// schemaOf returns the schema of db.Statement.Model, or else T
+func schemaOf[T any](db *gorm.DB) (*schema.Schema, error) {
+	if _, err := shouldBasedOnModel[T](db); err != nil {
+		return nil, err
 	}
 
-	var totalCount int64
-	if err := db.Count(&totalCount).Error; err != nil {
-		return 0, errors.Wrap(err, "count")
+	model := db.Statement.Model
+	if model == nil {
+		var t T
+		model = t
 	}
-	return int(totalCount), nil
+	return parseSchema(db, model)
 }
 
-func NewKeysetAdapter[T any](db *gorm.DB) relay.ApplyCursorsFunc[T] {
-	return cursor.NewKeysetAdapter(NewKeysetCounter[T](db))
+// checkNoOrderBy rejects an ORDER BY pre-applied to db, the ordering of pagination comes from orderBys only,
+// and merging both would make the rows inconsistent with the cursors.
+// Counters ignore the pre-applied ORDER BY, unless it is required by DISTINCT ON.
+func checkNoOrderBy(db *gorm.DB) error {
+	if _, ok := db.Statement.Clauses["ORDER BY"]; ok {
+		return errors.New("db must not have an ORDER BY clause, the order of pagination is specified by orderBys")
+	}
+	return nil
 }
 
 func parseSchema(db *gorm.DB, v any) (*schema.Schema, error) {
@@ -1,11 +1,21 @@
 package gormrelay
 
 import (
+	"cmp"
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"fmt"
 	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	jsoniter "github.com/json-iterator/go"
 	relay "github.com/molon/gorelay"
@@ -15,6 +25,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/theplant/testenv"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
@@ -62,6 +73,30 @@ func MustJsonString(v any) string {
 	return string(s)
 }
 
+type sqlRecorder struct {
+	logger.Interface
+	mu   sync.Mutex
+	sqls []string
+}
+
+func newSQLRecorder() *sqlRecorder {
+	return &sqlRecorder{Interface: db.Logger}
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	sql, _ := fc()
+	r.mu.Lock()
+	r.sqls = append(r.sqls, sql)
+	r.mu.Unlock()
+	r.Interface.Trace(ctx, begin, fc, err)
+}
+
+func (r *sqlRecorder) SQLs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.sqls...)
+}
+
 func mustEncodeKeysetCursor[T any](node T, keys []string) string {
 	cursor, err := cursor.EncodeKeysetCursor(node, keys)
 	if err != nil {
@@ -81,6 +116,7 @@ func TestScopeKeyset(t *testing.T) {
 				},
 				10,
 				false,
+				nil,
 			)).Find(&User{})
 			require.NoError(t, tx.Error)
 			return tx
@@ -97,6 +133,7 @@ func TestScopeKeyset(t *testing.T) {
 				},
 				10,
 				false,
+				nil,
 			)).Find(&User{})
 			require.NoError(t, tx.Error)
 			return tx
@@ -114,6 +151,7 @@ func TestScopeKeyset(t *testing.T) {
 				},
 				10,
 				false,
+				nil,
 			)).Find(&User{})
 			require.NoError(t, tx.Error)
 			return tx
@@ -131,6 +169,7 @@ func TestScopeKeyset(t *testing.T) {
 				},
 				10,
 				true, // from last
+				nil,
 			)).Find(&User{})
 			require.NoError(t, tx.Error)
 			return tx
@@ -150,12 +189,313 @@ func TestScopeKeyset(t *testing.T) {
 					},
 					10,
 					false,
+					nil,
 				)).Find(&User{})
 			require.NoError(t, tx.Error)
 			return tx
 		})
 		require.Equal(t, `SELECT * FROM "users" WHERE name LIKE 'name%' AND (("age" > 85 OR ("age" = 85 AND "name" < 'name15')) AND ("age" < 88 OR ("age" = 88 AND "name" > 'name12'))) ORDER BY "age","name" DESC LIMIT 10`, sql)
 	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with inclusive last column
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Age": 85, "Name": "name15"},
+				&map[string]interface{}{"Age": 88, "Name": "name12"},
+				[]relay.OrderBy{
+					{Field: "Age", Desc: false},
+					{Field: "Name", Desc: true},
+				},
+				10,
+				false,
+				&keysetOptions{inclusiveLastColumn: true},
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ("age" > 85 OR ("age" = 85 AND "name" <= 'name15')) AND ("age" < 88 OR ("age" = 88 AND "name" >= 'name12')) ORDER BY "age","name" DESC LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with a nullable middle column, NULLs are the largest on Postgres
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Group": 1, "Rank": nil, "ID": 3},
+				&map[string]interface{}{"Group": 2, "Rank": 5, "ID": 7},
+				[]relay.OrderBy{
+					{Field: "Group", Desc: false},
+					{Field: "Rank", Desc: false},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE ("group" > 1 OR ("group" = 1 AND "rank" IS NULL AND "id" > 3)) AND ("group" < 2 OR ("group" = 2 AND "rank" < 5) OR ("group" = 2 AND "rank" = 5 AND "id" < 7)) ORDER BY "group","rank","id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Rank": 5, "ID": 7},
+				&map[string]interface{}{"Rank": nil, "ID": 3},
+				[]relay.OrderBy{
+					{Field: "Rank", Desc: false},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE (("rank" > 5 OR "rank" IS NULL) OR ("rank" = 5 AND "id" > 7)) AND ("rank" IS NOT NULL OR ("rank" IS NULL AND "id" < 3)) ORDER BY "rank","id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// a column without the not null tag can't hold NULL if its Go type can't
+			tx = tx.Model(&untaggedUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Name": "name5", "ID": 7},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Name", Desc: false},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&untaggedUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "untagged_users" WHERE ("name" > 'name5' OR ("name" = 'name5' AND "id" > 7)) ORDER BY "name","id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx = tx.Model(&untaggedUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Name": "name5", "ID": 7},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Name", Desc: false},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				&keysetOptions{rowValues: true},
+			)).Find(&untaggedUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "untagged_users" WHERE ("name","id") > ('name5',7) ORDER BY "name","id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// NULLS FIRST, nothing is before NULLs
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Rank": 5, "ID": 7},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Rank", Desc: false, Nulls: relay.NullsFirst},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE ("rank" > 5 OR ("rank" = 5 AND "id" > 7)) ORDER BY "rank" NULLS FIRST,"id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// NULLS FIRST, every non-NULL value is after NULLs
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Rank": nil, "ID": 3},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Rank", Desc: true, Nulls: relay.NullsFirst},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE ("rank" IS NOT NULL OR ("rank" IS NULL AND "id" > 3)) ORDER BY "rank" DESC NULLS FIRST,"id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// NULLS LAST, from last with before, both the direction and the NULLs position are reversed
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Rank": 5, "ID": 7},
+				&map[string]interface{}{"Rank": nil, "ID": 3},
+				[]relay.OrderBy{
+					{Field: "Rank", Desc: true, Nulls: relay.NullsLast},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				true,
+				nil,
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE (("rank" < 5 OR "rank" IS NULL) OR ("rank" = 5 AND "id" > 7)) AND ("rank" IS NOT NULL OR ("rank" IS NULL AND "id" < 3)) ORDER BY "rank" NULLS FIRST,"id" DESC LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// row values with uniform directions
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Age": 85, "Name": "name15"},
+				&map[string]interface{}{"Age": 88, "Name": "name12"},
+				[]relay.OrderBy{
+					{Field: "Age", Desc: false},
+					{Field: "Name", Desc: false},
+				},
+				10,
+				false,
+				&keysetOptions{rowValues: true},
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ("age","name") > (85,'name15') AND ("age","name") < (88,'name12') ORDER BY "age","name" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// row values with uniform descending directions from last, inclusive
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				nil,
+				&map[string]interface{}{"Age": 85, "ID": 15},
+				[]relay.OrderBy{
+					{Field: "Age", Desc: true},
+					{Field: "ID", Desc: true},
+				},
+				10,
+				true,
+				&keysetOptions{rowValues: true, inclusiveLastColumn: true},
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ("age","id") >= (85,15) ORDER BY "age","id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// mixed directions fall back to the expansion
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Age": 85, "Name": "name15"},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Age", Desc: false},
+					{Field: "Name", Desc: true},
+				},
+				10,
+				false,
+				&keysetOptions{rowValues: true},
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ("age" > 85 OR ("age" = 85 AND "name" < 'name15')) ORDER BY "age","name" DESC LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Age": 1.23, "ID": 7},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Age", Desc: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				&keysetOptions{floatPrecisions: map[string]int{"Age": 2}},
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE (round(CAST("age" AS numeric), 2) < 1.23 OR (round(CAST("age" AS numeric), 2) = 1.23 AND "id" > 7)) ORDER BY round(CAST("age" AS numeric), 2) DESC,"id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// nullable columns fall back to the expansion
+			tx = tx.Model(&nullableUser{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Rank": 5, "ID": 7},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Rank", Desc: false, Nulls: relay.NullsFirst},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				&keysetOptions{rowValues: true},
+			)).Find(&nullableUser{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "nullable_users" WHERE ("rank" > 5 OR ("rank" = 5 AND "id" > 7)) ORDER BY "rank" NULLS FIRST,"id" LIMIT 10`, sql)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with timestamp location, the value decoded from a cursor is compared as a literal without time zone
+			tx = tx.Model(&Post{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"CreatedAt": "2024-01-02T03:04:05.123456+08:00", "ID": 3},
+				nil,
+				[]relay.OrderBy{
+					{Field: "CreatedAt", Desc: true},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				&keysetOptions{timestampLocations: map[string]*time.Location{"CreatedAt": time.UTC}},
+			)).Find(&Post{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "posts" WHERE ("created_at" < '2024-01-01 19:04:05.123456' OR ("created_at" = '2024-01-01 19:04:05.123456' AND "id" > 3)) ORDER BY "created_at" DESC,"id" LIMIT 10`, sql)
+	}
+	{
+		// with an order expression, it's on both sides, and the cursor values are still bound as parameters
+		stmt := db.Session(&gorm.Session{DryRun: true}).Model(&User{}).Scopes(scopeKeyset(
+			&map[string]interface{}{"Name": "name15", "ID": 16},
+			nil,
+			[]relay.OrderBy{
+				{Field: "Name", Desc: false},
+				{Field: "ID", Desc: false},
+			},
+			10,
+			false,
+			&keysetOptions{orderExprs: map[string]string{"Name": `LOWER("name")`}},
+		)).Find(&[]*User{}).Statement
+		require.NoError(t, stmt.Error)
+		require.Equal(t, `SELECT * FROM "users" WHERE (LOWER("name") > $1 OR (LOWER("name") = $2 AND "id" > $3)) ORDER BY LOWER("name"),"id" LIMIT $4`, stmt.SQL.String())
+		require.Equal(t, []any{"name15", "name15", 16, 10}, stmt.Vars)
+	}
+	{
+		sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			// with a collation, it's on both sides as well
+			tx = tx.Model(&User{}).Scopes(scopeKeyset(
+				&map[string]interface{}{"Name": "name15", "ID": 16},
+				nil,
+				[]relay.OrderBy{
+					{Field: "Name", Desc: false, Collate: "C"},
+					{Field: "ID", Desc: false},
+				},
+				10,
+				false,
+				nil,
+			)).Find(&User{})
+			require.NoError(t, tx.Error)
+			return tx
+		})
+		require.Equal(t, `SELECT * FROM "users" WHERE ("name" COLLATE "C" > 'name15' OR ("name" COLLATE "C" = 'name15' AND "id" > 16)) ORDER BY "name" COLLATE "C","id" LIMIT 10`, sql)
+	}
 }
 
 func TestKeysetCursor(t *testing.T) {
@@ -652,28 +992,45 @@ func TestKeysetCursor(t *testing.T) {
 		},
 	}
 
-	for _, tc := range testCases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			if tc.expectedPanic != "" {
-				require.PanicsWithValue(t, tc.expectedPanic, func() {
-					relay.New(false, tc.maxLimit, tc.limitIfNotSet, defaultOrderBys, tc.applyCursorsFunc)
-				})
-				return
-			}
+	// the same assertions against the users in memory
+	var users []*User
+	for i := 0; i < 100; i++ {
+		users = append(users, &User{ID: i + 1, Name: fmt.Sprintf("name%d", i), Age: 100 - i})
+	}
+	sliceApplyCursorsFunc := cursor.NewKeysetAdapter[*User](cursor.NewSliceKeysetFinder(users))
+
+	for name, adapterApplyCursorsFunc := range map[string]relay.ApplyCursorsFunc[*User]{
+		"DB":    applyCursorsFunc,
+		"Slice": sliceApplyCursorsFunc,
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range testCases {
+				tc := tc
+				if tc.applyCursorsFunc != nil {
+					tc.applyCursorsFunc = adapterApplyCursorsFunc
+				}
+				t.Run(tc.name, func(t *testing.T) {
+					if tc.expectedPanic != "" {
+						require.PanicsWithValue(t, tc.expectedPanic, func() {
+							relay.New(false, tc.maxLimit, tc.limitIfNotSet, defaultOrderBys, tc.applyCursorsFunc)
+						})
+						return
+					}
 
-			p := relay.New(false, tc.maxLimit, tc.limitIfNotSet, defaultOrderBys, tc.applyCursorsFunc)
-			resp, err := p.Paginate(context.Background(), tc.paginateRequest)
+					p := relay.New(false, tc.maxLimit, tc.limitIfNotSet, defaultOrderBys, tc.applyCursorsFunc)
+					resp, err := p.Paginate(context.Background(), tc.paginateRequest)
 
-			if tc.expectedError != "" {
-				require.Error(t, err)
-				require.ErrorContains(t, err, tc.expectedError)
-				return
-			}
+					if tc.expectedError != "" {
+						require.Error(t, err)
+						require.ErrorContains(t, err, tc.expectedError)
+						return
+					}
 
-			require.NoError(t, err)
-			require.Len(t, resp.Edges, tc.expectedEdgesLen)
-			require.Equal(t, tc.expectedPageInfo, resp.PageInfo)
+					require.NoError(t, err)
+					require.Len(t, resp.Edges, tc.expectedEdgesLen)
+					require.Equal(t, tc.expectedPageInfo, resp.PageInfo)
+				})
+			}
 		})
 	}
 }
@@ -681,7 +1038,7 @@ func TestKeysetCursor(t *testing.T) {
 func TestKeysetWithoutCounter(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, applyCursorFunc relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, applyCursorFunc relay.ApplyCursorsFunc[*User], afterID func(id int) string) {
 		p := relay.New(
 			false,
 			10, 10,
@@ -698,10 +1055,82 @@ func TestKeysetWithoutCounter(t *testing.T) {
 		require.Equal(t, 1, resp.Edges[0].Node.ID)
 		require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.ID)
 		require.Zero(t, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.HasNextPage)
+
+		// without a counter, the extra row fetched beyond first tells whether there is a next page
+		for _, tc := range []struct {
+			after   int
+			edges   int
+			hasNext bool
+		}{
+			{after: 89, edges: 10, hasNext: true},
+			{after: 90, edges: 10, hasNext: false},
+			{after: 95, edges: 5, hasNext: false},
+		} {
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(afterID(tc.after)),
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, tc.edges)
+			require.Equal(t, tc.after+1, resp.Edges[0].Node.ID)
+			require.Equal(t, tc.hasNext, resp.PageInfo.HasNextPage)
+			require.True(t, resp.PageInfo.HasPreviousPage)
+			require.Zero(t, resp.PageInfo.TotalCount)
+		}
 	}
 
-	t.Run("keyset", func(t *testing.T) { testCase(t, cursor.NewKeysetAdapter(NewKeysetFinder[*User](db))) })
-	t.Run("offset", func(t *testing.T) { testCase(t, cursor.NewOffsetAdapter(NewOffsetFinder[*User](db))) })
+	t.Run("keyset", func(t *testing.T) {
+		testCase(t, cursor.NewKeysetAdapter(NewKeysetFinder[*User](db)), func(id int) string {
+			return mustEncodeKeysetCursor(&User{ID: id}, []string{"ID"})
+		})
+	})
+	t.Run("offset", func(t *testing.T) {
+		testCase(t, cursor.NewOffsetAdapter(NewOffsetFinder[*User](db)), func(id int) string {
+			return cursor.EncodeOffsetCursor(id - 1)
+		})
+	})
+}
+
+func TestKeysetWithoutCounterEmptyTable(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DELETE FROM users").Error)
+
+	after := lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"}))
+	before := lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 10}, []string{"ID"}))
+	for _, tc := range []struct {
+		name string
+		req  *relay.PaginateRequest[*User]
+		// the flags by default, where a cursor is assumed to have rows beyond it
+		hasPrevious, hasNext bool
+	}{
+		{name: "First", req: &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}},
+		{name: "Last", req: &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)}},
+		{name: "AfterFirst", req: &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(10)}, hasPrevious: true},
+		{name: "AfterLast", req: &relay.PaginateRequest[*User]{After: after, Last: lo.ToPtr(10)}, hasPrevious: true},
+		{name: "BeforeFirst", req: &relay.PaginateRequest[*User]{Before: before, First: lo.ToPtr(10)}, hasNext: true},
+		{name: "BeforeLast", req: &relay.PaginateRequest[*User]{Before: before, Last: lo.ToPtr(10)}, hasNext: true},
+		{name: "AfterBefore", req: &relay.PaginateRequest[*User]{After: after, Before: before, First: lo.ToPtr(10)}, hasPrevious: true, hasNext: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, exact := range []bool{false, true} {
+				var opts []cursor.Option
+				if exact {
+					opts = append(opts, cursor.WithExactCursorExistence())
+				}
+				p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, cursor.NewKeysetAdapter(NewKeysetFinder[*User](db), opts...))
+				resp, err := p.Paginate(context.Background(), tc.req)
+				require.NoError(t, err)
+				require.Empty(t, resp.Edges)
+				require.Zero(t, resp.PageInfo.TotalCount)
+				// the cursors are checked to have no rows beyond them under WithExactCursorExistence
+				require.Equal(t, tc.hasPrevious && !exact, resp.PageInfo.HasPreviousPage)
+				require.Equal(t, tc.hasNext && !exact, resp.PageInfo.HasNextPage)
+				require.Nil(t, resp.PageInfo.StartCursor)
+				require.Nil(t, resp.PageInfo.EndCursor)
+			}
+		})
+	}
 }
 
 func TestUnexpectOrderBys(t *testing.T) {
@@ -734,12 +1163,59 @@ func TestUnexpectOrderBys(t *testing.T) {
 	require.Nil(t, resp)
 }
 
-func TestContext(t *testing.T) {
+func TestEmptyOrderBys(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
-		{
-			p := relay.New(
+	newPagination := func(opts ...relay.Option) relay.Pagination[*User] {
+		return relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "Age", Desc: false},
+			},
+			NewKeysetAdapter[*User](db),
+			opts...,
+		)
+	}
+
+	firstID := func(t *testing.T, p relay.Pagination[*User], orderBys []relay.OrderBy) int {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: orderBys,
+		})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 5)
+		return resp.Edges[0].Node.ID
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		p := newPagination()
+		// nil uses the default order
+		require.Equal(t, 100, firstID(t, p, nil))
+		require.Equal(t, 100, firstID(t, p, []relay.OrderBy{{Field: "ID", Desc: true}}))
+
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{},
+		})
+		require.ErrorContains(t, err, "orderBys must not be empty, leave it nil to use the default")
+		require.Nil(t, resp)
+	})
+
+	t.Run("PrimaryKeyOnly", func(t *testing.T) {
+		p := newPagination(relay.WithEmptyOrderBys(relay.OrderBy{Field: "ID", Desc: false}))
+		require.Equal(t, 100, firstID(t, p, nil))
+		require.Equal(t, 1, firstID(t, p, []relay.OrderBy{}))
+		require.Equal(t, 100, firstID(t, p, []relay.OrderBy{{Field: "ID", Desc: true}}))
+	})
+}
+
+func TestContext(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]) {
+		{
+			p := relay.New(
 				false,
 				10, 10,
 				[]relay.OrderBy{
@@ -794,57 +1270,15 @@ func TestWrapEncrypt(t *testing.T) {
 	resetDB(t)
 
 	testCase := func(t *testing.T, w func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User]) {
-		p := relay.New(
-			false,
-			10, 10,
-			[]relay.OrderBy{
-				{Field: "ID", Desc: false},
-			},
-			func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
-				return w(NewKeysetAdapter[*User](db))(ctx, req)
-			},
-		)
-		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
-			First: lo.ToPtr(10),
-		})
-		require.NoError(t, err)
-		require.Len(t, resp.Edges, 10)
-		require.Equal(t, 1, resp.Edges[0].Node.ID)
-		require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.ID)
-		require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-		require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
-
-		// next page
-		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
-			First: lo.ToPtr(5),
-			After: resp.PageInfo.EndCursor,
-		})
-		require.NoError(t, err)
-		require.Len(t, resp.Edges, 5)
-		require.Equal(t, 11, resp.Edges[0].Node.ID)
-		require.Equal(t, 15, resp.Edges[len(resp.Edges)-1].Node.ID)
-		require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-		require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
-
-		// prev page
-		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
-			Last:   lo.ToPtr(6),
-			Before: resp.PageInfo.StartCursor,
-		})
-		require.NoError(t, err)
-		require.Len(t, resp.Edges, 6)
-		require.Equal(t, 5, resp.Edges[0].Node.ID)
-		require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.ID)
-		require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-		require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
-
-		// invalid after cursor
-		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
-			First: lo.ToPtr(5),
-			After: lo.ToPtr("invalid"),
-		})
-		require.ErrorContains(t, err, "invalid after cursor")
-		require.Nil(t, resp)
+		for name, adapter := range map[string]relay.ApplyCursorsFunc[*User]{
+			"Keyset": NewKeysetAdapter[*User](db),
+			// offset cursors are plain offsets, so they are encoded by the same wrappers
+			"Offset": NewOffsetAdapter[*User](db),
+		} {
+			t.Run(name, func(t *testing.T) {
+				testWrappedCursors(t, w(adapter))
+			})
+		}
 	}
 
 	t.Run("WrapBase64", func(t *testing.T) {
@@ -895,180 +1329,3164 @@ func TestWrapEncrypt(t *testing.T) {
 	})
 }
 
-func TestNodesOnly(t *testing.T) {
-	resetDB(t)
-
+// testWrappedCursors paginates back and forth by the cursors of applyCursorsFunc, which wraps an adapter of users by ID
+func testWrappedCursors(t *testing.T, applyCursorsFunc relay.ApplyCursorsFunc[*User]) {
 	p := relay.New(
-		true,
+		false,
 		10, 10,
 		[]relay.OrderBy{
 			{Field: "ID", Desc: false},
 		},
-		func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
-			return NewKeysetAdapter[*User](db)(ctx, req)
-		},
+		applyCursorsFunc,
 	)
 	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
 		First: lo.ToPtr(10),
 	})
 	require.NoError(t, err)
-	require.Equal(t, 100, resp.PageInfo.TotalCount)
-	require.NotNil(t, resp.PageInfo.StartCursor)
-	require.NotNil(t, resp.PageInfo.EndCursor)
-	require.Len(t, resp.Edges, 0)
-	require.Len(t, resp.Nodes, 10)
-	require.Equal(t, 1, resp.Nodes[0].ID)
-	require.Equal(t, 10, resp.Nodes[len(resp.Nodes)-1].ID)
+	require.Len(t, resp.Edges, 10)
+	require.Equal(t, 1, resp.Edges[0].Node.ID)
+	require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.ID)
+	require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+	require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+
+	// next page
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+		After: resp.PageInfo.EndCursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 5)
+	require.Equal(t, 11, resp.Edges[0].Node.ID)
+	require.Equal(t, 15, resp.Edges[len(resp.Edges)-1].Node.ID)
+	require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+	require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+
+	// prev page
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Last:   lo.ToPtr(6),
+		Before: resp.PageInfo.StartCursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 6)
+	require.Equal(t, 5, resp.Edges[0].Node.ID)
+	require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.ID)
+	require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+	require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+
+	// invalid after cursor
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+		After: lo.ToPtr("invalid"),
+	})
+	require.ErrorContains(t, err, "invalid after cursor")
+	require.Nil(t, resp)
 }
 
-func TestKeysetGenericTypeAny(t *testing.T) {
+func TestWrapAESKeyring(t *testing.T) {
 	resetDB(t)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[any]) {
-		t.Run("Right", func(t *testing.T) {
-			p := relay.New(
-				false,
-				10, 10,
-				[]relay.OrderBy{
-					{Field: "ID", Desc: false},
-				}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
-					// This is a generic(T: any) function, so we need to call db.Model(x)
-					return f(db.Model(&User{}))(ctx, req)
-				},
-			)
-			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-				First: lo.ToPtr(10),
-			})
-			require.NoError(t, err)
-			require.Len(t, resp.Edges, 10)
-			require.Equal(t, 1, resp.Edges[0].Node.(*User).ID)
-			require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
-			require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-			require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+	oldKey, err := generateAESKey(32)
+	require.NoError(t, err)
+	newKey, err := generateAESKey(32)
+	require.NoError(t, err)
 
-			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-				Last: lo.ToPtr(10),
-			})
-			require.NoError(t, err)
-			require.Len(t, resp.Edges, 10)
-			require.Equal(t, 91, resp.Edges[0].Node.(*User).ID)
-			require.Equal(t, 100, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
-			require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-			require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
-		})
-		t.Run("Wrong", func(t *testing.T) {
-			p := relay.New(
-				false,
-				10, 10,
-				[]relay.OrderBy{
-					{Field: "ID", Desc: false},
-				}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
-					// This is wrong, we need to call db.Model(x) for generic(T: any) function
-					return f(db)(ctx, req)
-				},
-			)
-			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-				First: lo.ToPtr(10),
-			})
-			require.ErrorContains(t, err, "db.Statement.Model is nil and T is not a struct or struct pointer")
-			require.Nil(t, resp)
-		})
+	paginator := func(f func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User]) *relay.Paginator[*User] {
+		return relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, f(NewKeysetAdapter[*User](db)))
+	}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
 	}
 
-	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
-	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+	// issued before the rotation
+	resp, err := paginator(func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
+		return cursor.WrapAES(next, oldKey)
+	}).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	oldCursor := resp.PageInfo.EndCursor
 
-	anotherTestCase := func(t *testing.T, applyCursorsFunc relay.ApplyCursorsFunc[any]) {
-		t.Run("Wrong(WithoutCounter)", func(t *testing.T) {
-			p := relay.New(
-				false,
-				10, 10,
-				[]relay.OrderBy{
-					{Field: "ID", Desc: false},
+	rotated := paginator(func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
+		return cursor.WrapAESKeyring(next, newKey, oldKey)
+	})
+
+	// the old cursor still paginates, and the new cursors are encrypted with the new key
+	resp, err = rotated.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, []int{6, 7, 8, 9, 10}, ids(resp))
+	resp, err = rotated.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: resp.PageInfo.EndCursor, Last: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{8, 9}, ids(resp))
+
+	newOnly := paginator(func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
+		return cursor.WrapAES(next, newKey)
+	})
+	resp, err = newOnly.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.Equal(t, []int{10}, ids(resp))
+
+	// once the old key is dropped, the old cursor is invalid
+	_, err = newOnly.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5)})
+	require.ErrorContains(t, err, "invalid after cursor")
+
+	otherKey, err := generateAESKey(32)
+	require.NoError(t, err)
+	_, err = paginator(func(next relay.ApplyCursorsFunc[*User]) relay.ApplyCursorsFunc[*User] {
+		return cursor.WrapAESKeyring(next, newKey, otherKey)
+	}).Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: oldCursor, Last: lo.ToPtr(5)})
+	require.ErrorContains(t, err, "invalid before cursor")
+	require.ErrorIs(t, err, relay.ErrInvalidCursor)
+}
+
+func TestSentinelErrors(t *testing.T) {
+	resetDB(t)
+
+	for _, tc := range []struct {
+		name      string
+		useKeyset bool
+	}{
+		{name: "Keyset", useKeyset: true},
+		{name: "Offset", useKeyset: false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := relay.New(false, 10, 10,
+				[]relay.OrderBy{{Field: "ID", Desc: false}},
+				func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
+					if tc.useKeyset {
+						return NewKeysetAdapter[*User](db)(ctx, req)
+					}
+					return NewOffsetAdapter[*User](db)(ctx, req)
 				},
-				applyCursorsFunc,
 			)
-			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-				First: lo.ToPtr(10),
-			})
-			require.ErrorContains(t, err, "db.Statement.Model is nil and T is not a struct or struct pointer")
-			require.Nil(t, resp)
+
+			for _, c := range []struct {
+				req      *relay.PaginateRequest[*User]
+				sentinel error
+				message  string
+			}{
+				{
+					req:      &relay.PaginateRequest[*User]{First: lo.ToPtr(1), Last: lo.ToPtr(1)},
+					sentinel: relay.ErrFirstLastTogether,
+					message:  "first and last cannot be used together",
+				},
+				{
+					req:      &relay.PaginateRequest[*User]{First: lo.ToPtr(-1)},
+					sentinel: relay.ErrInvalidLimit,
+					message:  "first must be a non-negative integer",
+				},
+				{
+					req:      &relay.PaginateRequest[*User]{First: lo.ToPtr(11)},
+					sentinel: relay.ErrLimitExceeded,
+					message:  "first must be less than or equal to max limit",
+				},
+				{
+					req:      &relay.PaginateRequest[*User]{First: lo.ToPtr(1), OrderBys: []relay.OrderBy{{Field: "ID"}, {Field: "ID", Desc: true}}},
+					sentinel: relay.ErrInvalidOrderBy,
+					message:  "duplicated order by fields [ID]",
+				},
+				{
+					req:      &relay.PaginateRequest[*User]{First: lo.ToPtr(1), After: lo.ToPtr("malformed")},
+					sentinel: relay.ErrInvalidCursor,
+				},
+			} {
+				resp, err := p.Paginate(context.Background(), c.req)
+				require.Nil(t, resp)
+				require.ErrorIs(t, err, c.sentinel)
+				require.ErrorContains(t, err, c.message)
+			}
 		})
 	}
-
-	// This is wrong, we need to call db.Model(x) for generic(T: any) function
-	t.Run("keyset", func(t *testing.T) { anotherTestCase(t, cursor.NewKeysetAdapter(NewKeysetFinder[any](db))) })
-	t.Run("offset", func(t *testing.T) { anotherTestCase(t, cursor.NewOffsetAdapter(NewOffsetFinder[any](db))) })
 }
 
-func TestKeysetInvalidCursor(t *testing.T) {
+func TestNodesOnly(t *testing.T) {
 	resetDB(t)
 
 	p := relay.New(
-		false,
+		true,
 		10, 10,
 		[]relay.OrderBy{
 			{Field: "ID", Desc: false},
-		}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
-			// This is a generic(T: any) function, so we need to cast the model to the correct type
-			return NewKeysetAdapter[any](db.Model(&User{}))(ctx, req)
+		},
+		func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
+			return NewKeysetAdapter[*User](db)(ctx, req)
 		},
 	)
-	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-		After: lo.ToPtr(`{"ID":1}`),
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
 		First: lo.ToPtr(10),
 	})
 	require.NoError(t, err)
-	require.Len(t, resp.Edges, 10)
-	require.Equal(t, 1+1, resp.Edges[0].Node.(*User).ID)
-	require.Equal(t, 10+1, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
-	require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
-	require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
-
-	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-		After: lo.ToPtr(`{"FieldNotExists":1}`),
-		First: lo.ToPtr(10),
-	})
-	require.ErrorContains(t, err, `key "ID" not found in cursor`)
-	require.Nil(t, resp)
-
-	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-		After: lo.ToPtr(`{"ID":1,"Name":"name0"}`),
-		First: lo.ToPtr(10),
-	})
-	require.ErrorContains(t, err, `cursor length != keys length`)
-	require.Nil(t, resp)
-
-	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
-		Before: lo.ToPtr(`invalid`),
-		First:  lo.ToPtr(10),
-	})
-	require.ErrorContains(t, err, `unmarshal cursor`)
-	require.Nil(t, resp)
+	require.Equal(t, 100, resp.PageInfo.TotalCount)
+	require.NotNil(t, resp.PageInfo.StartCursor)
+	require.NotNil(t, resp.PageInfo.EndCursor)
+	require.Len(t, resp.Edges, 0)
+	require.Len(t, resp.Nodes, 10)
+	require.Equal(t, 1, resp.Nodes[0].ID)
+	require.Equal(t, 10, resp.Nodes[len(resp.Nodes)-1].ID)
 }
 
-func TestTotalCountZero(t *testing.T) {
+func TestCompactPageInfo(t *testing.T) {
 	resetDB(t)
-	require.NoError(t, db.Exec("DELETE FROM users").Error)
 
-	testCase := func(t *testing.T, f func(db *gorm.DB) relay.ApplyCursorsFunc[*User]) {
+	testCase := func(t *testing.T, nodesOnly bool, expectedEncodes int) {
+		encodes := 0
 		p := relay.New(
-			false,
+			nodesOnly,
 			10, 10,
 			[]relay.OrderBy{
 				{Field: "ID", Desc: false},
 			},
 			func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
-				return f(db)(ctx, req)
+				resp, err := NewKeysetAdapter[*User](db)(ctx, req)
+				if err != nil {
+					return nil, err
+				}
+				for i := range resp.Edges {
+					edge := &resp.Edges[i]
+					originalCursor := edge.Cursor
+					edge.Cursor = func(ctx context.Context, node *User) (string, error) {
+						encodes++
+						return originalCursor(ctx, node)
+					}
+				}
+				return resp, nil
 			},
+			relay.WithCompactPageInfo(),
 		)
 		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"})),
 			First: lo.ToPtr(10),
 		})
 		require.NoError(t, err)
-		require.Equal(t, 0, resp.PageInfo.TotalCount)
+		require.Equal(t, expectedEncodes, encodes)
+		require.Equal(t, relay.PageInfo{
+			TotalCount:      100,
+			HasNextPage:     true,
+			HasPreviousPage: true,
+			StartCursor:     nil,
+			EndCursor:       nil,
+		}, resp.PageInfo)
+		if nodesOnly {
+			require.Len(t, resp.Nodes, 10)
+			require.Equal(t, 6, resp.Nodes[0].ID)
+			require.Equal(t, 15, resp.Nodes[len(resp.Nodes)-1].ID)
+		} else {
+			require.Len(t, resp.Edges, 10)
+			require.Equal(t, 6, resp.Edges[0].Node.ID)
+			require.Equal(t, 15, resp.Edges[len(resp.Edges)-1].Node.ID)
+		}
 	}
 
-	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
-	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+	t.Run("NodesOnly", func(t *testing.T) { testCase(t, true, 0) })
+	t.Run("Edges", func(t *testing.T) { testCase(t, false, 10) })
+}
+
+func TestPaginatorAll(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		50, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+	)
+
+	walk := func(req *relay.PaginateRequest[*User], opts ...relay.WalkOption) (ids []int, completes []bool) {
+		for page, err := range p.All(context.Background(), req, opts...) {
+			require.NoError(t, err)
+			for _, edge := range page.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+			completes = append(completes, page.Complete)
+		}
+		return ids, completes
+	}
+
+	t.Run("Complete", func(t *testing.T) {
+		ids, completes := walk(&relay.PaginateRequest[*User]{First: lo.ToPtr(30)}, relay.WithMaxPages(4))
+		require.Equal(t, lo.RangeFrom(1, 100), ids)
+		require.Equal(t, []bool{false, false, false, true}, completes)
+
+		ids, completes = walk(&relay.PaginateRequest[*User]{Last: lo.ToPtr(40)})
+		require.Equal(t, append(append(lo.RangeFrom(61, 40), lo.RangeFrom(21, 40)...), lo.RangeFrom(1, 20)...), ids)
+		require.Equal(t, []bool{false, false, true}, completes)
+	})
+
+	t.Run("Seeded", func(t *testing.T) {
+		ids, completes := walk(&relay.PaginateRequest[*User]{
+			After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 10}, []string{"ID"})),
+			First: lo.ToPtr(40),
+		})
+		require.Equal(t, lo.RangeFrom(11, 90), ids)
+		require.Equal(t, []bool{false, false, true}, completes)
+
+		ids, completes = walk(&relay.PaginateRequest[*User]{
+			Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 91}, []string{"ID"})),
+			Last:   lo.ToPtr(40),
+		})
+		require.Equal(t, append(append(lo.RangeFrom(51, 40), lo.RangeFrom(11, 40)...), lo.RangeFrom(1, 10)...), ids)
+		require.Equal(t, []bool{false, false, true}, completes)
+	})
+
+	t.Run("Bounded", func(t *testing.T) {
+		cursor := lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 50}, []string{"ID"}))
+		for _, req := range []*relay.PaginateRequest[*User]{
+			{Before: cursor, First: lo.ToPtr(10)},
+			{After: cursor, Last: lo.ToPtr(10)},
+		} {
+			var pages int
+			var lastErr error
+			for _, err := range p.All(context.Background(), req) {
+				pages++
+				lastErr = err
+			}
+			require.Equal(t, 1, pages)
+			require.ErrorContains(t, lastErr, "All can't be bounded by before when walking forward, or by after when walking backward")
+		}
+	})
+
+	t.Run("TruncatedByBudget", func(t *testing.T) {
+		ids, completes := walk(&relay.PaginateRequest[*User]{First: lo.ToPtr(30)}, relay.WithMaxPages(3))
+		require.Equal(t, lo.RangeFrom(1, 90), ids)
+		require.Equal(t, []bool{false, false, false}, completes)
+	})
+
+	t.Run("Break", func(t *testing.T) {
+		pages := 0
+		for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}) {
+			require.NoError(t, err)
+			require.False(t, page.Complete)
+			pages++
+			break
+		}
+		require.Equal(t, 1, pages)
+	})
+
+	t.Run("ContextCanceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var pages int
+		var lastErr error
+		for page, err := range p.All(ctx, &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}) {
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			require.False(t, page.Complete)
+			pages++
+			cancel()
+		}
+		require.Equal(t, 1, pages)
+		require.ErrorIs(t, lastErr, context.Canceled)
+	})
+
+	t.Run("CompactPageInfo", func(t *testing.T) {
+		p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db), relay.WithCompactPageInfo())
+		var lastErr error
+		for _, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}) {
+			lastErr = err
+		}
+		require.ErrorContains(t, lastErr, "cursor of the subsequent page is not available")
+	})
+}
+
+func TestAppliedLimit(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		20, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+	)
+
+	testCases := []struct {
+		name                 string
+		req                  *relay.PaginateRequest[*User]
+		expectedAppliedLimit int
+		expectedEdgesLen     int
+		expectedError        string
+	}{
+		{
+			name:                 "Defaulted",
+			req:                  &relay.PaginateRequest[*User]{},
+			expectedAppliedLimit: 10,
+			expectedEdgesLen:     10,
+		},
+		{
+			name: "DefaultedBackward",
+			req: &relay.PaginateRequest[*User]{
+				Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"})),
+			},
+			expectedAppliedLimit: 10,
+			expectedEdgesLen:     4,
+		},
+		{
+			name:                 "Explicit",
+			req:                  &relay.PaginateRequest[*User]{Last: lo.ToPtr(15)},
+			expectedAppliedLimit: 15,
+			expectedEdgesLen:     15,
+		},
+		{
+			name:                 "Zero",
+			req:                  &relay.PaginateRequest[*User]{First: lo.ToPtr(0)},
+			expectedAppliedLimit: 0,
+			expectedEdgesLen:     0,
+		},
+		{
+			name: "Exhausted",
+			req: &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 95}, []string{"ID"})),
+				First: lo.ToPtr(20),
+			},
+			expectedAppliedLimit: 20,
+			expectedEdgesLen:     5,
+		},
+		{
+			// requests over the max limit are rejected rather than clamped
+			name:          "OverMaxLimit",
+			req:           &relay.PaginateRequest[*User]{First: lo.ToPtr(21)},
+			expectedError: "first must be less than or equal to max limit",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := p.Paginate(context.Background(), tc.req)
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAppliedLimit, resp.AppliedLimit)
+			require.Len(t, resp.Edges, tc.expectedEdgesLen)
+		})
+	}
+}
+
+func TestLimitNegotiator(t *testing.T) {
+	resetDB(t)
+
+	var underLoad atomic.Bool
+	negotiate := func(requested int) int {
+		if underLoad.Load() {
+			return requested / 2
+		}
+		return requested
+	}
+
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]{
+		"Keyset": NewKeysetAdapter[*User],
+		"Offset": NewOffsetAdapter[*User],
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := relay.New(false, 20, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, f(db), relay.WithLimitNegotiator(negotiate))
+
+			ids := func(resp *relay.PaginateResponse[*User]) []int {
+				return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+			}
+
+			underLoad.Store(false)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(8)})
+			require.NoError(t, err)
+			require.Equal(t, 8, resp.AppliedLimit)
+			require.Equal(t, lo.RangeFrom(1, 8), ids(resp))
+
+			underLoad.Store(true)
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(8)})
+			require.NoError(t, err)
+			require.Equal(t, 4, resp.AppliedLimit)
+			require.Equal(t, lo.RangeFrom(1, 4), ids(resp))
+			require.True(t, resp.PageInfo.HasNextPage)
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(8)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(5, 4), ids(resp))
+
+			// limitIfNotSet is negotiated as well
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(6)})
+			require.NoError(t, err)
+			require.Equal(t, 3, resp.AppliedLimit)
+			require.Equal(t, []int{98, 99, 100}, ids(resp))
+			require.True(t, resp.PageInfo.HasPreviousPage)
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+			require.NoError(t, err)
+			require.Equal(t, 5, resp.AppliedLimit)
+
+			// the requested limit is still validated
+			_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(30)})
+			require.ErrorContains(t, err, "first must be less than or equal to max limit")
+		})
+	}
+
+	// the negotiated limit is bounded by maxLimit
+	p := relay.New(false, 20, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, NewKeysetAdapter[*User](db),
+		relay.WithLimitNegotiator(func(requested int) int { return requested * 3 }),
+	)
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, 20, resp.AppliedLimit)
+	require.Len(t, resp.Edges, 20)
+}
+
+func TestResponseMeta(t *testing.T) {
+	resetDB(t)
+
+	defaultOrderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]{
+		"Keyset": NewKeysetAdapter[*User],
+		"Offset": NewOffsetAdapter[*User],
+	} {
+		t.Run(name, func(t *testing.T) {
+			// without the option, there is no meta
+			resp, err := relay.New(false, 20, 10, defaultOrderBys, f(db)).Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+			require.NoError(t, err)
+			require.Nil(t, resp.Meta)
+
+			p := relay.New(false, 20, 10, defaultOrderBys, f(db),
+				relay.WithResponseMeta(),
+				relay.WithLimitNegotiator(func(requested int) int { return min(requested, 15) }),
+			)
+
+			// defaults
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+			require.NoError(t, err)
+			require.Equal(t, &relay.ResponseMeta{
+				AppliedOrderBys:   defaultOrderBys,
+				OrderBysDefaulted: true,
+				AppliedLimit:      10,
+			}, resp.Meta)
+
+			endCursor := resp.PageInfo.EndCursor
+
+			// overrides
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				Last:           lo.ToPtr(5),
+				OrderBys:       []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID", Desc: false}},
+				SkipTotalCount: true,
+			})
+			require.NoError(t, err)
+			require.Equal(t, &relay.ResponseMeta{
+				AppliedOrderBys: []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID", Desc: false}},
+				RequestedLimit:  lo.ToPtr(5),
+				AppliedLimit:    5,
+				Backward:        true,
+				SkipTotalCount:  true,
+			}, resp.Meta)
+
+			// clamped by the negotiator
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: endCursor, First: lo.ToPtr(20)})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, 15)
+			require.True(t, resp.Meta.HasAfter)
+			require.False(t, resp.Meta.HasBefore)
+			require.Equal(t, lo.ToPtr(20), resp.Meta.RequestedLimit)
+			require.Equal(t, 15, resp.Meta.AppliedLimit)
+			require.Equal(t, resp.AppliedLimit, resp.Meta.AppliedLimit)
+
+			// the default order bys are not shared with the meta
+			resp.Meta.AppliedOrderBys[0].Desc = true
+			require.False(t, defaultOrderBys[0].Desc)
+		})
+	}
+
+	t.Run("PrimaryKeyTiebreak", func(t *testing.T) {
+		recorder := newSQLRecorder()
+		p := relay.New(false, 20, 10, defaultOrderBys,
+			NewKeysetAdapter(db.Session(&gorm.Session{Logger: recorder}), WithPrimaryKeyTiebreak[*User](nil)),
+			relay.WithResponseMeta(),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}},
+		})
+		require.NoError(t, err)
+		// as appended to the query
+		require.Equal(t, []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID", Desc: true}}, resp.Meta.AppliedOrderBys)
+		require.True(t, resp.Meta.OrderBysDefaulted)
+		require.True(t, lo.SomeBy(recorder.SQLs(), func(sql string) bool {
+			return regexp.MustCompile(`ORDER BY .age. DESC,.id. DESC`).MatchString(sql)
+		}))
+
+		// the order bys including the primary key are applied as they are
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID"}},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID"}}, resp.Meta.AppliedOrderBys)
+		require.False(t, resp.Meta.OrderBysDefaulted)
+	})
+}
+
+func TestStrictInvariants(t *testing.T) {
+	resetDB(t)
+
+	// a custom finder which ignores the limit
+	finder := cursor.KeysetFinderFunc[*User](func(ctx context.Context, after, before *map[string]any, orderBys []relay.OrderBy, limit int, fromLast bool) ([]*User, error) {
+		return NewKeysetFinder[*User](db).Find(ctx, after, before, orderBys, limit+5, fromLast)
+	})
+	orderBys := []relay.OrderBy{
+		{Field: "ID", Desc: false},
+	}
+
+	// without the checks, the extra rows are trimmed silently
+	p := relay.New(false, 10, 10, orderBys, cursor.NewKeysetAdapter[*User](finder))
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 3)
+
+	p = relay.New(false, 10, 10, orderBys, cursor.NewKeysetAdapter[*User](finder), relay.WithStrictInvariants())
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(3)})
+	require.ErrorIs(t, err, relay.ErrInvariantViolated)
+	require.ErrorContains(t, err, "9 edges returned for limit 4")
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.ErrorIs(t, err, relay.ErrInvariantViolated)
+	require.ErrorContains(t, err, "16 edges returned for limit 11")
+
+	// a well-behaved finder passes the checks
+	p = relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db), relay.WithStrictInvariants())
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 10)
+	require.True(t, resp.PageInfo.HasNextPage)
+}
+
+func TestPaginateToMap(t *testing.T) {
+	resetDB(t)
+
+	byID := func(u *User) int {
+		return u.ID
+	}
+	for _, nodesOnly := range []bool{false, true} {
+		p := relay.New(nodesOnly, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db))
+
+		m, pageInfo, err := relay.PaginateToMap(context.Background(), p, &relay.PaginateRequest[*User]{First: lo.ToPtr(5)}, byID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []int{1, 2, 3, 4, 5}, lo.Keys(m))
+		for id, u := range m {
+			require.Equal(t, id, u.ID)
+		}
+		require.True(t, pageInfo.HasNextPage)
+		require.Equal(t, 100, pageInfo.TotalCount)
+
+		m, _, err = relay.PaginateToMap(context.Background(), p, &relay.PaginateRequest[*User]{
+			After: pageInfo.EndCursor,
+			First: lo.ToPtr(5),
+		}, byID)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []int{6, 7, 8, 9, 10}, lo.Keys(m))
+	}
+
+	// users share ages
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 3").Error)
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db))
+	_, _, err := relay.PaginateToMap(context.Background(), p, &relay.PaginateRequest[*User]{First: lo.ToPtr(5)}, func(u *User) int {
+		return u.Age
+	})
+	require.ErrorContains(t, err, "duplicated key 1")
+}
+
+func TestRequireExplicitLimit(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		10, 0, // no default limit
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+		relay.WithRequireExplicitLimit(),
+	)
+
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+	require.ErrorIs(t, err, relay.ErrLimitRequired)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"})),
+	})
+	require.ErrorIs(t, err, relay.ErrLimitRequired)
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 5)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Last: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 5)
+	require.Equal(t, 96, resp.Edges[0].Node.ID)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(11),
+	})
+	require.ErrorContains(t, err, "first must be less than or equal to max limit")
+
+	require.PanicsWithValue(t, "maxLimit must be greater than 0", func() {
+		relay.New(false, 0, 0, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db), relay.WithRequireExplicitLimit())
+	})
+}
+
+func TestDirectionLimits(t *testing.T) {
+	resetDB(t)
+
+	after := mustEncodeKeysetCursor(&User{ID: 50}, []string{"ID"})
+	before := mustEncodeKeysetCursor(&User{ID: 51}, []string{"ID"})
+	newPaginator := func(opts ...relay.Option) relay.Pagination[*User] {
+		return relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db), opts...)
+	}
+
+	t.Run("Forward", func(t *testing.T) {
+		p := newPaginator(relay.WithForwardLimits(20, 3))
+
+		// the default of the initial load is smaller
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 3)
+
+		// but the ceiling of explicit page sizes is larger
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(after), First: lo.ToPtr(20)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 20)
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(21)})
+		require.ErrorIs(t, err, relay.ErrLimitExceeded)
+
+		// backward paging keeps the shared limits
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: lo.ToPtr(before)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Equal(t, 41, resp.Edges[0].Node.ID)
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(11)})
+		require.ErrorIs(t, err, relay.ErrLimitExceeded)
+		require.ErrorContains(t, err, "last must be less than or equal to max limit")
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		p := newPaginator(relay.WithBackwardLimits(5, 2))
+
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: lo.ToPtr(before)})
+		require.NoError(t, err)
+		require.Equal(t, []int{49, 50}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 5)
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(6)})
+		require.ErrorIs(t, err, relay.ErrLimitExceeded)
+
+		// forward paging keeps the shared limits
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(11)})
+		require.ErrorIs(t, err, relay.ErrLimitExceeded)
+	})
+
+	t.Run("Negotiated", func(t *testing.T) {
+		p := newPaginator(relay.WithForwardLimits(20, 10), relay.WithBackwardLimits(4, 4), relay.WithLimitNegotiator(func(requested int) int { return requested * 2 }))
+
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(8)})
+		require.NoError(t, err)
+		require.Equal(t, 16, resp.AppliedLimit)
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(3)})
+		require.NoError(t, err)
+		require.Equal(t, 4, resp.AppliedLimit)
+	})
+
+	require.PanicsWithValue(t, "forward maxLimit must be greater than or equal to limitIfNotSet", func() {
+		newPaginator(relay.WithForwardLimits(5, 6))
+	})
+	require.PanicsWithValue(t, "backward limitIfNotSet must be greater than 0", func() {
+		newPaginator(relay.WithBackwardLimits(5, 0))
+	})
+	require.PanicsWithValue(t, "backward maxLimit must be greater than 0", func() {
+		relay.New(false, 10, 0, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db), relay.WithRequireExplicitLimit(), relay.WithBackwardLimits(0, 0))
+	})
+}
+
+func TestOrderByCollate(t *testing.T) {
+	resetDB(t)
+
+	// bytewise, e.g. "name10" < "name2"
+	names := lo.Map(lo.Range(100), func(i int, _ int) string { return fmt.Sprintf("name%d", i) })
+	slices.Sort(names)
+	orderBys := []relay.OrderBy{{Field: "Name", Collate: "C"}}
+	for _, newAdapter := range []func() relay.ApplyCursorsFunc[*User]{
+		func() relay.ApplyCursorsFunc[*User] { return NewKeysetAdapter[*User](db) },
+		func() relay.ApplyCursorsFunc[*User] { return NewOffsetAdapter[*User](db) },
+	} {
+		p := relay.New(false, 30, 30, orderBys, newAdapter())
+		var got []string
+		var after *string
+		for {
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: after, First: lo.ToPtr(30)})
+			require.NoError(t, err)
+			got = append(got, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) string { return edge.Node.Name })...)
+			if !resp.PageInfo.HasNextPage {
+				break
+			}
+			after = resp.PageInfo.EndCursor
+		}
+		require.Equal(t, names, got)
+	}
+
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(10),
+		OrderBys: []relay.OrderBy{{Field: "Name", Collate: `C" --`}},
+	})
+	require.ErrorIs(t, err, relay.ErrInvalidOrderBy)
+}
+
+func TestSortValueBounds(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID"}}
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db), relay.WithSortValueBounds())
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 10)
+	require.Equal(t, 100, resp.PageInfo.FirstSortValue)
+	require.Equal(t, 91, resp.PageInfo.LastSortValue)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, 90, resp.PageInfo.FirstSortValue)
+	require.Equal(t, 81, resp.PageInfo.LastSortValue)
+
+	// not available on empty pages, without the option or with an adapter not providing them
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(0)})
+	require.NoError(t, err)
+	require.Nil(t, resp.PageInfo.FirstSortValue)
+	require.Nil(t, resp.PageInfo.LastSortValue)
+	for _, p := range []*relay.Paginator[*User]{
+		relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db)),
+		relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](db), relay.WithSortValueBounds()),
+	} {
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+		require.NoError(t, err)
+		require.Len(t, resp.Edges, 10)
+		require.Nil(t, resp.PageInfo.FirstSortValue)
+		require.Nil(t, resp.PageInfo.LastSortValue)
+	}
+}
+
+func TestEmptyPageCursors(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+		relay.WithEmptyPageCursors(),
+	)
+
+	after := mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"})
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(after),
+		First: lo.ToPtr(0),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 0)
+	require.Equal(t, relay.PageInfo{
+		TotalCount:      100,
+		HasNextPage:     true,
+		HasPreviousPage: true,
+		StartCursor:     lo.ToPtr(after),
+		EndCursor:       lo.ToPtr(after),
+	}, resp.PageInfo)
+
+	// continue forward from the empty page
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: resp.PageInfo.EndCursor,
+		First: lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 2)
+	require.Equal(t, 6, resp.Edges[0].Node.ID)
+	require.Equal(t, 7, resp.Edges[1].Node.ID)
+
+	// backward pagination carries `before`
+	before := mustEncodeKeysetCursor(&User{ID: 10}, []string{"ID"})
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:  lo.ToPtr(after),
+		Before: lo.ToPtr(before),
+		Last:   lo.ToPtr(0),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 0)
+	require.Equal(t, lo.ToPtr(before), resp.PageInfo.StartCursor)
+	require.Equal(t, lo.ToPtr(before), resp.PageInfo.EndCursor)
+
+	// continue backward from the empty page
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: resp.PageInfo.StartCursor,
+		Last:   lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 2)
+	require.Equal(t, 8, resp.Edges[0].Node.ID)
+	require.Equal(t, 9, resp.Edges[1].Node.ID)
+
+	// without the option, cursors are nil on an empty page
+	resp, err = relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(after),
+		First: lo.ToPtr(0),
+	})
+	require.NoError(t, err)
+	require.Nil(t, resp.PageInfo.StartCursor)
+	require.Nil(t, resp.PageInfo.EndCursor)
+}
+
+func TestPostFilter(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter(
+			db.Session(&gorm.Session{Logger: recorder}),
+			// drops half of the rows
+			WithPostFilter(func(u *User) bool { return u.ID%2 == 0 }),
+		),
+	)
+
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 4, 6, 8, 10}, ids(resp))
+	require.True(t, resp.PageInfo.HasNextPage)
+	require.Equal(t, 100, resp.PageInfo.TotalCount)
+	finds := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(sql, "count(*)") })
+	require.Len(t, finds, 2) // refilled once
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: resp.PageInfo.EndCursor,
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{12, 14, 16, 18, 20}, ids(resp))
+	require.True(t, resp.PageInfo.HasNextPage)
+	require.True(t, resp.PageInfo.HasPreviousPage)
+
+	// the last page has no next page even though the unfiltered rows are not exhausted by one fetch
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 90}, []string{"ID"})),
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{92, 94, 96, 98, 100}, ids(resp))
+	require.False(t, resp.PageInfo.HasNextPage)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 91}, []string{"ID"})),
+		Last:   lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{82, 84, 86, 88, 90}, ids(resp))
+	require.True(t, resp.PageInfo.HasPreviousPage)
+	require.True(t, resp.PageInfo.HasNextPage)
+
+	// with the edge cursors
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: resp.PageInfo.StartCursor,
+		Last:   lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{72, 74, 76, 78, 80}, ids(resp))
+
+	// offset pagination does not support it
+	_, err = relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewOffsetAdapter(db, WithPostFilter(func(u *User) bool { return u.ID%2 == 0 })),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+	})
+	require.ErrorContains(t, err, "post filter is not supported by offset pagination")
+}
+
+func TestMaxRefills(t *testing.T) {
+	resetDB(t)
+
+	finds := func(recorder *sqlRecorder) int {
+		return len(lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(sql, "count(*)") }))
+	}
+	paginate := func(filter func(u *User) bool, opts ...Option[*User]) (*relay.PaginateResponse[*User], int, error) {
+		recorder := newSQLRecorder()
+		p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}},
+			NewKeysetAdapter(db.Session(&gorm.Session{Logger: recorder}), append(opts, WithPostFilter(filter))...),
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+		return resp, finds(recorder), err
+	}
+
+	// a filter dropping every row stops after the max refills instead of scanning the whole table
+	_, n, err := paginate(func(u *User) bool { return false })
+	require.ErrorIs(t, err, ErrTooManyRefills)
+	require.Equal(t, 1+DefaultMaxRefills, n)
+
+	_, n, err = paginate(func(u *User) bool { return false }, WithMaxRefills[*User](2))
+	require.ErrorIs(t, err, ErrTooManyRefills)
+	require.ErrorContains(t, err, "0 nodes of 6 kept after 2 refills")
+	require.Equal(t, 3, n)
+
+	// a sparse filter within the bound
+	resp, n, err := paginate(func(u *User) bool { return u.ID%15 == 0 }, WithMaxRefills[*User](20))
+	require.NoError(t, err)
+	require.Equal(t, []int{15, 30, 45, 60, 75}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+	require.True(t, resp.PageInfo.HasNextPage)
+	require.Equal(t, 15, n)
+
+	// the rows run out before the bound
+	resp, _, err = paginate(func(u *User) bool { return u.ID == 100 }, WithMaxRefills[*User](20))
+	require.NoError(t, err)
+	require.Equal(t, []int{100}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+	require.False(t, resp.PageInfo.HasNextPage)
+
+	require.PanicsWithValue(t, "max refills must be greater than 0", func() { WithMaxRefills[*User](0) })
+}
+
+type userTag struct {
+	UserID int    `gorm:"primarykey;not null;"`
+	Tag    string `gorm:"primarykey;not null;"`
+}
+
+func TestDedupBy(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS user_tags").Error)
+	require.NoError(t, db.AutoMigrate(&userTag{}))
+	var tags []*userTag
+	for id := 1; id <= 100; id++ {
+		tags = append(tags, &userTag{UserID: id, Tag: "a"})
+		if id%3 == 0 {
+			tags = append(tags, &userTag{UserID: id, Tag: "b"})
+		}
+		if id%5 == 0 {
+			tags = append(tags, &userTag{UserID: id, Tag: "c"}, &userTag{UserID: id, Tag: "d"})
+		}
+	}
+	require.NoError(t, db.Create(tags).Error)
+
+	// a user is duplicated for each of its tags
+	joined := db.Model(&User{}).Joins("JOIN user_tags ON user_tags.user_id = users.id")
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	resp, err := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](joined)).Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 3, 4}, ids(resp))
+
+	recorder := newSQLRecorder()
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](
+		joined.Session(&gorm.Session{Logger: recorder}),
+		WithDedupBy(func(u *User) any { return u.ID }),
+	))
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, ids(resp))
+	require.True(t, resp.PageInfo.HasNextPage)
+	finds := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(sql, "count(*)") })
+	require.Len(t, finds, 2) // refilled once
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, []int{6, 7, 8, 9, 10}, ids(resp))
+	require.True(t, resp.PageInfo.HasPreviousPage)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: resp.PageInfo.StartCursor, Last: lo.ToPtr(4)})
+	require.NoError(t, err)
+	require.Equal(t, []int{2, 3, 4, 5}, ids(resp))
+	require.True(t, resp.PageInfo.HasPreviousPage)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, []int{96, 97, 98, 99, 100}, ids(resp))
+	require.False(t, resp.PageInfo.HasNextPage)
+
+	// every user once across all the pages
+	var all []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.True(t, len(page.Edges) == 7 || page.Complete)
+		all = append(all, ids(page.PaginateResponse)...)
+	}
+	require.Equal(t, lo.RangeFrom(1, 100), all)
+
+	_, err = relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](joined, WithDedupBy(func(u *User) any { return u.ID }))).
+		Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	require.ErrorContains(t, err, "dedup is not supported by offset pagination")
+}
+
+func TestCaseInsensitiveTiebreak(t *testing.T) {
+	resetDB(t)
+
+	// ten users share each age, and the names tie case-insensitively, e.g. "bob" and "Bob", so the id is the last tiebreak
+	names := []string{"bob", "Bob", "alice", "ALICE", "Carol", "carol", "BOB"}
+	require.NoError(t, db.Exec("UPDATE users SET age = (id - 1) / 10").Error)
+	for id := 1; id <= 100; id++ {
+		require.NoError(t, db.Model(&User{}).Where("id = ?", id).Update("name", names[id%len(names)]).Error)
+	}
+
+	var users []*User
+	require.NoError(t, db.Find(&users).Error)
+	sort.SliceStable(users, func(i, j int) bool {
+		if users[i].Age != users[j].Age {
+			return users[i].Age > users[j].Age
+		}
+		if a, b := strings.ToLower(users[i].Name), strings.ToLower(users[j].Name); a != b {
+			return a < b
+		}
+		return users[i].ID < users[j].ID
+	})
+	expected := lo.Map(users, func(u *User, _ int) int { return u.ID })
+
+	recorder := newSQLRecorder()
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "Age", Desc: true},
+			{Field: "Name", Desc: false},
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter(
+			db.Session(&gorm.Session{Logger: recorder}),
+			WithOrderExpr[*User]("Name", `LOWER("name")`),
+			WithCursorOptions[*User](cursor.WithKeysetNormalizer("Name", func(v any) (any, error) { return strings.ToLower(v.(string)), nil })),
+		),
+	)
+
+	// the pages neither overlap nor leave gaps in both directions
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		ids = append(ids, lo.Map(page.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })...)
+	}
+	require.Equal(t, expected, ids)
+
+	ids = nil
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		ids = append(lo.Map(page.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }), ids...)
+	}
+	require.Equal(t, expected, ids)
+
+	// the equality branches compare the expression as ORDER BY does
+	last := recorder.SQLs()[len(recorder.SQLs())-1]
+	require.Contains(t, last, `LOWER("name") = `)
+	require.Regexp(t, `ORDER BY .age.,LOWER\("name"\) DESC,.id. DESC`, last)
+}
+
+// Entry has columns named by reserved words, which must be quoted in every generated query
+type Entry struct {
+	ID    int    `gorm:"primarykey;not null;" json:"id"`
+	Order int    `gorm:"column:order;index;not null;" json:"order"`
+	User  string `gorm:"column:user;not null;" json:"user"`
+}
+
+func TestReservedWordColumns(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS entries").Error)
+	require.NoError(t, db.AutoMigrate(&Entry{}))
+	vs := []*Entry{}
+	for i := 1; i <= 30; i++ {
+		vs = append(vs, &Entry{ID: i, Order: i % 4, User: fmt.Sprintf("user%d", i%3)})
+	}
+	require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).Create(vs).Error)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Order", Desc: false},
+		{Field: "User", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	expected := lo.Map(vs, func(e *Entry, _ int) int { return e.ID })
+	sort.SliceStable(expected, func(i, j int) bool {
+		a, b := vs[expected[i]-1], vs[expected[j]-1]
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		if a.User != b.User {
+			return a.User > b.User
+		}
+		return a.ID < b.ID
+	})
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*Entry]) relay.ApplyCursorsFunc[*Entry]) {
+		rec := newSQLRecorder()
+		p := relay.New(false, 10, 10, orderBys, f(db.Session(&gorm.Session{Logger: rec}), WithCountColumn[*Entry]("order")))
+
+		var ids []int
+		for page, err := range p.All(context.Background(), &relay.PaginateRequest[*Entry]{First: lo.ToPtr(7)}) {
+			require.NoError(t, err)
+			require.Equal(t, 30, page.PageInfo.TotalCount)
+			for _, edge := range page.Edges {
+				ids = append(ids, edge.Node.ID)
+			}
+		}
+		require.Equal(t, expected, ids)
+
+		ids = nil
+		for page, err := range p.All(context.Background(), &relay.PaginateRequest[*Entry]{Last: lo.ToPtr(7)}) {
+			require.NoError(t, err)
+			ids = append(lo.Map(page.Edges, func(edge relay.Edge[*Entry], _ int) int { return edge.Node.ID }), ids...)
+		}
+		require.Equal(t, expected, ids)
+
+		quoted := []string{db.Statement.Quote("order"), db.Statement.Quote("user")}
+		for _, sql := range rec.SQLs() {
+			if strings.Contains(sql, "COUNT") {
+				require.Contains(t, sql, "COUNT("+quoted[0]+")")
+				continue
+			}
+			for _, q := range quoted {
+				require.Contains(t, sql, q)
+			}
+		}
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter[*Entry]) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter[*Entry]) })
+}
+
+func TestInclusiveLastColumn(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	testCases := []struct {
+		name           string
+		opts           []Option[*User]
+		expectedAfter  []int
+		expectedBefore []int
+	}{
+		{
+			name:           "Exclusive",
+			expectedAfter:  []int{11, 12, 13},
+			expectedBefore: []int{7, 8, 9},
+		},
+		{
+			name:           "Inclusive",
+			opts:           []Option[*User]{WithInclusiveLastColumn[*User]()},
+			expectedAfter:  []int{10, 11, 12},
+			expectedBefore: []int{8, 9, 10},
+		},
+		{
+			name: "InclusiveWithPostFilter",
+			opts: []Option[*User]{WithInclusiveLastColumn[*User](), WithPostFilter(func(u *User) bool {
+				return u.ID%2 == 0
+			})},
+			expectedAfter:  []int{10, 12, 14},
+			expectedBefore: []int{6, 8, 10},
+		},
+	}
+
+	cursor := mustEncodeKeysetCursor(&User{ID: 10, Age: 91}, []string{"Age", "ID"})
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, tc.opts...))
+
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: &cursor,
+				First: lo.ToPtr(3),
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAfter, ids(resp))
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				Before: &cursor,
+				Last:   lo.ToPtr(3),
+			})
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedBefore, ids(resp))
+		})
+	}
+}
+
+type untaggedUser struct {
+	ID   int `gorm:"primarykey"`
+	Name string
+}
+
+type nullableUser struct {
+	ID    int  `gorm:"primarykey;not null;" json:"id"`
+	Group int  `gorm:"not null;" json:"group"`
+	Rank  *int `json:"rank"`
+}
+
+func TestNullableTiebreak(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS nullable_users").Error)
+	require.NoError(t, db.AutoMigrate(&nullableUser{}))
+	var users []*nullableUser
+	for i := 1; i <= 40; i++ {
+		u := &nullableUser{ID: i, Group: i % 3}
+		if i%4 != 0 {
+			u.Rank = lo.ToPtr(i % 5)
+		}
+		users = append(users, u)
+	}
+	require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).Create(users).Error)
+
+	nullsLargest := nullsLargest(db)
+	compareRank := func(a, b *int) int {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return lo.Ternary(nullsLargest, 1, -1)
+		case b == nil:
+			return lo.Ternary(nullsLargest, -1, 1)
+		}
+		return *a - *b
+	}
+
+	for _, nulls := range []relay.Nulls{relay.NullsDefault, relay.NullsFirst, relay.NullsLast} {
+		for _, groupDesc := range []bool{false, true} {
+			for _, rankDesc := range []bool{false, true} {
+				t.Run(fmt.Sprintf("nulls %q/group desc %v/rank desc %v", nulls, groupDesc, rankDesc), func(t *testing.T) {
+					sorted := append([]*nullableUser(nil), users...)
+					sort.SliceStable(sorted, func(i, j int) bool {
+						a, b := sorted[i], sorted[j]
+						if a.Group != b.Group {
+							return (a.Group < b.Group) != groupDesc
+						}
+						if nulls != relay.NullsDefault && (a.Rank == nil) != (b.Rank == nil) {
+							// regardless of the direction
+							return (a.Rank == nil) == (nulls == relay.NullsFirst)
+						}
+						if c := compareRank(a.Rank, b.Rank); c != 0 {
+							return (c < 0) != rankDesc
+						}
+						return a.ID < b.ID
+					})
+					expected := lo.Map(sorted, func(u *nullableUser, _ int) int { return u.ID })
+
+					orderBys := []relay.OrderBy{
+						{Field: "Group", Desc: groupDesc},
+						{Field: "Rank", Desc: rankDesc, Nulls: nulls},
+						{Field: "ID", Desc: false},
+					}
+					p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*nullableUser](db))
+
+					var ids []int
+					for page, err := range p.All(context.Background(), &relay.PaginateRequest[*nullableUser]{First: lo.ToPtr(3)}) {
+						require.NoError(t, err)
+						for _, edge := range page.Edges {
+							ids = append(ids, edge.Node.ID)
+						}
+					}
+					require.Equal(t, expected, ids)
+
+					ids = nil
+					for page, err := range p.All(context.Background(), &relay.PaginateRequest[*nullableUser]{Last: lo.ToPtr(3)}) {
+						require.NoError(t, err)
+						ids = append(lo.Map(page.Edges, func(edge relay.Edge[*nullableUser], _ int) int { return edge.Node.ID }), ids...)
+					}
+					require.Equal(t, expected, ids)
+
+					if nulls != relay.NullsDefault {
+						resp, err := relay.New(false, 40, 10, orderBys, NewOffsetAdapter[*nullableUser](db)).Paginate(context.Background(), &relay.PaginateRequest[*nullableUser]{First: lo.ToPtr(40)})
+						require.NoError(t, err)
+						require.Equal(t, expected, lo.Map(resp.Edges, func(edge relay.Edge[*nullableUser], _ int) int { return edge.Node.ID }))
+					}
+				})
+			}
+		}
+	}
+}
+
+type event struct {
+	ID         int       `gorm:"primarykey;not null;" json:"id"`
+	HappenedAt time.Time `gorm:"type:timestamp;not null;" json:"happenedAt"`
+}
+
+func TestTimestampLocation(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS events").Error)
+	require.NoError(t, db.AutoMigrate(&event{}))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var events []*event
+	for i := 1; i <= 30; i++ {
+		// some events happen at the same time
+		events = append(events, &event{ID: i, HappenedAt: base.Add(time.Duration(i/2) * 37 * time.Minute)})
+	}
+	require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).Create(events).Error)
+
+	var expected []int
+	require.NoError(t, db.Model(&event{}).Order("happened_at DESC, id").Pluck("id", &expected).Error)
+
+	for _, timeZone := range []string{"UTC", "Asia/Shanghai", "America/New_York"} {
+		t.Run(timeZone, func(t *testing.T) {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				require.NoError(t, tx.Exec(fmt.Sprintf("SET LOCAL TIME ZONE '%s'", timeZone)).Error)
+
+				p := relay.New(false, 10, 10, []relay.OrderBy{
+					{Field: "HappenedAt", Desc: true},
+					{Field: "ID", Desc: false},
+				}, NewKeysetAdapter(tx, WithTimestampLocation[*event]("HappenedAt", time.UTC)))
+
+				var ids []int
+				for page, err := range p.All(context.Background(), &relay.PaginateRequest[*event]{First: lo.ToPtr(4)}) {
+					require.NoError(t, err)
+					for _, edge := range page.Edges {
+						require.True(t, strings.HasSuffix(edge.Cursor, `Z","ID":`+fmt.Sprint(edge.Node.ID)+`}`), edge.Cursor)
+						ids = append(ids, edge.Node.ID)
+					}
+				}
+				require.Equal(t, expected, ids)
+
+				ids = nil
+				for page, err := range p.All(context.Background(), &relay.PaginateRequest[*event]{Last: lo.ToPtr(4)}) {
+					require.NoError(t, err)
+					ids = append(lo.Map(page.Edges, func(edge relay.Edge[*event], _ int) int { return edge.Node.ID }), ids...)
+				}
+				require.Equal(t, expected, ids)
+				return nil
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+type tick struct {
+	ID        int       `gorm:"primarykey;not null;" json:"id"`
+	CreatedAt time.Time `gorm:"not null;" json:"createdAt"`
+}
+
+func TestTimeCursorPrecision(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS ticks").Error)
+	require.NoError(t, db.AutoMigrate(&tick{}))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 123456000, time.UTC)
+	var ticks []*tick
+	for i := 1; i <= 30; i++ {
+		// near-identical timestamps differing by a microsecond, every three of them are identical and only differ by ID
+		ticks = append(ticks, &tick{ID: i, CreatedAt: base.Add(time.Duration(i/3) * time.Microsecond)})
+	}
+	require.NoError(t, db.Create(ticks).Error)
+
+	for _, desc := range []bool{false, true} {
+		t.Run(fmt.Sprintf("desc %v", desc), func(t *testing.T) {
+			var expected []int
+			require.NoError(t, db.Model(&tick{}).Order(lo.Ternary(desc, "created_at DESC, id", "created_at, id")).Pluck("id", &expected).Error)
+			require.Len(t, expected, 30)
+
+			p := relay.New(false, 10, 10, []relay.OrderBy{
+				{Field: "CreatedAt", Desc: desc},
+				{Field: "ID", Desc: false},
+			}, NewKeysetAdapter[*tick](db))
+
+			var ids []int
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*tick]{First: lo.ToPtr(4)}) {
+				require.NoError(t, err)
+				ids = append(ids, lo.Map(page.Edges, func(edge relay.Edge[*tick], _ int) int { return edge.Node.ID })...)
+			}
+			require.Equal(t, expected, ids)
+
+			ids = nil
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*tick]{Last: lo.ToPtr(4)}) {
+				require.NoError(t, err)
+				ids = append(lo.Map(page.Edges, func(edge relay.Edge[*tick], _ int) int { return edge.Node.ID }), ids...)
+			}
+			require.Equal(t, expected, ids)
+		})
+	}
+
+	// the cursor keeps the sub-second precision
+	cursor := mustEncodeKeysetCursor(ticks[3], []string{"CreatedAt", "ID"})
+	require.JSONEq(t, `{"CreatedAt":"2024-01-01T00:00:00.123457Z","ID":4}`, cursor)
+}
+
+type featuredPost struct {
+	ID         int       `gorm:"primarykey;not null;" json:"id"`
+	IsFeatured bool      `gorm:"not null;" json:"isFeatured"`
+	CreatedAt  time.Time `gorm:"not null;" json:"createdAt"`
+}
+
+func TestBoolOrderBy(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS featured_posts").Error)
+	require.NoError(t, db.AutoMigrate(&featuredPost{}))
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var posts []*featuredPost
+	for i := 1; i <= 30; i++ {
+		posts = append(posts, &featuredPost{ID: i, IsFeatured: i%3 == 0, CreatedAt: base.Add(time.Duration(i*7%30) * time.Hour)})
+	}
+	require.NoError(t, db.Create(posts).Error)
+
+	sorted := slices.Clone(posts)
+	slices.SortFunc(sorted, func(a, b *featuredPost) int {
+		if a.IsFeatured != b.IsFeatured {
+			return lo.Ternary(a.IsFeatured, -1, 1)
+		}
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	expected := lo.Map(sorted, func(post *featuredPost, _ int) int { return post.ID })
+
+	p := relay.New(false, 10, 10, []relay.OrderBy{
+		{Field: "IsFeatured", Desc: true},
+		{Field: "CreatedAt", Desc: true},
+	}, NewKeysetAdapter[*featuredPost](db))
+	ids := func(page *relay.PaginateResponse[*featuredPost]) []int {
+		return lo.Map(page.Edges, func(edge relay.Edge[*featuredPost], _ int) int { return edge.Node.ID })
+	}
+
+	var all []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*featuredPost]{First: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		all = append(all, ids(page.PaginateResponse)...)
+	}
+	require.Equal(t, expected, all)
+
+	all = nil
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*featuredPost]{Last: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		all = append(ids(page.PaginateResponse), all...)
+	}
+	require.Equal(t, expected, all)
+
+	// the last featured post, whose cursor encodes the bool as is
+	cursor := mustEncodeKeysetCursor(sorted[9], []string{"IsFeatured", "CreatedAt"})
+	require.JSONEq(t, `{"CreatedAt":"2024-01-01T00:00:00Z","IsFeatured":true}`, cursor)
+	for _, after := range []string{cursor, `{"CreatedAt":"2024-01-01T00:00:00Z","IsFeatured":1}`} {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{After: lo.ToPtr(after), First: lo.ToPtr(3)})
+		require.NoError(t, err)
+		require.Equal(t, expected[10:13], ids(resp))
+		require.False(t, resp.Edges[0].Node.IsFeatured)
+	}
+
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{
+		After: lo.ToPtr(`{"CreatedAt":"2024-01-01T00:00:00Z","IsFeatured":2}`),
+		First: lo.ToPtr(3),
+	})
+	require.ErrorIs(t, err, relay.ErrInvalidCursor)
+	require.ErrorContains(t, err, `field "IsFeatured": invalid bool 2`)
+}
+
+func TestRedactedKeysetValues(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS featured_posts").Error)
+	require.NoError(t, db.AutoMigrate(&featuredPost{}))
+	require.NoError(t, db.Create(&featuredPost{ID: 1, CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}).Error)
+
+	orderBys := []relay.OrderBy{
+		{Field: "IsFeatured", Desc: true},
+		{Field: "CreatedAt", Desc: true},
+	}
+	paginate := func(after string, opts ...cursor.Option) error {
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter(db, WithCursorOptions[*featuredPost](opts...)))
+		_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{After: lo.ToPtr(after), First: lo.ToPtr(3)})
+		require.ErrorIs(t, err, relay.ErrInvalidCursor)
+		return err
+	}
+
+	for after, value := range map[string]string{
+		`{"CreatedAt":"2024-01-01T00:00:00Z","IsFeatured":12345}`:   "12345",
+		`{"CreatedAt":"secret-2024-01-01","IsFeatured":true}`:       "secret-2024-01-01",
+		`{"CreatedAt":"2024-01-01T00:00:00Z","IsFeatured":"12345"}`: "12345",
+	} {
+		// the values are quoted in errors by default
+		err := paginate(after)
+		require.ErrorContains(t, err, value)
+
+		err = paginate(after, cursor.WithRedactedKeys("IsFeatured", "CreatedAt"))
+		require.ErrorContains(t, err, cursor.RedactedValue)
+		require.NotContains(t, err.Error(), value)
+	}
+
+	// only the redacted keys
+	err := paginate(`{"CreatedAt":"secret-2024-01-01","IsFeatured":true}`, cursor.WithRedactedKeys("IsFeatured"))
+	require.ErrorContains(t, err, "secret-2024-01-01")
+}
+
+type geoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+type shop struct {
+	ID       int       `gorm:"primarykey;not null;" json:"id"`
+	Name     string    `gorm:"not null;" json:"name"`
+	Location *geoPoint `gorm:"serializer:json" json:"location"`
+}
+
+func TestNonScalarOrderBy(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS shops").Error)
+	require.NoError(t, db.AutoMigrate(&shop{}))
+	require.NoError(t, db.Create([]*shop{
+		{ID: 1, Name: "a", Location: &geoPoint{Lat: 1, Lng: 2}},
+		{ID: 2, Name: "b", Location: &geoPoint{Lat: 3, Lng: 4}},
+	}).Error)
+
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "Location"}, {Field: "ID"}}, NewKeysetAdapter[*shop](db))
+	for _, req := range []*relay.PaginateRequest[*shop]{
+		{First: lo.ToPtr(1)},
+		{After: lo.ToPtr(mustEncodeKeysetCursor(&shop{ID: 1, Location: &geoPoint{Lat: 1, Lng: 2}}, []string{"Location", "ID"})), First: lo.ToPtr(1)},
+	} {
+		_, err := p.Paginate(context.Background(), req)
+		require.ErrorIs(t, err, relay.ErrInvalidOrderBy)
+		require.ErrorContains(t, err, `order-by field "Location" must be a scalar/comparable type, not *gormrelay.geoPoint`)
+	}
+
+	// scalar fields of the same model are fine
+	p = relay.New(false, 10, 10, []relay.OrderBy{{Field: "Name", Desc: true}, {Field: "ID"}}, NewKeysetAdapter[*shop](db))
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*shop]{First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.Equal(t, 2, resp.Edges[0].Node.ID)
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*shop]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.Equal(t, 1, resp.Edges[0].Node.ID)
+}
+
+func TestKeysetIntegrityCheck(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("UPDATE users SET name = ? WHERE id IN (?, ?)", "dup", 3, 4).Error)
+
+	paginate := func(orderBys []relay.OrderBy, opts ...cursor.Option) (*relay.PaginateResponse[*User], error) {
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, WithCursorOptions[*User](opts...)))
+		return p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	}
+
+	// the two rows sharing the full keyset are adjacent in the page
+	_, err := paginate([]relay.OrderBy{{Field: "Name"}}, cursor.WithKeysetIntegrityCheck())
+	require.ErrorIs(t, err, cursor.ErrAmbiguousKeyset)
+	require.ErrorContains(t, err, "rows 0 and 1 of the page have the same values of the order by fields [Name], add a unique tiebreak")
+
+	// not checked by default
+	resp, err := paginate([]relay.OrderBy{{Field: "Name"}})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 5)
+
+	// a unique tiebreak disambiguates them
+	resp, err = paginate([]relay.OrderBy{{Field: "Name"}, {Field: "ID"}}, cursor.WithKeysetIntegrityCheck())
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, lo.Map(resp.Edges[:2], func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+}
+
+func TestCursorTagKey(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "Age"}, {Field: "ID", Desc: true}}
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db.Where("age > ?", 80),
+		WithCursorOptions[*User](cursor.WithCursorTagKey("json")),
+	))
+
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		for _, edge := range page.Edges {
+			require.Equal(t, fmt.Sprintf(`{"age":%d,"id":%d}`, edge.Node.Age, edge.Node.ID), edge.Cursor)
+			ids = append(ids, edge.Node.ID)
+		}
+	}
+	require.Equal(t, lo.RangeWithSteps(20, 0, -1), ids)
+
+	// the keys of the cursors are named by the tags, while the order bys still refer to the fields
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(`{"age":85,"id":16}`), First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{15, 14}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(`{"Age":85,"ID":16}`), First: lo.ToPtr(2)})
+	require.ErrorIs(t, err, relay.ErrInvalidCursor)
+}
+
+func TestExactCursorExistence(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID"}}
+	idCursor := func(id int) *string { return lo.ToPtr(mustEncodeKeysetCursor(&User{ID: id}, []string{"ID"})) }
+	exact := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, WithCursorOptions[*User](cursor.WithExactCursorExistence())))
+	assumed := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+
+	for _, tc := range []struct {
+		name                         string
+		req                          *relay.PaginateRequest[*User]
+		edges                        int
+		hasPrevious, hasNext         bool
+		assumedPrevious, assumedNext bool
+	}{
+		// the previous rows of the last row exist, but no row follows it
+		{"AfterLastRow", &relay.PaginateRequest[*User]{After: idCursor(100), First: lo.ToPtr(5)}, 0, true, false, true, false},
+		{"AfterBeforeFirstRow", &relay.PaginateRequest[*User]{After: idCursor(0), First: lo.ToPtr(5)}, 5, false, true, true, true},
+		{"BeforeFirstRow", &relay.PaginateRequest[*User]{Before: idCursor(1), Last: lo.ToPtr(5)}, 0, false, true, false, true},
+		{"BeforeBeyondLastRow", &relay.PaginateRequest[*User]{Before: idCursor(101), Last: lo.ToPtr(5)}, 5, true, false, true, true},
+		{"Between", &relay.PaginateRequest[*User]{After: idCursor(10), Before: idCursor(13), First: lo.ToPtr(5)}, 2, true, true, true, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := exact.Paginate(context.Background(), tc.req)
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, tc.edges)
+			require.Equal(t, tc.hasPrevious, resp.PageInfo.HasPreviousPage)
+			require.Equal(t, tc.hasNext, resp.PageInfo.HasNextPage)
+
+			// by default the cursors are assumed to have their neighbors
+			resp, err = assumed.Paginate(context.Background(), tc.req)
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, tc.edges)
+			require.Equal(t, tc.assumedPrevious, resp.PageInfo.HasPreviousPage)
+			require.Equal(t, tc.assumedNext, resp.PageInfo.HasNextPage)
+		})
+	}
+}
+
+type customer struct {
+	ID   int    `gorm:"primarykey;not null;"`
+	Name string `gorm:"not null;"`
+}
+
+type customerOrder struct {
+	ID         int `gorm:"primarykey;not null;"`
+	CustomerID *int
+	Customer   *customer
+	Total      int `gorm:"not null;"`
+}
+
+func TestRelatedOrderBy(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS customer_orders").Error)
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS customers").Error)
+	require.NoError(t, db.AutoMigrate(&customer{}, &customerOrder{}))
+	names := []string{"", "bob", "alice", "carol", "alice", "dave", "bob"}
+	for id := 1; id < len(names); id++ {
+		require.NoError(t, db.Create(&customer{ID: id, Name: names[id]}).Error)
+	}
+	for id := 1; id <= 30; id++ {
+		order := &customerOrder{ID: id, Total: id * 10}
+		if id%7 != 0 {
+			order.CustomerID = lo.ToPtr(id % 7)
+		}
+		require.NoError(t, db.Create(order).Error)
+	}
+
+	ids := func(resp *relay.PaginateResponse[*customerOrder]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*customerOrder], _ int) int { return edge.Node.ID })
+	}
+	nameOf := func(order *customerOrder) string {
+		if order.Customer == nil {
+			return ""
+		}
+		return order.Customer.Name
+	}
+
+	for _, tc := range []struct {
+		name     string
+		db       *gorm.DB
+		orderBys []relay.OrderBy
+		total    int
+		column   string
+	}{
+		{
+			name:     "JoinedByName",
+			db:       db.Joins("Customer"),
+			orderBys: []relay.OrderBy{{Field: "Customer.Name"}, {Field: "ID"}},
+			total:    30,
+			column:   "`Customer`.`name`",
+		},
+		{
+			name:     "JoinedByTable",
+			db:       db.Joins("JOIN customers ON customers.id = customer_orders.customer_id").Preload("Customer"),
+			orderBys: []relay.OrderBy{{Field: "Customer.Name", Desc: true}, {Field: "ID", Desc: true}},
+			total:    26,
+			column:   "`customers`.`name`",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := newSQLRecorder()
+			p := relay.New(false, 100, 10, tc.orderBys, NewKeysetAdapter[*customerOrder](tc.db.Session(&gorm.Session{Logger: recorder})))
+
+			full, err := p.Paginate(context.Background(), &relay.PaginateRequest[*customerOrder]{First: lo.ToPtr(100)})
+			require.NoError(t, err)
+			require.Len(t, full.Edges, tc.total)
+			nodes := lo.Map(full.Edges, func(edge relay.Edge[*customerOrder], _ int) *customerOrder { return edge.Node })
+			require.True(t, slices.IsSortedFunc(lo.Filter(nodes, func(order *customerOrder, _ int) bool { return order.Customer != nil }), func(a, b *customerOrder) int {
+				c := cmp.Compare(nameOf(a), nameOf(b))
+				if c == 0 {
+					c = cmp.Compare(a.ID, b.ID)
+				}
+				return lo.Ternary(tc.orderBys[0].Desc, -c, c)
+			}))
+			require.True(t, lo.SomeBy(recorder.SQLs(), func(sql string) bool { return strings.Contains(sql, "ORDER BY "+tc.column) }))
+
+			// the cursors come from the nested structs of the nodes
+			var all []int
+			for page, err := range p.All(context.Background(), &relay.PaginateRequest[*customerOrder]{First: lo.ToPtr(4)}) {
+				require.NoError(t, err)
+				all = append(all, ids(page.PaginateResponse)...)
+			}
+			require.Equal(t, ids(full), all)
+
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*customerOrder]{Before: lo.ToPtr(full.Edges[10].Cursor), Last: lo.ToPtr(3)})
+			require.NoError(t, err)
+			require.Equal(t, ids(full)[7:10], ids(resp))
+		})
+	}
+}
+
+func TestKeysetGenericTypeAny(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[any]) relay.ApplyCursorsFunc[any]) {
+		t.Run("Right", func(t *testing.T) {
+			p := relay.New(
+				false,
+				10, 10,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
+					// This is a generic(T: any) function, so we need to call db.Model(x)
+					return f(db.Model(&User{}))(ctx, req)
+				},
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, 10)
+			require.Equal(t, 1, resp.Edges[0].Node.(*User).ID)
+			require.Equal(t, 10, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
+			require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+			require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+				Last: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, 10)
+			require.Equal(t, 91, resp.Edges[0].Node.(*User).ID)
+			require.Equal(t, 100, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
+			require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+			require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+		})
+		t.Run("Wrong", func(t *testing.T) {
+			p := relay.New(
+				false,
+				10, 10,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
+					// This is wrong, we need to call db.Model(x) for generic(T: any) function
+					return f(db)(ctx, req)
+				},
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+				First: lo.ToPtr(10),
+			})
+			require.ErrorContains(t, err, "db.Statement.Model is nil and T is not a struct or struct pointer")
+			require.Nil(t, resp)
+		})
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	anotherTestCase := func(t *testing.T, applyCursorsFunc relay.ApplyCursorsFunc[any]) {
+		t.Run("Wrong(WithoutCounter)", func(t *testing.T) {
+			p := relay.New(
+				false,
+				10, 10,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				},
+				applyCursorsFunc,
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+				First: lo.ToPtr(10),
+			})
+			require.ErrorContains(t, err, "db.Statement.Model is nil and T is not a struct or struct pointer")
+			require.Nil(t, resp)
+		})
+	}
+
+	// This is wrong, we need to call db.Model(x) for generic(T: any) function
+	t.Run("keyset", func(t *testing.T) { anotherTestCase(t, cursor.NewKeysetAdapter(NewKeysetFinder[any](db))) })
+	t.Run("offset", func(t *testing.T) { anotherTestCase(t, cursor.NewOffsetAdapter(NewOffsetFinder[any](db))) })
+}
+
+func TestKeysetInvalidCursor(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		}, func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[any], error) {
+			// This is a generic(T: any) function, so we need to cast the model to the correct type
+			return NewKeysetAdapter[any](db.Model(&User{}))(ctx, req)
+		},
+	)
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+		After: lo.ToPtr(`{"ID":1}`),
+		First: lo.ToPtr(10),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 10)
+	require.Equal(t, 1+1, resp.Edges[0].Node.(*User).ID)
+	require.Equal(t, 10+1, resp.Edges[len(resp.Edges)-1].Node.(*User).ID)
+	require.Equal(t, resp.Edges[0].Cursor, *(resp.PageInfo.StartCursor))
+	require.Equal(t, resp.Edges[len(resp.Edges)-1].Cursor, *(resp.PageInfo.EndCursor))
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+		After: lo.ToPtr(`{"FieldNotExists":1}`),
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, `key "ID" not found in cursor`)
+	require.Nil(t, resp)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+		After: lo.ToPtr(`{"ID":1,"Name":"name0"}`),
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, `cursor length != keys length`)
+	require.Nil(t, resp)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[any]{
+		Before: lo.ToPtr(`invalid`),
+		First:  lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, `unmarshal cursor`)
+	require.Nil(t, resp)
+
+	_, err = relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter(db, WithCursorOptions[*User](cursor.WithMaxKeysetKeys(1))),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(`{"ID":1,"Name":"name0","Age":99}`),
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, `too many keys, max is 1`)
+}
+
+func TestInconsistentCursor(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter(db, WithCursorOptions[*User](cursor.WithConsistencyCheck())))
+
+	testCases := []struct {
+		name          string
+		after, before string
+		expectedError bool
+	}{
+		{
+			name:   "Consistent",
+			after:  `{"Age":90,"ID":11}`,
+			before: `{"Age":85,"ID":16}`,
+		},
+		{
+			name:   "ConsistentByTiebreak",
+			after:  `{"Age":90,"ID":11}`,
+			before: `{"Age":90,"ID":12}`,
+		},
+		{
+			// ages are descending, so the after cursor can't be of a smaller age
+			name:          "Reversed",
+			after:         `{"Age":85,"ID":16}`,
+			before:        `{"Age":90,"ID":11}`,
+			expectedError: true,
+		},
+		{
+			name:          "ReversedByTiebreak",
+			after:         `{"Age":90,"ID":12}`,
+			before:        `{"Age":90,"ID":11}`,
+			expectedError: true,
+		},
+		{
+			name:          "SamePosition",
+			after:         `{"Age":90,"ID":11}`,
+			before:        `{"ID":11,"Age":90}`,
+			expectedError: true,
+		},
+		{
+			// values which can't be compared are left to the database
+			name:   "Incomparable",
+			after:  `{"Age":null,"ID":11}`,
+			before: `{"Age":90,"ID":11}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After:  &tc.after,
+				Before: &tc.before,
+				First:  lo.ToPtr(10),
+			})
+			if tc.expectedError {
+				require.ErrorIs(t, err, cursor.ErrInconsistentCursor)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	// without the check, the query runs and returns an empty page
+	resp, err := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db)).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:  lo.ToPtr(`{"Age":85,"ID":16}`),
+		Before: lo.ToPtr(`{"Age":90,"ID":11}`),
+		First:  lo.ToPtr(10),
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Edges)
+
+	// "a" precedes "B" case-insensitively, but not bytewise
+	nameOrderBys := []relay.OrderBy{{Field: "Name"}, {Field: "ID"}}
+	req := &relay.PaginateRequest[*User]{
+		After:  lo.ToPtr(`{"ID":1,"Name":"a"}`),
+		Before: lo.ToPtr(`{"ID":2,"Name":"B"}`),
+		First:  lo.ToPtr(10),
+	}
+	_, err = relay.New(false, 10, 10, nameOrderBys, NewKeysetAdapter[*User](db)).Paginate(context.Background(), req)
+	require.NoError(t, err)
+	_, err = relay.New(false, 10, 10, nameOrderBys, NewKeysetAdapter(db, WithCursorOptions[*User](
+		cursor.WithConsistencyCheck(),
+	))).Paginate(context.Background(), req)
+	require.ErrorIs(t, err, cursor.ErrInconsistentCursor)
+	_, err = relay.New(false, 10, 10, nameOrderBys, NewKeysetAdapter(db, WithCursorOptions[*User](
+		cursor.WithConsistencyCheck(),
+		cursor.WithStringCollation(func(a, b string) int { return strings.Compare(strings.ToLower(a), strings.ToLower(b)) }),
+	))).Paginate(context.Background(), req)
+	require.NoError(t, err)
+}
+
+func TestOrderFingerprint(t *testing.T) {
+	resetDB(t)
+
+	ascending := []relay.OrderBy{{Field: "ID", Desc: false}}
+	descending := []relay.OrderBy{{Field: "ID", Desc: true}}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	// cursors issued before the default direction is flipped
+	oldPagination := relay.New(false, 10, 10, ascending, cursor.WrapOrderFingerprint(NewKeysetAdapter[*User](db)))
+	resp, err := oldPagination.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(1, 10), ids(resp))
+	oldCursor := resp.PageInfo.EndCursor
+
+	resp, err = oldPagination.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(11, 5), ids(resp))
+
+	t.Run("Mismatch", func(t *testing.T) {
+		p := relay.New(false, 10, 10, descending, cursor.WrapOrderFingerprint(NewKeysetAdapter[*User](db)))
+		_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5)})
+		require.ErrorIs(t, err, cursor.ErrOrderMismatch)
+		require.ErrorContains(t, err, "restart the pagination without cursors")
+
+		// the same order requested explicitly is still fine
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5), OrderBys: ascending})
+		require.NoError(t, err)
+		require.Equal(t, lo.RangeFrom(11, 5), ids(resp))
+
+		// restarting without cursors
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Equal(t, []int{100, 99, 98, 97, 96}, ids(resp))
+
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr(`{"ID":10}`), First: lo.ToPtr(5)})
+		require.ErrorContains(t, err, "missing order fingerprint")
+	})
+
+	t.Run("Migration", func(t *testing.T) {
+		p := relay.New(false, 10, 10, descending, cursor.WrapOrderFingerprint(
+			NewKeysetAdapter[*User](db),
+			cursor.WithOrderMigration(ascending, descending),
+		))
+		// continues from the same row in the new direction
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: oldCursor, First: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Equal(t, []int{9, 8, 7, 6, 5}, ids(resp))
+
+		// cursors issued under the new order are continued as usual
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: resp.PageInfo.EndCursor, First: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Equal(t, []int{4, 3, 2, 1}, ids(resp))
+
+		require.PanicsWithValue(t, "order migration must be between order bys of the same fields", func() {
+			cursor.WithOrderMigration(ascending, []relay.OrderBy{{Field: "Age"}})
+		})
+	})
+
+	t.Run("KeysetAdapter", func(t *testing.T) {
+		orderBys := []relay.OrderBy{{Field: "ID"}, {Field: "Age", Desc: true}}
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, WithCursorOptions[*User](cursor.WithOrderFingerprint())))
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Equal(t, lo.RangeFrom(1, 5), ids(resp))
+		heldCursor := resp.PageInfo.EndCursor
+		require.Regexp(t, `^[0-9a-f]{8}:\{"Age":96,"ID":5\}$`, *heldCursor)
+
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: heldCursor, First: lo.ToPtr(5)})
+		require.NoError(t, err)
+		require.Equal(t, lo.RangeFrom(6, 5), ids(resp))
+
+		// the client swapped the sort direction
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:    heldCursor,
+			First:    lo.ToPtr(5),
+			OrderBys: []relay.OrderBy{{Field: "ID", Desc: true}, {Field: "Age"}},
+		})
+		require.ErrorIs(t, err, cursor.ErrOrderMismatch)
+		require.ErrorIs(t, err, relay.ErrInvalidCursor)
+		require.ErrorContains(t, err, "invalid after cursor: order by mismatch")
+
+		// cursors issued without the fingerprint keep working
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 10, Age: 91}, []string{"ID", "Age"})), Last: lo.ToPtr(3)})
+		require.NoError(t, err)
+		require.Equal(t, []int{7, 8, 9}, ids(resp))
+	})
+}
+
+func TestEmptyRangeOnEqualCursors(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	c := mustEncodeKeysetCursor(&User{ID: 10}, []string{"ID"})
+
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+	_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: &c, Before: &c, First: lo.ToPtr(5)})
+	require.ErrorContains(t, err, "after == before")
+
+	p = relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, WithCursorOptions[*User](
+		cursor.WithEmptyRangeOnEqualCursors(),
+		cursor.WithConsistencyCheck(),
+	)))
+	for _, req := range []*relay.PaginateRequest[*User]{
+		{After: &c, Before: &c, First: lo.ToPtr(5)},
+		{After: &c, Before: &c, Last: lo.ToPtr(5)},
+	} {
+		resp, err := p.Paginate(context.Background(), req)
+		require.NoError(t, err)
+		require.Empty(t, resp.Edges)
+		require.Equal(t, 100, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.HasNextPage)
+		require.True(t, resp.PageInfo.HasPreviousPage)
+		require.Nil(t, resp.PageInfo.StartCursor)
+	}
+
+	// the cursors are still validated
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("x"), Before: lo.ToPtr("x"), First: lo.ToPtr(5)})
+	require.Error(t, err)
+}
+
+func TestNamedParams(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "Name", Desc: false},
+	}
+	after := &map[string]any{"Age": 85, "Name": "name15"}
+	before := &map[string]any{"Age": 80, "Name": "name20"}
+
+	dryRun := func(orderBys []relay.OrderBy, before *map[string]any, opts *keysetOptions) *gorm.Statement {
+		tx := db.Session(&gorm.Session{DryRun: true}).Model(&User{}).Scopes(scopeKeyset(after, before, orderBys, 10, false, opts)).Find(&User{})
+		require.NoError(t, tx.Error)
+		return tx.Statement
+	}
+	positional := dryRun(orderBys, before, nil)
+	require.Empty(t, namedArgs(positional.Clauses["WHERE"].Expression))
+
+	named := dryRun(orderBys, before, &keysetOptions{namedParams: true})
+	require.Equal(t, map[string]any{"age0": 85, "name0": "name15", "age1": 80, "name1": "name20"}, namedArgs(named.Clauses["WHERE"].Expression))
+	// GORM binds the named args by the placeholders of the dialect, the same as positional params
+	require.Equal(t, positional.SQL.String(), named.SQL.String())
+	require.Equal(t, positional.Vars, named.Vars)
+	require.Equal(t, []any{85, 85, "name15", 80, 80, "name20"}, named.Vars)
+
+	named = dryRun([]relay.OrderBy{{Field: "Age"}, {Field: "Name"}}, nil, &keysetOptions{namedParams: true, rowValues: true})
+	require.Equal(t, map[string]any{"age0": 85, "name0": "name15"}, namedArgs(named.Clauses["WHERE"].Expression))
+	require.Regexp(t, `\(.age.,.name.\) > \(\$1,\$2\)|\(.age.,.name.\) > \(\?,\?\)`, named.SQL.String())
+	require.Equal(t, []any{85, "name15"}, named.Vars)
+
+	paginate := func(opts ...Option[*User]) []int {
+		p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, opts...))
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:  lo.ToPtr(mustEncodeKeysetCursor(&User{Age: 85, Name: "name15"}, []string{"Age", "Name"})),
+			Before: lo.ToPtr(mustEncodeKeysetCursor(&User{Age: 80, Name: "name20"}, []string{"Age", "Name"})),
+			First:  lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+	require.Equal(t, []int{17, 18, 19, 20}, paginate(WithNamedParams[*User]()))
+	require.Equal(t, []int{17, 18, 19, 20}, paginate())
+}
+
+// namedArgs collects the values of the named args in expr by name
+func namedArgs(expr clause.Expression) map[string]any {
+	args := map[string]any{}
+	var collect func(exprs ...clause.Expression)
+	collect = func(exprs ...clause.Expression) {
+		for _, expr := range exprs {
+			switch e := expr.(type) {
+			case clause.Where:
+				collect(e.Exprs...)
+			case clause.AndConditions:
+				collect(e.Exprs...)
+			case clause.OrConditions:
+				collect(e.Exprs...)
+			case clause.NamedExpr:
+				for _, v := range e.Vars {
+					if arg, ok := v.(sql.NamedArg); ok {
+						args[arg.Name] = arg.Value
+					}
+				}
+			}
+		}
+	}
+	collect(expr)
+	return args
+}
+
+type Order struct {
+	ID         int `gorm:"primarykey;not null;" json:"id"`
+	CustomerID int `gorm:"index;not null;" json:"customerId"`
+	Amount     int `gorm:"not null;" json:"amount"`
+}
+
+type customerTotal struct {
+	CustomerID int `json:"customerId"`
+	Total      int `json:"total"`
+}
+
+type measurement struct {
+	ID    int     `gorm:"primarykey;not null;" json:"id"`
+	Value float64 `gorm:"not null;" json:"value"`
+}
+
+func TestFloatPrecision(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS measurements").Error)
+	require.NoError(t, db.AutoMigrate(&measurement{}))
+
+	// values differing beyond 2 decimal places, away from the halfway points
+	offsets := []float64{0.0001, 0.0032, -0.0021, 0.0044, -0.0013, 0.0007}
+	var measurements []*measurement
+	for i := 1; i <= 36; i++ {
+		measurements = append(measurements, &measurement{ID: i, Value: float64(i%5)/100 + 1 + offsets[i%len(offsets)]})
+	}
+	require.NoError(t, db.Create(measurements).Error)
+
+	rounded := func(v float64) float64 { return math.Round(v*100) / 100 }
+	expected := slices.Clone(measurements)
+	sort.SliceStable(expected, func(i, j int) bool {
+		if a, b := rounded(expected[i].Value), rounded(expected[j].Value); a != b {
+			return a > b
+		}
+		return expected[i].ID < expected[j].ID
+	})
+	expectedIDs := lo.Map(expected, func(m *measurement, _ int) int { return m.ID })
+
+	recorder := newSQLRecorder()
+	p := relay.New(false, 10, 10, []relay.OrderBy{
+		{Field: "Value", Desc: true},
+		{Field: "ID", Desc: false},
+	}, NewKeysetAdapter(db.Session(&gorm.Session{Logger: recorder}), WithFloatPrecision[*measurement]("Value", 2)))
+
+	var ids []int
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*measurement]{First: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		for _, edge := range page.Edges {
+			// the cursor encodes the rounded value
+			require.JSONEq(t, fmt.Sprintf(`{"Value":%v,"ID":%d}`, rounded(edge.Node.Value), edge.Node.ID), edge.Cursor)
+			ids = append(ids, edge.Node.ID)
+		}
+	}
+	require.Equal(t, expectedIDs, ids)
+
+	ids = nil
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*measurement]{Last: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		ids = append(lo.Map(page.Edges, func(edge relay.Edge[*measurement], _ int) int { return edge.Node.ID }), ids...)
+	}
+	require.Equal(t, expectedIDs, ids)
+
+	// the same expression as roundedKeysetOptions, which casts to numeric on Postgres
+	column := `round\(.value., 2\)`
+	if db.Dialector.Name() == "postgres" {
+		column = `round\(CAST\(.value. AS numeric\), 2\)`
+	}
+	last := recorder.SQLs()[len(recorder.SQLs())-1]
+	require.Regexp(t, column+` = 1\.0\d? AND .id. <`, last)
+	require.Regexp(t, `ORDER BY `+column+`,.id. DESC`, last)
+}
+
+func TestAggregateKeyset(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS orders").Error)
+	require.NoError(t, db.AutoMigrate(&Order{}))
+
+	// 30 customers with 1 to 4 orders each, the totals tie across customers
+	totals := map[int]int{}
+	orders := []*Order{}
+	for c := 1; c <= 30; c++ {
+		for j := 0; j <= c%4; j++ {
+			amount := (c%5 + 1) * 10
+			orders = append(orders, &Order{CustomerID: c, Amount: amount})
+			totals[c] += amount
+		}
+	}
+	require.NoError(t, db.Create(orders).Error)
+	// orders of customer 31 are filtered out by WHERE
+	require.NoError(t, db.Create(&Order{CustomerID: 31, Amount: -1}).Error)
+
+	expected := lo.Map(lo.RangeFrom(1, 30), func(c int, _ int) *customerTotal {
+		return &customerTotal{CustomerID: c, Total: totals[c]}
+	})
+	sort.SliceStable(expected, func(i, j int) bool {
+		if expected[i].Total != expected[j].Total {
+			return expected[i].Total > expected[j].Total
+		}
+		return expected[i].CustomerID < expected[j].CustomerID
+	})
+
+	recorder := newSQLRecorder()
+	grouped := db.Session(&gorm.Session{Logger: recorder}).Model(&Order{}).
+		Select("customer_id, SUM(amount) AS total").Where("amount > ?", 0).Group("customer_id")
+	p := relay.New(false, 10, 10, []relay.OrderBy{
+		{Field: "Total", Desc: true},
+		{Field: "CustomerID", Desc: false},
+	}, NewKeysetAdapter[*customerTotal](grouped, WithAggregateField[*customerTotal]("Total", "SUM(amount)")))
+
+	var forward []*customerTotal
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*customerTotal]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.Equal(t, 30, page.PageInfo.TotalCount)
+		forward = append(forward, lo.Map(page.Edges, func(edge relay.Edge[*customerTotal], _ int) *customerTotal { return edge.Node })...)
+	}
+	require.Equal(t, expected, forward)
+
+	var backward []*customerTotal
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*customerTotal]{Last: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		require.Equal(t, 30, page.PageInfo.TotalCount)
+		backward = append(lo.Map(page.Edges, func(edge relay.Edge[*customerTotal], _ int) *customerTotal { return edge.Node }), backward...)
+	}
+	require.Equal(t, expected, backward)
+
+	// a page between two boundaries
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*customerTotal]{
+		After:  lo.ToPtr(mustEncodeKeysetCursor(expected[4], []string{"Total", "CustomerID"})),
+		Before: lo.ToPtr(mustEncodeKeysetCursor(expected[9], []string{"Total", "CustomerID"})),
+		First:  lo.ToPtr(10),
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected[5:9], lo.Map(resp.Edges, func(edge relay.Edge[*customerTotal], _ int) *customerTotal { return edge.Node }))
+	require.True(t, resp.PageInfo.HasNextPage)
+	require.True(t, resp.PageInfo.HasPreviousPage)
+
+	sqls := recorder.SQLs()
+	require.Contains(t, sqls[len(sqls)-1], "HAVING")
+	require.NotContains(t, strings.SplitN(sqls[len(sqls)-1], "GROUP BY", 2)[0], "SUM(amount) <")
+	require.True(t, lo.SomeBy(sqls, func(sql string) bool {
+		return strings.Contains(sql, "count(*) FROM (SELECT customer_id, SUM(amount) AS total")
+	}))
+}
+
+type Ranking struct {
+	Rank int `gorm:"column:rank_no;not null;" json:"rank"`
+}
+
+// member has column names which don't snake-case from the field names, including one of an embedded struct
+type member struct {
+	ID   int    `gorm:"primarykey;not null;" json:"id"`
+	Name string `gorm:"column:full_name;not null;" json:"name"`
+	Ranking
+}
+
+func TestCustomColumnNames(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS members").Error)
+	require.NoError(t, db.AutoMigrate(&member{}))
+
+	members := []*member{}
+	for i := 1; i <= 30; i++ {
+		members = append(members, &member{ID: i, Name: fmt.Sprintf("member%02d", (i*7)%30), Ranking: Ranking{Rank: i % 4}})
+	}
+	require.NoError(t, db.Create(members).Error)
+
+	expected := slices.Clone(members)
+	sort.SliceStable(expected, func(i, j int) bool {
+		if expected[i].Rank != expected[j].Rank {
+			return expected[i].Rank > expected[j].Rank
+		}
+		return expected[i].Name < expected[j].Name
+	})
+
+	orderBys := []relay.OrderBy{
+		{Field: "Rank", Desc: true},
+		{Field: "Name", Desc: false},
+		{Field: "ID", Desc: false},
+	}
+	recorder := newSQLRecorder()
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*member](db.Session(&gorm.Session{Logger: recorder})))
+
+	var forward []*member
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*member]{First: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		forward = append(forward, lo.Map(page.Edges, func(edge relay.Edge[*member], _ int) *member { return edge.Node })...)
+	}
+	require.Equal(t, expected, forward)
+
+	var backward []*member
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*member]{Last: lo.ToPtr(7)}) {
+		require.NoError(t, err)
+		backward = append(lo.Map(page.Edges, func(edge relay.Edge[*member], _ int) *member { return edge.Node }), backward...)
+	}
+	require.Equal(t, expected, backward)
+
+	sqls := recorder.SQLs()
+	last := sqls[len(sqls)-1]
+	require.Contains(t, last, "rank_no")
+	require.Contains(t, last, "full_name")
+	require.NotRegexp(t, `\W(rank|name)\W`, strings.ReplaceAll(last, "full_name", ""))
+
+	// the same as offset pagination
+	resp, err := relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*member](db)).Paginate(context.Background(), &relay.PaginateRequest[*member]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, expected[:10], lo.Map(resp.Edges, func(edge relay.Edge[*member], _ int) *member { return edge.Node }))
+}
+
+// tenantUser has a composite primary key, IDs collide across tenants
+type tenantUser struct {
+	TenantID int    `gorm:"primaryKey;autoIncrement:false" json:"tenantId"`
+	ID       int    `gorm:"primaryKey;autoIncrement:false" json:"id"`
+	Name     string `gorm:"not null" json:"name"`
+}
+
+func TestCompositePrimaryKey(t *testing.T) {
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS tenant_users").Error)
+	require.NoError(t, db.AutoMigrate(&tenantUser{}))
+
+	var expected []*tenantUser
+	for tenantID := 1; tenantID <= 3; tenantID++ {
+		for id := 1; id <= 7; id++ {
+			expected = append(expected, &tenantUser{TenantID: tenantID, ID: id, Name: fmt.Sprintf("user%d-%d", tenantID, id)})
+		}
+	}
+	// inserted out of order
+	require.NoError(t, db.Create(lo.Reverse(slices.Clone(expected))).Error)
+
+	orderBys, err := PrimaryKeyOrderBys[*tenantUser](db)
+	require.NoError(t, err)
+	require.Equal(t, []relay.OrderBy{{Field: "TenantID"}, {Field: "ID"}}, orderBys)
+
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*tenantUser](db))
+	nodes := func(edges []relay.Edge[*tenantUser]) []*tenantUser {
+		return lo.Map(edges, func(edge relay.Edge[*tenantUser], _ int) *tenantUser { return edge.Node })
+	}
+
+	var forward []*tenantUser
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*tenantUser]{First: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		forward = append(forward, nodes(page.Edges)...)
+	}
+	require.Equal(t, expected, forward)
+
+	var backward []*tenantUser
+	for page, err := range p.All(context.Background(), &relay.PaginateRequest[*tenantUser]{Last: lo.ToPtr(4)}) {
+		require.NoError(t, err)
+		backward = append(nodes(page.Edges), backward...)
+	}
+	require.Equal(t, expected, backward)
+
+	// the cursors carry all the primary key fields
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*tenantUser]{
+		After: lo.ToPtr(mustEncodeKeysetCursor(&tenantUser{TenantID: 1, ID: 7}, []string{"TenantID", "ID"})),
+		First: lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Equal(t, expected[7:9], nodes(resp.Edges))
+	require.JSONEq(t, `{"TenantID":2,"ID":2}`, *resp.PageInfo.EndCursor)
+
+	// the adapter falls back to the primary key without order bys
+	edges, _, _, err := relay.EdgesToReturn(context.Background(), nil, nil, lo.ToPtr(3), nil, nil, false, NewKeysetAdapter[*tenantUser](db))
+	require.NoError(t, err)
+	require.Equal(t, expected[:3], nodes(edges))
+	require.JSONEq(t, `{"TenantID":1,"ID":3}`, edges[2].Cursor)
+
+	_, err = PrimaryKeyOrderBys[map[string]any](db)
+	require.ErrorContains(t, err, "db.Statement.Model is nil")
+}
+
+func TestPrimaryKeyTiebreak(t *testing.T) {
+	resetDB(t)
+	// the primary sort column is all-equal
+	require.NoError(t, db.Exec("UPDATE users SET age = 1").Error)
+
+	ids := func(edges []relay.Edge[*User]) []int {
+		return lo.Map(edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+	walk := func(p *relay.Paginator[*User], req *relay.PaginateRequest[*User]) []int {
+		var result []int
+		for page, err := range p.All(context.Background(), req) {
+			require.NoError(t, err)
+			if req.Last != nil {
+				result = append(ids(page.Edges), result...)
+			} else {
+				result = append(result, ids(page.Edges)...)
+			}
+		}
+		return result
+	}
+
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: true}}
+
+	// without a tiebreak, all the rows after the first page tie with the cursor and are skipped
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+	require.Len(t, walk(p, &relay.PaginateRequest[*User]{First: lo.ToPtr(10)}), 10)
+
+	var warned [][]relay.OrderBy
+	recorder := newSQLRecorder()
+	p = relay.New(false, 10, 10, orderBys, NewKeysetAdapter(
+		db.Session(&gorm.Session{Logger: recorder}),
+		WithPrimaryKeyTiebreak[*User](func(ctx context.Context, orderBys []relay.OrderBy) {
+			warned = append(warned, orderBys)
+		}),
+	))
+
+	// the primary key is appended in the direction of the last order by
+	expected := lo.RangeWithSteps(100, 0, -1)
+	require.Equal(t, expected, walk(p, &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}))
+	require.Equal(t, expected, walk(p, &relay.PaginateRequest[*User]{Last: lo.ToPtr(7)}))
+	require.NotEmpty(t, warned)
+	require.Equal(t, orderBys, warned[0])
+	// the last one walks backward, in the reversed directions
+	require.Regexp(t, `ORDER BY .age.,.id. LIMIT`, recorder.SQLs()[len(recorder.SQLs())-1])
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(1)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"Age":1,"ID":100}`, *resp.PageInfo.EndCursor)
+
+	// nothing is appended if the order bys include the primary key already
+	warned = nil
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(1),
+		OrderBys: []relay.OrderBy{{Field: "ID", Desc: false}, {Field: "Age", Desc: true}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, ids(resp.Edges))
+	require.JSONEq(t, `{"ID":1,"Age":1}`, *resp.PageInfo.EndCursor)
+	require.Empty(t, warned)
+
+	// warn is optional
+	p = relay.New(false, 10, 10, orderBys, NewKeysetAdapter(db, WithPrimaryKeyTiebreak[*User](nil)))
+	require.Equal(t, expected, walk(p, &relay.PaginateRequest[*User]{First: lo.ToPtr(9)}))
+}
+
+func TestKeysetDebugHook(t *testing.T) {
+	resetDB(t)
+
+	var audited [][2]map[string]any
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "Age", Desc: true},
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter(db, WithCursorOptions[*User](cursor.WithKeysetDebugHook(func(ctx context.Context, after, before map[string]any) {
+			audited = append(audited, [2]map[string]any{after, before})
+		}))),
+	)
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(5)})
+	require.NoError(t, err)
+	require.Equal(t, [][2]map[string]any{{nil, nil}}, audited)
+
+	audited = nil
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:  resp.PageInfo.StartCursor,
+		Before: resp.PageInfo.EndCursor,
+		First:  lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][2]map[string]any{{
+		{"Age": float64(100), "ID": float64(1)},
+		{"Age": float64(96), "ID": float64(5)},
+	}}, audited)
+
+	// the values are converted as bound to the query, and only the redacted keys are masked
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS featured_posts").Error)
+	require.NoError(t, db.AutoMigrate(&featuredPost{}))
+	require.NoError(t, db.Create(&featuredPost{ID: 1, IsFeatured: true, CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}).Error)
+	for _, opts := range [][]Option[*featuredPost]{nil, {WithWindowCount[*featuredPost]()}} {
+		var debugged [][2]map[string]any
+		p := relay.New(false, 10, 10, []relay.OrderBy{
+			{Field: "IsFeatured", Desc: true},
+			{Field: "CreatedAt", Desc: true},
+		}, NewKeysetAdapter(db, append(opts, WithCursorOptions[*featuredPost](
+			cursor.WithRedactedKeys("CreatedAt"),
+			cursor.WithKeysetDebugHook(func(ctx context.Context, after, before map[string]any) {
+				debugged = append(debugged, [2]map[string]any{after, before})
+			}),
+		))...))
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{
+			After: lo.ToPtr(`{"CreatedAt":"2024-01-02T00:00:00.123456Z","IsFeatured":1}`),
+			First: lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][2]map[string]any{{{"CreatedAt": cursor.RedactedValue, "IsFeatured": true}, nil}}, debugged)
+
+		debugged = nil
+		p = relay.New(false, 10, 10, []relay.OrderBy{{Field: "CreatedAt"}}, NewKeysetAdapter(db, append(opts, WithCursorOptions[*featuredPost](
+			cursor.WithKeysetDebugHook(func(ctx context.Context, after, before map[string]any) {
+				debugged = append(debugged, [2]map[string]any{after, before})
+			}),
+		))...))
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{
+			Before: lo.ToPtr(`{"CreatedAt":"2024-01-02T00:00:00.123456Z"}`),
+			Last:   lo.ToPtr(5),
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][2]map[string]any{{nil, {"CreatedAt": time.Date(2024, 1, 2, 0, 0, 0, 123456000, time.UTC)}}}, debugged)
+
+		// the values failing to convert are rejected as before
+		_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*featuredPost]{
+			Before: lo.ToPtr(`{"CreatedAt":"x"}`),
+			Last:   lo.ToPtr(5),
+		})
+		require.ErrorIs(t, err, relay.ErrInvalidCursor)
+	}
+}
+
+func TestPaginatorStream(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	p := relay.New(false, 50, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, NewKeysetAdapter[*User](db.Session(&gorm.Session{Logger: recorder})))
+	finds := func() int {
+		return len(lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(sql, "count(") }))
+	}
+
+	stream := func(ctx context.Context, req *relay.PaginateRequest[*User], buffer int) (<-chan relay.Edge[*User], <-chan error) {
+		out := make(chan relay.Edge[*User], buffer)
+		errc := make(chan error, 1)
+		go func() {
+			errc <- p.Stream(ctx, req, 10, out)
+		}()
+		return out, errc
+	}
+
+	t.Run("Forward", func(t *testing.T) {
+		out, errc := stream(context.Background(), &relay.PaginateRequest[*User]{}, 2)
+
+		// the fetching is held back by the slow consumer, only the first page is fetched
+		first := <-out
+		require.Equal(t, 1, first.Node.ID)
+		time.Sleep(20 * time.Millisecond)
+		require.Equal(t, 1, finds())
+
+		ids := []int{first.Node.ID}
+		for edge := range out {
+			time.Sleep(100 * time.Microsecond)
+			ids = append(ids, edge.Node.ID)
+			require.NotEmpty(t, edge.Cursor)
+		}
+		require.NoError(t, <-errc)
+		require.Equal(t, lo.RangeFrom(1, 100), ids)
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		out, errc := stream(context.Background(), &relay.PaginateRequest[*User]{
+			Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: 36}, []string{"ID"})),
+			Last:   lo.ToPtr(1),
+		}, 0)
+		var ids []int
+		for edge := range out {
+			ids = append(ids, edge.Node.ID)
+		}
+		require.NoError(t, <-errc)
+		require.Equal(t, lo.Reverse(lo.RangeFrom(1, 35)), ids)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		out, errc := stream(ctx, &relay.PaginateRequest[*User]{}, 0)
+		<-out
+		cancel()
+		require.ErrorIs(t, <-errc, context.Canceled)
+		for range out {
+			// drains the edges sent before the cancellation until out is closed
+		}
+	})
+}
+
+func TestPaginatorEachBatch(t *testing.T) {
+	resetDB(t)
+
+	for _, nodesOnly := range []bool{false, true} {
+		t.Run(fmt.Sprintf("NodesOnly %v", nodesOnly), func(t *testing.T) {
+			p := relay.New(nodesOnly, 10, 5, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db))
+
+			var batches [][]int
+			err := p.EachBatch(context.Background(), &relay.PaginateRequest[*User]{}, func(nodes []*User) error {
+				batches = append(batches, lo.Map(nodes, func(user *User, _ int) int { return user.ID }))
+				return nil
+			})
+			require.NoError(t, err)
+			// batches of the max limit rather than the default limit
+			require.Len(t, batches, 10)
+			for _, batch := range batches {
+				require.Len(t, batch, 10)
+			}
+			require.Equal(t, lo.RangeFrom(1, 100), lo.Flatten(batches))
+
+			// backward in the walking order
+			var ids []int
+			err = p.EachBatch(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(1)}, func(nodes []*User) error {
+				ids = append(ids, lo.Map(nodes, func(user *User, _ int) int { return user.ID })...)
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeWithSteps(100, 0, -1), ids)
+		})
+	}
+
+	p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db))
+
+	// stops at the first error of fn
+	errStop := errors.New("stop")
+	batches := 0
+	err := p.EachBatch(context.Background(), &relay.PaginateRequest[*User]{}, func(nodes []*User) error {
+		batches++
+		if batches == 3 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 3, batches)
+
+	// and on the cancellation of the context
+	ctx, cancel := context.WithCancel(context.Background())
+	batches = 0
+	err = p.EachBatch(ctx, &relay.PaginateRequest[*User]{}, func(nodes []*User) error {
+		batches++
+		cancel()
+		return nil
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, batches)
+}
+
+func TestRowValueComparison(t *testing.T) {
+	resetDB(t)
+
+	for _, orderBys := range [][]relay.OrderBy{
+		{{Field: "Age", Desc: false}, {Field: "Name", Desc: false}},
+		{{Field: "Age", Desc: true}, {Field: "ID", Desc: true}},
+		{{Field: "Age", Desc: true}, {Field: "Name", Desc: false}},
+	} {
+		t.Run(fmt.Sprint(orderBys), func(t *testing.T) {
+			walk := func(opts ...Option[*User]) (forward, backward []int) {
+				p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db, opts...))
+				for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}) {
+					require.NoError(t, err)
+					forward = append(forward, lo.Map(page.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })...)
+				}
+				for page, err := range p.All(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(7)}) {
+					require.NoError(t, err)
+					backward = append(lo.Map(page.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }), backward...)
+				}
+				return forward, backward
+			}
+			expectedForward, expectedBackward := walk()
+			require.Len(t, expectedForward, 100)
+			require.Equal(t, expectedForward, expectedBackward)
+
+			forward, backward := walk(WithRowValueComparison[*User]())
+			require.Equal(t, expectedForward, forward)
+			require.Equal(t, expectedForward, backward)
+
+			forward, _ = walk(WithRowValueComparison[*User](), WithNamedParams[*User]())
+			require.Equal(t, expectedForward, forward)
+		})
+	}
+}
+
+func TestMaxLimitBoundary(t *testing.T) {
+	resetDB(t)
+
+	const maxLimit = 20
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]{
+		"keyset": NewKeysetAdapter[*User],
+		"offset": NewOffsetAdapter[*User],
+	} {
+		t.Run(name, func(t *testing.T) {
+			recorder := newSQLRecorder()
+			p := relay.New(false, maxLimit, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, f(db.Session(&gorm.Session{Logger: recorder})))
+			cursorOf := func(id int) *string {
+				if name == "keyset" {
+					return lo.ToPtr(mustEncodeKeysetCursor(&User{ID: id}, []string{"ID"}))
+				}
+				return lo.ToPtr(cursor.EncodeOffsetCursor(id - 1))
+			}
+
+			// one more row is fetched to detect the next page
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(1, maxLimit), ids(resp))
+			require.Equal(t, maxLimit, resp.AppliedLimit)
+			require.Equal(t, 100, resp.PageInfo.TotalCount)
+			require.True(t, resp.PageInfo.HasNextPage)
+			require.Contains(t, recorder.SQLs()[0], fmt.Sprintf("LIMIT %d", maxLimit+1))
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(81, maxLimit), ids(resp))
+			require.True(t, resp.PageInfo.HasPreviousPage)
+			require.False(t, resp.PageInfo.HasNextPage)
+
+			// exactly maxLimit rows are left
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: cursorOf(80), First: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(81, maxLimit), ids(resp))
+			require.False(t, resp.PageInfo.HasNextPage)
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: cursorOf(21), Last: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(1, maxLimit), ids(resp))
+			require.False(t, resp.PageInfo.HasPreviousPage)
+			require.True(t, resp.PageInfo.HasNextPage)
+
+			// one more than maxLimit rows are left
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: cursorOf(79), First: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(80, maxLimit), ids(resp))
+			require.True(t, resp.PageInfo.HasNextPage)
+
+			resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: cursorOf(22), Last: lo.ToPtr(maxLimit)})
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(2, maxLimit), ids(resp))
+			require.True(t, resp.PageInfo.HasPreviousPage)
+
+			// maxLimit rows between the cursors, which exist on both sides
+			for _, req := range []*relay.PaginateRequest[*User]{
+				{After: cursorOf(10), Before: cursorOf(31), First: lo.ToPtr(maxLimit)},
+				{After: cursorOf(10), Before: cursorOf(31), Last: lo.ToPtr(maxLimit)},
+			} {
+				resp, err = p.Paginate(context.Background(), req)
+				require.NoError(t, err)
+				require.Equal(t, lo.RangeFrom(11, maxLimit), ids(resp))
+				require.True(t, resp.PageInfo.HasNextPage)
+				require.True(t, resp.PageInfo.HasPreviousPage)
+			}
+
+			_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(maxLimit + 1)})
+			require.ErrorContains(t, err, "first must be less than or equal to max limit")
+			_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(maxLimit + 1)})
+			require.ErrorContains(t, err, "last must be less than or equal to max limit")
+		})
+	}
+}
+
+func TestTotalCountZero(t *testing.T) {
+	resetDB(t)
+	require.NoError(t, db.Exec("DELETE FROM users").Error)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[*User], error) {
+				return f(db)(ctx, req)
+			},
+		)
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, 0, resp.PageInfo.TotalCount)
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+}
+
+func TestSessionConfig(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User], after string) {
+		paginate := func(db *gorm.DB, config *gorm.Session) {
+			p := relay.New(
+				false,
+				10, 10,
+				[]relay.OrderBy{
+					{Field: "ID", Desc: false},
+				},
+				f(db, WithSessionConfig[*User](config)),
+			)
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: lo.ToPtr(after),
+				First: lo.ToPtr(10),
+			})
+			require.NoError(t, err)
+			require.Len(t, resp.Edges, 10)
+			require.Equal(t, 100, resp.PageInfo.TotalCount)
+		}
+
+		// applied to both the count and find executions
+		recorder := newSQLRecorder()
+		paginate(db, &gorm.Session{Logger: recorder})
+		sqls := recorder.SQLs()
+		require.Len(t, sqls, 2)
+		require.Contains(t, sqls[0], "count(*)")
+		require.Contains(t, sqls[1], "SELECT *")
+
+		// silenced
+		recorder = newSQLRecorder()
+		paginate(db.Session(&gorm.Session{Logger: recorder}), &gorm.Session{Logger: logger.Discard})
+		require.Empty(t, recorder.SQLs())
+	}
+
+	t.Run("keyset", func(t *testing.T) {
+		testCase(t, NewKeysetAdapter, mustEncodeKeysetCursor(&User{ID: 5}, []string{"ID"}))
+	})
+	t.Run("offset", func(t *testing.T) {
+		testCase(t, NewOffsetAdapter, cursor.EncodeOffsetCursor(4))
+	})
+}
+
+func TestPreOrderedDB(t *testing.T) {
+	resetDB(t)
+
+	testCase := func(t *testing.T, f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]) {
+		p := relay.New(
+			false,
+			10, 10,
+			[]relay.OrderBy{
+				{Field: "ID", Desc: false},
+			},
+			f(db.Order("age DESC")),
+		)
+		for _, req := range []*relay.PaginateRequest[*User]{
+			{First: lo.ToPtr(10)},
+			{Last: lo.ToPtr(10)},
+		} {
+			resp, err := p.Paginate(context.Background(), req)
+			require.ErrorContains(t, err, "db must not have an ORDER BY clause, the order of pagination is specified by orderBys")
+			require.Nil(t, resp)
+		}
+	}
+
+	t.Run("keyset", func(t *testing.T) { testCase(t, NewKeysetAdapter) })
+	t.Run("offset", func(t *testing.T) { testCase(t, NewOffsetAdapter) })
+
+	// counters ignore it
+	totalCount, err := NewKeysetCounter[*User](db.Order("age DESC")).Count(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 100, totalCount)
+}
+
+func TestKeysetDirectionsOracle(t *testing.T) {
+	resetDB(t)
+	// duplicated ages, so that the second column matters
+	require.NoError(t, db.Exec("UPDATE users SET age = id % 7").Error)
+
+	var users []*User
+	require.NoError(t, db.Find(&users).Error)
+
+	positions := []int{-1, 0, 13, 50, 51, 98, 99} // -1 means nil
+	limits := []int{0, 1, 5, 200}
+
+	for _, ageDesc := range []bool{false, true} {
+		for _, idDesc := range []bool{false, true} {
+			orderBys := []relay.OrderBy{
+				{Field: "Age", Desc: ageDesc},
+				{Field: "ID", Desc: idDesc},
+			}
+
+			// brute-force oracle
+			sorted := append([]*User(nil), users...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				a, b := sorted[i], sorted[j]
+				if a.Age != b.Age {
+					return (a.Age < b.Age) != ageDesc
+				}
+				return (a.ID < b.ID) != idDesc
+			})
+
+			p := relay.New(false, 200, 10, orderBys, NewKeysetAdapter[*User](db.Session(&gorm.Session{Logger: logger.Discard})))
+			keys := []string{"Age", "ID"}
+
+			for _, afterPos := range positions {
+				for _, beforePos := range positions {
+					if afterPos >= 0 && beforePos >= 0 && afterPos >= beforePos {
+						continue
+					}
+					for _, fromLast := range []bool{false, true} {
+						for _, limit := range limits {
+							name := fmt.Sprintf("age desc %v/id desc %v/after %d/before %d/last %v/limit %d", ageDesc, idDesc, afterPos, beforePos, fromLast, limit)
+
+							req := &relay.PaginateRequest[*User]{}
+							start, end := 0, len(sorted)
+							if afterPos >= 0 {
+								req.After = lo.ToPtr(mustEncodeKeysetCursor(sorted[afterPos], keys))
+								start = afterPos + 1
+							}
+							if beforePos >= 0 {
+								req.Before = lo.ToPtr(mustEncodeKeysetCursor(sorted[beforePos], keys))
+								end = beforePos
+							}
+							window := sorted[start:end]
+							expected := window
+							var hasNext, hasPrev bool
+							if fromLast {
+								req.Last = lo.ToPtr(limit)
+								if len(window) > limit {
+									expected = window[len(window)-limit:]
+									hasPrev = true
+								}
+							} else {
+								req.First = lo.ToPtr(limit)
+								if len(window) > limit {
+									expected = window[:limit]
+									hasNext = true
+								}
+							}
+							hasNext = hasNext || req.Before != nil
+							hasPrev = hasPrev || req.After != nil
+
+							resp, err := p.Paginate(context.Background(), req)
+							require.NoError(t, err, name)
+							require.Equal(t,
+								lo.Map(expected, func(u *User, _ int) int { return u.ID }),
+								lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }),
+								name,
+							)
+							require.Equal(t, hasNext, resp.PageInfo.HasNextPage, name)
+							require.Equal(t, hasPrev, resp.PageInfo.HasPreviousPage, name)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+type collatedUser struct {
+	ID  string `gorm:"primarykey"`
+	Age int
+}
+
+func TestStringCollation(t *testing.T) {
+	// a case-insensitive collation of the primary key, which doesn't sort bytewise as Go does
+	require.NoError(t, db.Exec("DROP TABLE IF EXISTS collated_users").Error)
+	if db.Dialector.Name() == "postgres" {
+		require.NoError(t, db.Exec(`CREATE COLLATION IF NOT EXISTS case_insensitive (provider = icu, locale = 'und-u-ks-level2', deterministic = false)`).Error)
+		require.NoError(t, db.Exec(`CREATE TABLE collated_users (id TEXT COLLATE case_insensitive PRIMARY KEY, age INTEGER NOT NULL)`).Error)
+	} else {
+		require.NoError(t, db.Exec(`CREATE TABLE collated_users (id TEXT COLLATE NOCASE PRIMARY KEY, age INTEGER NOT NULL)`).Error)
+	}
+	var users []*collatedUser
+	for i, id := range []string{"Juliet", "alpha", "Hotel", "charlie", "Foxtrot", "echo", "Delta", "golf", "Bravo", "india"} {
+		users = append(users, &collatedUser{ID: id, Age: i % 3})
+	}
+	require.NoError(t, db.Create(users).Error)
+
+	caseInsensitive := func(a, b string) int {
+		return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+	}
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	newPagination := func(f relay.ApplyCursorsFunc[*collatedUser]) *relay.Paginator[*collatedUser] {
+		return relay.New(false, 10, 10, orderBys, f)
+	}
+	dbPagination := newPagination(NewKeysetAdapter[*collatedUser](db))
+	slicePagination := newPagination(cursor.NewKeysetAdapter[*collatedUser](
+		cursor.NewSliceKeysetFinder(users, cursor.WithStringCollation(caseInsensitive)),
+	))
+	ids := func(resp *relay.PaginateResponse[*collatedUser]) []string {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*collatedUser], _ int) string { return edge.Node.ID })
+	}
+
+	for _, req := range []func(cursor *string) *relay.PaginateRequest[*collatedUser]{
+		func(cursor *string) *relay.PaginateRequest[*collatedUser] {
+			return &relay.PaginateRequest[*collatedUser]{After: cursor, First: lo.ToPtr(3)}
+		},
+		func(cursor *string) *relay.PaginateRequest[*collatedUser] {
+			return &relay.PaginateRequest[*collatedUser]{Before: cursor, Last: lo.ToPtr(3)}
+		},
+	} {
+		var all []string
+		var c *string
+		for {
+			// the pages of both finders match with the cursors of either
+			dbResp, err := dbPagination.Paginate(context.Background(), req(c))
+			require.NoError(t, err)
+			sliceResp, err := slicePagination.Paginate(context.Background(), req(c))
+			require.NoError(t, err)
+			require.Equal(t, ids(dbResp), ids(sliceResp))
+			require.Equal(t, dbResp.PageInfo, sliceResp.PageInfo)
+
+			if req(nil).First != nil {
+				all = append(all, ids(dbResp)...)
+				if !dbResp.PageInfo.HasNextPage {
+					break
+				}
+				c = dbResp.PageInfo.EndCursor
+			} else {
+				all = append(ids(dbResp), all...)
+				if !dbResp.PageInfo.HasPreviousPage {
+					break
+				}
+				c = dbResp.PageInfo.StartCursor
+			}
+		}
+		require.Equal(t, []string{"alpha", "Bravo", "charlie", "Delta", "echo", "Foxtrot", "golf", "Hotel", "india", "Juliet"}, all)
+	}
+
+	// bytewise, the uppercase letters sort first
+	resp, err := newPagination(cursor.NewKeysetAdapter[*collatedUser](cursor.NewSliceKeysetFinder(users))).Paginate(
+		context.Background(), &relay.PaginateRequest[*collatedUser]{First: lo.ToPtr(3)},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Bravo", "Delta", "Foxtrot"}, ids(resp))
 }
@@ -3,6 +3,8 @@ package gormrelay
 import (
 	"context"
 	"reflect"
+	"strconv"
+	"strings"
 
 	relay "github.com/molon/gorelay"
 	"github.com/molon/gorelay/cursor"
@@ -11,90 +13,142 @@ import (
 	"gorm.io/gorm/clause"
 )
 
-func NewOffsetFinder[T any](db *gorm.DB) cursor.OffsetFinder[T] {
+func NewOffsetFinder[T any](db *gorm.DB, opts ...Option[T]) cursor.OffsetFinder[T] {
+	o := newOptions(opts)
 	return cursor.OffsetFinderFunc[T](func(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, error) {
+		if o.postFilter != nil {
+			return nil, errors.New("post filter is not supported by offset pagination")
+		}
+		if o.dedupBy != nil {
+			return nil, errors.New("dedup is not supported by offset pagination")
+		}
+		if len(o.distinctOn) > 0 {
+			return nil, errors.New("distinct on is not supported by offset pagination")
+		}
+
 		var nodes []T
 
 		if limit == 0 {
 			return nodes, nil
 		}
 
-		db := db
-		if db.Statement.Context != ctx {
-			db = db.WithContext(ctx)
-		}
+		return limitConcurrency(ctx, o.concurrencyLimiter, func() ([]T, error) {
+			return retryTransient(ctx, o.transientRetry, func() ([]T, error) {
+				return findByOffset[T](prepareDB(ctx, db, o), orderBys, skip, limit, o, nil)
+			})
+		})
+	})
+}
 
-		if skip > 0 {
-			db = db.Offset(skip)
-		}
+// findByOffset also selects the total count into total by a window function, if total is not nil
+func findByOffset[T any](db *gorm.DB, orderBys []relay.OrderBy, skip, limit int, opts *options[T], total *int) ([]T, error) {
+	var nodes []T
+
+	if err := checkNoOrderBy(db); err != nil {
+		return nil, err
+	}
+
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return nil, err
+	}
 
-		db = db.Limit(limit)
+	if !basedOnModel && db.Statement.Model == nil {
+		var t T
+		db = db.Model(t)
+	}
 
-		basedOnModel, err := shouldBasedOnModel[T](db)
+	// Before wrapping into subqueries, so that the hint is of the table
+	db = applyIndexHint(db, opts.indexHint)
+
+	if len(opts.computedFields) > 0 || len(opts.timeBuckets) > 0 {
+		db, err = computeFields(db, opts.computedFields, opts.timeBuckets)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		if !basedOnModel && db.Statement.Model == nil {
-			var t T
-			db = db.Model(t)
+	if total != nil {
+		db, err = selectWindowTotal(db, false)
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		if len(orderBys) > 0 {
-			s, err := parseSchema(db, db.Statement.Model)
-			if err != nil {
-				return nil, err
-			}
+	if skip > 0 {
+		db = db.Offset(skip)
+	}
 
-			orderByColumns := make([]clause.OrderByColumn, 0, len(orderBys))
-			for _, orderBy := range orderBys {
+	db = db.Limit(limit)
+
+	if len(orderBys) > 0 {
+		s, err := parseSchema(db, db.Statement.Model)
+		if err != nil {
+			return nil, err
+		}
+
+		orderByColumns := make([]clause.OrderByColumn, 0, len(orderBys))
+		for _, orderBy := range orderBys {
+			var column clause.Column
+			if expr, ok := opts.orderExprs[orderBy.Field]; ok {
+				column = clause.Column{Name: expr, Raw: true}
+			} else {
 				field, ok := s.FieldsByName[orderBy.Field]
 				if !ok {
 					return nil, errors.Errorf("missing field %q in schema", orderBy.Field)
 				}
-
-				orderByColumns = append(orderByColumns, clause.OrderByColumn{
-					Column: clause.Column{Name: field.DBName},
-					Desc:   orderBy.Desc,
-				})
+				column = clause.Column{Name: field.DBName}
+			}
+			if orderBy.Collate != "" {
+				column = collateColumn(db, column, orderBy.Collate)
 			}
-			db = db.Order(clause.OrderBy{Columns: orderByColumns})
-		}
 
-		if basedOnModel {
-			modelType := reflect.TypeOf(db.Statement.Model)
-			sliceType := reflect.SliceOf(modelType)
-			nodesVal := reflect.New(sliceType).Elem()
+			orderByColumns = append(orderByColumns, orderByColumn(db.Statement, column, orderBy, false))
+		}
+		db = db.Order(clause.OrderBy{Columns: orderByColumns})
+	}
 
-			err := db.Find(nodesVal.Addr().Interface()).Error
-			if err != nil {
-				return nil, errors.Wrap(err, "find")
-			}
+	find := func(dest any) error {
+		if total != nil {
+			return findWithWindowTotal(db, dest, total)
+		}
+		return errors.Wrap(db.Find(dest).Error, "find")
+	}
 
-			nodes := make([]T, nodesVal.Len())
-			for i := 0; i < nodesVal.Len(); i++ {
-				nodes[i] = nodesVal.Index(i).Interface().(T)
-			}
+	if basedOnModel {
+		modelType := reflect.TypeOf(db.Statement.Model)
+		sliceType := reflect.SliceOf(modelType)
+		nodesVal := reflect.New(sliceType).Elem()
 
-			return nodes, nil
+		if err := find(nodesVal.Addr().Interface()); err != nil {
+			return nil, err
 		}
 
-		if err := db.Find(&nodes).Error; err != nil {
-			return nil, errors.Wrap(err, "find")
+		nodes := make([]T, nodesVal.Len())
+		for i := 0; i < nodesVal.Len(); i++ {
+			nodes[i] = nodesVal.Index(i).Interface().(T)
 		}
+
 		return nodes, nil
-	})
+	}
+
+	if err := find(&nodes); err != nil {
+		return nil, err
+	}
+	return nodes, nil
 }
 
 type OffsetCounter[T any] struct {
 	db     *gorm.DB
 	finder cursor.OffsetFinder[T]
+	opts   *options[T]
 }
 
-func NewOffsetCounter[T any](db *gorm.DB) *OffsetCounter[T] {
+func NewOffsetCounter[T any](db *gorm.DB, opts ...Option[T]) *OffsetCounter[T] {
 	return &OffsetCounter[T]{
 		db:     db,
-		finder: NewOffsetFinder[T](db),
+		finder: NewOffsetFinder[T](db, opts...),
+		opts:   newOptions(opts),
 	}
 }
 
@@ -102,30 +156,165 @@ func (a *OffsetCounter[T]) Find(ctx context.Context, orderBys []relay.OrderBy, s
 	return a.finder.Find(ctx, orderBys, skip, limit)
 }
 
+// Count counts exactly, even under WithMaxPKCount, e.g. to locate the last page
 func (a *OffsetCounter[T]) Count(ctx context.Context) (int, error) {
-	db := a.db
+	opts := *a.opts
+	opts.maxPKCount = false
+	totalCount, _, err := count[T](ctx, a.db, &opts)
+	return totalCount, err
+}
 
-	basedOnModel, err := shouldBasedOnModel[T](db)
+func (a *OffsetCounter[T]) ApproximateCount(ctx context.Context) (int, bool, error) {
+	return count[T](ctx, a.db, a.opts)
+}
+
+func (a *OffsetCounter[T]) SkipCount(_ context.Context) bool {
+	return a.opts.skipCount()
+}
+
+// OffsetCounterWindowed is an OffsetCounter which also selects the total count with the page by `COUNT(*) OVER ()`,
+// so that cursor.NewOffsetAdapter fetches both in one round trip, it requires window functions, e.g. Postgres, MySQL 8 or SQLite 3.25.
+type OffsetCounterWindowed[T any] struct {
+	*OffsetCounter[T]
+}
+
+func NewOffsetCounterWindowed[T any](db *gorm.DB, opts ...Option[T]) *OffsetCounterWindowed[T] {
+	return &OffsetCounterWindowed[T]{OffsetCounter: NewOffsetCounter[T](db, opts...)}
+}
+
+func (a *OffsetCounterWindowed[T]) FindWithCount(ctx context.Context, orderBys []relay.OrderBy, skip, limit int) ([]T, int, error) {
+	if a.opts.postFilter != nil {
+		return nil, 0, errors.New("post filter is not supported by offset pagination")
+	}
+	if a.opts.dedupBy != nil {
+		return nil, 0, errors.New("dedup is not supported by offset pagination")
+	}
+	if len(a.opts.distinctOn) > 0 {
+		return nil, 0, errors.New("distinct on is not supported by offset pagination")
+	}
+
+	var totalCount int
+	nodes, err := limitConcurrency(ctx, a.opts.concurrencyLimiter, func() ([]T, error) {
+		return retryTransient(ctx, a.opts.transientRetry, func() ([]T, error) {
+			return findByOffset[T](prepareDB(ctx, a.db, a.opts), orderBys, skip, limit, a.opts, &totalCount)
+		})
+	})
 	if err != nil {
-		return 0, err
+		return nil, 0, err
+	}
+	if len(nodes) == 0 {
+		// No row carries the total, e.g. if the page is beyond the end, the adapter counts separately
+		return nodes, -1, nil
 	}
+	return nodes, totalCount, nil
+}
 
-	if !basedOnModel && db.Statement.Context != ctx {
-		db = db.WithContext(ctx)
+func NewOffsetAdapter[T any](db *gorm.DB, opts ...Option[T]) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	var next relay.ApplyCursorsFunc[T]
+	if o.offsetSnapshot != "" {
+		next = newOffsetSnapshotAdapter[T](db, o.offsetSnapshot, opts)
+	} else {
+		next = cursor.NewOffsetAdapter(newOffsetCounter[T](db, opts), o.cursorOptions...)
+	}
+	if o.pageEnricher != nil {
+		next = enrichPages(db, next, o.pageEnricher)
 	}
+	return next
+}
 
-	if db.Statement.Model == nil {
-		var t T
-		db = db.Model(t)
+func newOffsetCounter[T any](db *gorm.DB, opts []Option[T]) cursor.OffsetFinder[T] {
+	if newOptions(opts).windowCount {
+		return NewOffsetCounterWindowed[T](db, opts...)
+	}
+	return NewOffsetCounter[T](db, opts...)
+}
+
+// newOffsetSnapshotAdapter pins `MAX(column)` on the first request and prefixes it to the cursors as `<snapshot>:`,
+// subsequent requests only see the rows with `column <= snapshot`, so the rows inserted meanwhile don't shift the offsets.
+func newOffsetSnapshotAdapter[T any](db *gorm.DB, column string, opts []Option[T]) relay.ApplyCursorsFunc[T] {
+	o := newOptions(opts)
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		r := *req
+
+		var snapshot *int64
+		for _, c := range []**string{&r.After, &r.Before} {
+			if *c == nil {
+				continue
+			}
+			s, rest, err := splitOffsetSnapshot(**c)
+			if err != nil {
+				return nil, relay.MarkError(err, relay.ErrInvalidCursor)
+			}
+			if snapshot != nil && *snapshot != s {
+				return nil, relay.MarkError(errors.New("after and before have different snapshots"), relay.ErrInvalidCursor)
+			}
+			snapshot, *c = &s, &rest
+		}
+
+		if snapshot == nil {
+			s, err := limitConcurrency(ctx, o.concurrencyLimiter, func() (int64, error) {
+				return maxOfColumn[T](ctx, db, column, o)
+			})
+			if err != nil {
+				return nil, err
+			}
+			snapshot = &s
+		}
+
+		// A new session, so that a chained db is not mutated by Where
+		snapshotDB := db.Session(&gorm.Session{}).Where(clause.Lte{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: *snapshot})
+		resp, err := cursor.NewOffsetAdapter(newOffsetCounter[T](snapshotDB, opts), o.cursorOptions...)(ctx, &r)
+		if err != nil {
+			return nil, err
+		}
+
+		prefix := strconv.FormatInt(*snapshot, 10) + ":"
+		for i := range resp.Edges {
+			edge := &resp.Edges[i]
+			originalCursor := edge.Cursor
+			edge.Cursor = func(ctx context.Context, node T) (string, error) {
+				cursor, err := originalCursor(ctx, node)
+				if err != nil {
+					return "", err
+				}
+				return prefix + cursor, nil
+			}
+		}
+		return resp, nil
 	}
+}
 
-	var totalCount int64
-	if err := db.Count(&totalCount).Error; err != nil {
-		return 0, errors.Wrap(err, "count")
+func splitOffsetSnapshot(cursor string) (int64, string, error) {
+	snapshot, rest, ok := strings.Cut(cursor, ":")
+	if !ok {
+		return 0, "", errors.New("missing snapshot in cursor")
+	}
+	s, err := strconv.ParseInt(snapshot, 10, 64)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "parse snapshot")
 	}
-	return int(totalCount), nil
+	return s, rest, nil
 }
 
-func NewOffsetAdapter[T any](db *gorm.DB) relay.ApplyCursorsFunc[T] {
-	return cursor.NewOffsetAdapter(NewOffsetCounter[T](db))
+func maxOfColumn[T any](ctx context.Context, db *gorm.DB, column string, o *options[T]) (int64, error) {
+	basedOnModel, err := shouldBasedOnModel[T](db)
+	if err != nil {
+		return 0, err
+	}
+
+	db = prepareDB(ctx, db, o)
+	if !basedOnModel && db.Statement.Model == nil {
+		tType := reflect.TypeOf((*T)(nil)).Elem()
+		if tType.Kind() == reflect.Ptr {
+			tType = tType.Elem()
+		}
+		db = db.Model(reflect.New(tType).Interface())
+	}
+	var n int64
+	err = db.Select("COALESCE(MAX(?), 0)", clause.Column{Table: clause.CurrentTable, Name: column}).Scan(&n).Error
+	if err != nil {
+		return 0, errors.Wrap(err, "snapshot")
+	}
+	return n, nil
 }
@@ -2,12 +2,17 @@ package gormrelay
 
 import (
 	"context"
+	"fmt"
+	"slices"
+	"strings"
 	"testing"
 
 	relay "github.com/molon/gorelay"
 	"github.com/molon/gorelay/cursor"
+	"github.com/pkg/errors"
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestOffsetCursor(t *testing.T) {
@@ -539,3 +544,581 @@ func TestOffsetWithLastAndNilBeforeIfNoCounter(t *testing.T) {
 	require.ErrorContains(t, err, "counter is required for fromLast and nil before")
 	require.Nil(t, resp)
 }
+
+func TestOffsetEdgeIndex(t *testing.T) {
+	resetDB(t)
+
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewOffsetAdapter[*User](db),
+	)
+
+	indexes := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int {
+			require.NotNil(t, edge.Index)
+			require.Equal(t, edge.Node.ID, *edge.Index)
+			return *edge.Index
+		})
+	}
+
+	// middle page
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(cursor.EncodeOffsetCursor(29)),
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{31, 32, 33, 34, 35}, indexes(resp))
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: lo.ToPtr(cursor.EncodeOffsetCursor(49)),
+		Last:   lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{45, 46, 47, 48, 49}, indexes(resp))
+
+	// nil in keyset mode
+	resp, err = relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewKeysetAdapter[*User](db),
+	).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First: lo.ToPtr(5),
+	})
+	require.NoError(t, err)
+	for _, edge := range resp.Edges {
+		require.Nil(t, edge.Index)
+	}
+}
+
+func TestOffsetSnapshot(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	tx := db.Where("age > ?", 0)
+	p := relay.New(false, 10, 10, orderBys, NewOffsetAdapter(tx, WithOffsetSnapshot[*User]("id")))
+	drifting := relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](tx))
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(1, 10), ids(resp))
+	require.True(t, strings.HasPrefix(*resp.PageInfo.EndCursor, "100:"))
+	endCursor := *resp.PageInfo.EndCursor
+
+	driftingResp, err := drifting.Paginate(context.Background(), &relay.PaginateRequest[*User]{First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	driftingEndCursor := *driftingResp.PageInfo.EndCursor
+
+	// inserted rows are ordered before the first page
+	vs := []*User{}
+	for i := 0; i < 5; i++ {
+		vs = append(vs, &User{Name: fmt.Sprintf("new%d", i), Age: 1000 + i})
+	}
+	require.NoError(t, db.Create(vs).Error)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: &endCursor, First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(11, 10), ids(resp))
+	require.Equal(t, 100, resp.PageInfo.TotalCount)
+	require.True(t, resp.PageInfo.HasPreviousPage)
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Before: resp.PageInfo.StartCursor, Last: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(1, 10), ids(resp))
+
+	// the offsets shift without the snapshot
+	driftingResp, err = drifting.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: &driftingEndCursor, First: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, lo.RangeFrom(6, 10), ids(driftingResp))
+
+	// a new walk pins a new snapshot
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{Last: lo.ToPtr(10)})
+	require.NoError(t, err)
+	require.Equal(t, 105, resp.PageInfo.TotalCount)
+	require.True(t, strings.HasPrefix(*resp.PageInfo.StartCursor, "105:"))
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:  &endCursor,
+		Before: resp.PageInfo.StartCursor,
+		First:  lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, "after and before have different snapshots")
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(cursor.EncodeOffsetCursor(9)),
+		First: lo.ToPtr(10),
+	})
+	require.ErrorContains(t, err, "missing snapshot in cursor")
+}
+
+func TestOffsetBeforeMatchesKeyset(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "ID", Desc: false},
+	}
+	offsetPagination := relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](db))
+	keysetPagination := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	// the row at offset i has ID i+1
+	for _, before := range []int{0, 1, 2, 3, 4, 50, 99} {
+		for _, after := range []int{-1, 0, 1} {
+			if after >= before {
+				continue
+			}
+			for _, fromLast := range []bool{false, true} {
+				name := fmt.Sprintf("after %d/before %d/last %v", after, before, fromLast)
+
+				offsetReq := &relay.PaginateRequest[*User]{Before: lo.ToPtr(cursor.EncodeOffsetCursor(before))}
+				keysetReq := &relay.PaginateRequest[*User]{Before: lo.ToPtr(mustEncodeKeysetCursor(&User{ID: before + 1}, []string{"ID"}))}
+				if after >= 0 {
+					offsetReq.After = lo.ToPtr(cursor.EncodeOffsetCursor(after))
+					keysetReq.After = lo.ToPtr(mustEncodeKeysetCursor(&User{ID: after + 1}, []string{"ID"}))
+				}
+				if fromLast {
+					offsetReq.Last, keysetReq.Last = lo.ToPtr(3), lo.ToPtr(3)
+				} else {
+					offsetReq.First, keysetReq.First = lo.ToPtr(3), lo.ToPtr(3)
+				}
+
+				offsetResp, err := offsetPagination.Paginate(context.Background(), offsetReq)
+				require.NoError(t, err, name)
+				keysetResp, err := keysetPagination.Paginate(context.Background(), keysetReq)
+				require.NoError(t, err, name)
+
+				require.Equal(t, ids(keysetResp), ids(offsetResp), name)
+				require.Equal(t, keysetResp.PageInfo.HasNextPage, offsetResp.PageInfo.HasNextPage, name)
+				require.Equal(t, keysetResp.PageInfo.HasPreviousPage, offsetResp.PageInfo.HasPreviousPage, name)
+			}
+		}
+	}
+
+	// Last 3 before offset 2 has only the two rows before it
+	resp, err := offsetPagination.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: lo.ToPtr(cursor.EncodeOffsetCursor(2)),
+		Last:   lo.ToPtr(3),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, ids(resp))
+	require.False(t, resp.PageInfo.HasPreviousPage)
+	require.True(t, resp.PageInfo.HasNextPage)
+
+	// Last 3 before offset 0 is empty at the very start
+	resp, err = offsetPagination.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Before: lo.ToPtr(cursor.EncodeOffsetCursor(0)),
+		Last:   lo.ToPtr(3),
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Edges)
+	require.False(t, resp.PageInfo.HasPreviousPage)
+	require.True(t, resp.PageInfo.HasNextPage)
+}
+
+func TestOffsetLastWithoutBeforeMatchesKeyset(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID", Desc: false}}
+	keysetPagination := relay.New(false, 100, 10, orderBys, NewKeysetAdapter[*User](db.Where("age > ?", 10)))
+
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	// 90 rows ordered by age descending, the row at offset i has ID i+1
+	for _, last := range []int{1, 3, 10, 90, 95} {
+		for _, after := range []int{-1, 0, 85} {
+			name := fmt.Sprintf("after %d/last %d", after, last)
+
+			offsetReq := &relay.PaginateRequest[*User]{Last: lo.ToPtr(last)}
+			keysetReq := &relay.PaginateRequest[*User]{Last: lo.ToPtr(last)}
+			if after >= 0 {
+				offsetReq.After = lo.ToPtr(cursor.EncodeOffsetCursor(after))
+				keysetReq.After = lo.ToPtr(mustEncodeKeysetCursor(&User{ID: after + 1, Age: 100 - after}, []string{"Age", "ID"}))
+			}
+
+			recorder := newSQLRecorder()
+			offsetPagination := relay.New(false, 100, 10, orderBys, NewOffsetAdapter[*User](db.Session(&gorm.Session{Logger: recorder}).Where("age > ?", 10)))
+			offsetResp, err := offsetPagination.Paginate(context.Background(), offsetReq)
+			require.NoError(t, err, name)
+			keysetResp, err := keysetPagination.Paginate(context.Background(), keysetReq)
+			require.NoError(t, err, name)
+
+			require.Equal(t, ids(keysetResp), ids(offsetResp), name)
+			require.Equal(t, keysetResp.PageInfo.HasNextPage, offsetResp.PageInfo.HasNextPage, name)
+			require.Equal(t, keysetResp.PageInfo.HasPreviousPage, offsetResp.PageInfo.HasPreviousPage, name)
+			require.Equal(t, 90, offsetResp.PageInfo.TotalCount, name)
+
+			// the tail is read directly from the offset located by the total count, with a row more to tell HasPreviousPage
+			skip := max(90-(last+1), after+1, 0)
+			finds := lo.Filter(recorder.SQLs(), func(sql string, _ int) bool { return !strings.Contains(strings.ToLower(sql), "count(") })
+			require.Len(t, finds, 1, name)
+			if skip > 0 {
+				require.Contains(t, finds[0], fmt.Sprintf("OFFSET %d", skip), name)
+			} else {
+				require.NotContains(t, finds[0], "OFFSET", name)
+			}
+		}
+	}
+}
+
+func TestOffsetTotalPages(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	paginate := func(applyCursorsFunc relay.ApplyCursorsFunc[*User], req *relay.PaginateRequest[*User]) *relay.PaginateResponse[*User] {
+		resp, err := relay.New(false, 20, 10, orderBys, applyCursorsFunc).Paginate(context.Background(), req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	for _, tc := range []struct {
+		limit      int
+		totalPages int
+	}{
+		{limit: 10, totalPages: 10},
+		{limit: 7, totalPages: 15},
+	} {
+		t.Run(fmt.Sprintf("Limit%d", tc.limit), func(t *testing.T) {
+			resp := paginate(NewOffsetAdapter[*User](db), &relay.PaginateRequest[*User]{First: lo.ToPtr(tc.limit)})
+			require.Equal(t, lo.ToPtr(tc.totalPages), resp.TotalPages)
+
+			resp = paginate(NewOffsetAdapter[*User](db), &relay.PaginateRequest[*User]{Last: lo.ToPtr(tc.limit)})
+			require.Equal(t, lo.ToPtr(tc.totalPages), resp.TotalPages)
+			require.Len(t, resp.Edges, tc.limit)
+		})
+	}
+
+	// the limit is defaulted
+	resp := paginate(NewOffsetAdapter[*User](db), &relay.PaginateRequest[*User]{})
+	require.Equal(t, lo.ToPtr(10), resp.TotalPages)
+
+	// the first page is also the last one
+	resp = paginate(NewOffsetAdapter[*User](db.Where("id <= ?", 5)), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)})
+	require.Equal(t, lo.ToPtr(1), resp.TotalPages)
+
+	resp = paginate(NewOffsetAdapter[*User](db.Where("id > ?", 100)), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)})
+	require.Equal(t, lo.ToPtr(0), resp.TotalPages)
+
+	// not available without a counter, with a skipped count, or in keyset mode
+	resp = paginate(cursor.NewOffsetAdapter(NewOffsetFinder[*User](db)), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)})
+	require.Nil(t, resp.TotalPages)
+
+	resp = paginate(NewOffsetAdapter[*User](db, WithCountLoadShedder[*User](func() bool { return true })), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)})
+	require.Nil(t, resp.TotalPages)
+	require.True(t, resp.PageInfo.TotalCountSkipped)
+
+	resp = paginate(NewKeysetAdapter[*User](db), &relay.PaginateRequest[*User]{First: lo.ToPtr(7)})
+	require.Nil(t, resp.TotalPages)
+	require.Equal(t, 100, resp.PageInfo.TotalCount)
+}
+
+func TestOffsetZeroLimitMatchesKeyset(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "ID", Desc: false},
+	}
+	offsetPagination := relay.New(false, 10, 10, orderBys, NewOffsetAdapter[*User](db))
+	keysetPagination := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](db))
+
+	// the row at offset i has ID i+1, -1 means no cursor
+	for _, after := range []int{-1, 0, 50, 98, 99} {
+		for _, before := range []int{-1, 0, 1, 51, 99} {
+			if after >= 0 && before >= 0 && after >= before {
+				continue
+			}
+			for _, fromLast := range []bool{false, true} {
+				name := fmt.Sprintf("after %d/before %d/last %v", after, before, fromLast)
+
+				offsetReq := &relay.PaginateRequest[*User]{}
+				keysetReq := &relay.PaginateRequest[*User]{}
+				if after >= 0 {
+					offsetReq.After = lo.ToPtr(cursor.EncodeOffsetCursor(after))
+					keysetReq.After = lo.ToPtr(mustEncodeKeysetCursor(&User{ID: after + 1}, []string{"ID"}))
+				}
+				if before >= 0 {
+					offsetReq.Before = lo.ToPtr(cursor.EncodeOffsetCursor(before))
+					keysetReq.Before = lo.ToPtr(mustEncodeKeysetCursor(&User{ID: before + 1}, []string{"ID"}))
+				}
+				if fromLast {
+					offsetReq.Last, keysetReq.Last = lo.ToPtr(0), lo.ToPtr(0)
+				} else {
+					offsetReq.First, keysetReq.First = lo.ToPtr(0), lo.ToPtr(0)
+				}
+
+				offsetResp, err := offsetPagination.Paginate(context.Background(), offsetReq)
+				require.NoError(t, err, name)
+				keysetResp, err := keysetPagination.Paginate(context.Background(), keysetReq)
+				require.NoError(t, err, name)
+
+				require.Empty(t, offsetResp.Edges, name)
+				require.Equal(t, keysetResp.PageInfo, offsetResp.PageInfo, name)
+			}
+		}
+	}
+
+	// there is no next or previous page at all on an empty table
+	require.NoError(t, db.Exec("DELETE FROM users").Error)
+	for _, req := range []*relay.PaginateRequest[*User]{
+		{First: lo.ToPtr(0)},
+		{Last: lo.ToPtr(0)},
+	} {
+		offsetResp, err := offsetPagination.Paginate(context.Background(), req)
+		require.NoError(t, err)
+		keysetResp, err := keysetPagination.Paginate(context.Background(), req)
+		require.NoError(t, err)
+		require.Equal(t, relay.PageInfo{}, offsetResp.PageInfo)
+		require.Equal(t, keysetResp.PageInfo, offsetResp.PageInfo)
+	}
+}
+
+func TestOffsetRelayArrayConnectionCursors(t *testing.T) {
+	resetDB(t)
+
+	parser := cursor.RelayArrayConnectionParser{}
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewOffsetAdapter(db, WithCursorOptions[*User](cursor.WithOffsetParser(parser))),
+	)
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(parser.Encode(4)),
+		First: lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Edges, 2)
+	require.Equal(t, 6, resp.Edges[0].Node.ID)
+	require.Equal(t, lo.ToPtr(parser.Encode(5)), resp.PageInfo.StartCursor)
+	require.Equal(t, lo.ToPtr(parser.Encode(6)), resp.PageInfo.EndCursor)
+
+	_, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(cursor.EncodeOffsetCursor(4)),
+		First: lo.ToPtr(2),
+	})
+	require.ErrorContains(t, err, "decode arrayconnection cursor")
+}
+
+// hashedOffsetParser prefixes offset cursors with the hash of the query they are issued for
+type hashedOffsetParser struct {
+	hash string
+}
+
+func (p hashedOffsetParser) Encode(offset int) string {
+	return p.hash + ":" + cursor.EncodeOffsetCursor(offset)
+}
+
+func (p hashedOffsetParser) Decode(c string) (int, error) {
+	hash, offset, ok := strings.Cut(c, ":")
+	if !ok || hash != p.hash {
+		return 0, errors.New("cursor of another query")
+	}
+	return cursor.DecodeOffsetCursor(offset)
+}
+
+func TestOffsetCustomParser(t *testing.T) {
+	resetDB(t)
+
+	newPagination := func(hash string) *relay.Paginator[*User] {
+		return relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewOffsetAdapter(db.Where("age > ?", 70),
+			WithCursorOptions[*User](cursor.WithOffsetParser(hashedOffsetParser{hash: hash})),
+		))
+	}
+	p := newPagination("q1")
+
+	for _, backward := range []bool{false, true} {
+		req := &relay.PaginateRequest[*User]{First: lo.ToPtr(7)}
+		if backward {
+			req = &relay.PaginateRequest[*User]{Last: lo.ToPtr(7)}
+		}
+		var ids []int
+		for page, err := range p.All(context.Background(), req) {
+			require.NoError(t, err)
+			for _, edge := range page.Edges {
+				require.True(t, strings.HasPrefix(edge.Cursor, "q1:"), edge.Cursor)
+				ids = append(ids, edge.Node.ID)
+			}
+		}
+		if backward {
+			slices.Sort(ids)
+		}
+		require.Equal(t, lo.RangeFrom(1, 30), ids)
+	}
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{After: lo.ToPtr("q1:4"), First: lo.ToPtr(2)})
+	require.NoError(t, err)
+	require.Equal(t, []int{6, 7}, lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+
+	// the cursors of the parser are rejected as invalid cursors by the pagination of another query
+	for _, req := range []*relay.PaginateRequest[*User]{
+		{After: resp.PageInfo.EndCursor, First: lo.ToPtr(2)},
+		{Before: resp.PageInfo.StartCursor, Last: lo.ToPtr(2)},
+		{After: lo.ToPtr("4"), First: lo.ToPtr(2)},
+	} {
+		_, err := newPagination("q2").Paginate(context.Background(), req)
+		require.ErrorIs(t, err, relay.ErrInvalidCursor)
+		require.ErrorContains(t, err, "cursor of another query")
+	}
+}
+
+func TestOffsetDebugHook(t *testing.T) {
+	resetDB(t)
+
+	type boundaries struct{ after, before *int }
+	var audited []boundaries
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewOffsetAdapter(db, WithCursorOptions[*User](cursor.WithOffsetDebugHook(func(ctx context.Context, after, before *int) {
+			audited = append(audited, boundaries{after, before})
+		}))),
+	)
+
+	for _, tc := range []struct {
+		req      *relay.PaginateRequest[*User]
+		expected boundaries
+	}{
+		{&relay.PaginateRequest[*User]{First: lo.ToPtr(5)}, boundaries{}},
+		{&relay.PaginateRequest[*User]{After: lo.ToPtr(cursor.EncodeOffsetCursor(4)), First: lo.ToPtr(5)}, boundaries{after: lo.ToPtr(4)}},
+		{&relay.PaginateRequest[*User]{
+			After:  lo.ToPtr(cursor.EncodeOffsetCursor(4)),
+			Before: lo.ToPtr(cursor.EncodeOffsetCursor(8)),
+			First:  lo.ToPtr(5),
+		}, boundaries{after: lo.ToPtr(4), before: lo.ToPtr(8)}},
+		// the last page is located by the total count
+		{&relay.PaginateRequest[*User]{Last: lo.ToPtr(5)}, boundaries{before: lo.ToPtr(100)}},
+	} {
+		audited = nil
+		_, err := p.Paginate(context.Background(), tc.req)
+		require.NoError(t, err)
+		require.Equal(t, []boundaries{tc.expected}, audited)
+	}
+}
+
+func TestOffsetRequestOrderBys(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		NewOffsetAdapter[*User](db.Session(&gorm.Session{Logger: recorder})),
+	)
+	orderBys := []relay.OrderBy{
+		{Field: "Name", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:    lo.ToPtr(cursor.EncodeOffsetCursor(2)),
+		First:    lo.ToPtr(3),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+	// names are compared as strings, "name99" > "name98" > ... > "name10" > "name1" > "name0"
+	require.Equal(t, []int{97, 96, 95}, ids(resp))
+	sqls := recorder.SQLs()
+	require.Regexp(t, "ORDER BY .*name.* DESC,.*id.* LIMIT 4 OFFSET 3$", sqls[len(sqls)-1])
+
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Last:     lo.ToPtr(3),
+		OrderBys: orderBys,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{11, 2, 1}, ids(resp))
+	sqls = recorder.SQLs()
+	require.Regexp(t, "ORDER BY .*name.* DESC,.*id.* LIMIT 4 OFFSET 96$", sqls[len(sqls)-1])
+}
+
+func TestOffsetCounterWindowed(t *testing.T) {
+	resetDB(t)
+
+	recorder := newSQLRecorder()
+	p := relay.New(
+		false,
+		10, 10,
+		[]relay.OrderBy{
+			{Field: "ID", Desc: false},
+		},
+		cursor.NewOffsetAdapter(NewOffsetCounterWindowed[*User](db.Session(&gorm.Session{Logger: recorder}).Where("id <= ?", 95))),
+	)
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return int(edge.Node.ID) })
+	}
+
+	// a middle page and its total by one query
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(cursor.EncodeOffsetCursor(39)),
+		First: lo.ToPtr(3),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{41, 42, 43}, ids(resp))
+	require.Equal(t, 95, resp.PageInfo.TotalCount)
+	require.True(t, resp.PageInfo.HasPreviousPage)
+	require.True(t, resp.PageInfo.HasNextPage)
+	sqls := recorder.SQLs()
+	require.Len(t, sqls, 1)
+	require.Contains(t, sqls[0], "COUNT(*) OVER ()")
+	require.Equal(t, "name40", resp.Edges[0].Node.Name)
+
+	// the last page is located by counting first
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		Last: lo.ToPtr(2),
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{94, 95}, ids(resp))
+	require.Equal(t, 95, resp.PageInfo.TotalCount)
+	require.Len(t, recorder.SQLs(), 3)
+
+	// a page beyond the end has no row carrying the total, so it falls back to counting
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After: lo.ToPtr(cursor.EncodeOffsetCursor(199)),
+		First: lo.ToPtr(3),
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Edges)
+	require.Equal(t, 95, resp.PageInfo.TotalCount)
+	require.False(t, resp.PageInfo.HasNextPage)
+	require.Len(t, recorder.SQLs(), 5)
+
+	// no window if the count is skipped
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		After:          lo.ToPtr(cursor.EncodeOffsetCursor(39)),
+		First:          lo.ToPtr(3),
+		SkipTotalCount: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{41, 42, 43}, ids(resp))
+	sqls = recorder.SQLs()
+	require.Len(t, sqls, 6)
+	require.NotContains(t, sqls[5], "OVER")
+}
@@ -0,0 +1,383 @@
+package gormrelay
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// keysetOptions are the options for building keyset queries
+type keysetOptions struct {
+	inclusiveLastColumn bool
+	timestampLocations  map[string]*time.Location
+	namedParams         bool
+	aggregateExprs      map[string]string
+	orderExprs          map[string]string
+	rowValues           bool
+	floatPrecisions     map[string]int
+	roundedExprs        map[string]string        // the expressions of floatPrecisions, resolved per statement
+	relatedColumns      map[string]clause.Column // the columns of the fields of relations, resolved per statement
+	qualifyColumns      bool                     // qualify the columns by the table of the statement, e.g. if there are joins
+	collatedColumns     map[string]clause.Column // the columns of the order bys with Collate, resolved per statement
+	redactedKeys        map[string]bool          // the keys of cursor.WithRedactedKeys, whose values are kept out of errors
+}
+
+// countOptions are the options for counting
+type countOptions struct {
+	maxPKCount  bool
+	countColumn string
+}
+
+type options[T any] struct {
+	keysetOptions
+	countOptions
+	countLoadShedder   func() bool
+	batch              *Batch
+	postFilter         func(T) bool
+	dedupBy            func(T) any
+	maxRefills         int
+	distinctOn         []string
+	computedFields     map[string]string
+	timeBuckets        map[string]timeBucket
+	onlyDeleted        bool
+	pageEnricher       func(ctx context.Context, db *gorm.DB, nodes []T) error
+	cursorOptions      []cursor.Option
+	sessionConfig      *gorm.Session
+	offsetSnapshot     string
+	transientRetry     *transientRetry
+	indexHint          string
+	uniqueOrderCheck   bool
+	windowCount        bool
+	concurrencyLimiter chan struct{}
+	// warn of WithPrimaryKeyTiebreak, non-nil if enabled
+	primaryKeyTiebreak *func(ctx context.Context, orderBys []relay.OrderBy)
+}
+
+type Option[T any] func(*options[T])
+
+func newOptions[T any](opts []Option[T]) *options[T] {
+	o := &options[T]{maxRefills: DefaultMaxRefills}
+	for _, opt := range opts {
+		opt(o)
+	}
+	o.redactedKeys = cursor.RedactedKeys(o.cursorOptions...)
+	return o
+}
+
+// WithMaxPKCount makes the counter estimate the total count with `MAX(pk) - MIN(pk) + 1`,
+// which is much cheaper than `COUNT(*)` on large tables with an auto-increment integer primary key.
+// It only takes effect if the query has no filters (no WHERE, JOIN, GROUP BY, DISTINCT or soft delete),
+// otherwise the exact count is used. The result is reported as approximate because gaps can't be detected.
+// Where the count locates the page, i.e. offset pagination by last without before, the exact count is used regardless.
+func WithMaxPKCount[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.maxPKCount = true
+	}
+}
+
+// WithCountLoadShedder makes the counter skip counting if shedder returns true, e.g. under high load.
+// The response reports the skip with PageInfo.TotalCountSkipped, so clients can retry for the total later.
+func WithCountLoadShedder[T any](shedder func() bool) Option[T] {
+	return func(o *options[T]) {
+		o.countLoadShedder = shedder
+	}
+}
+
+func (o *options[T]) skipCount() bool {
+	return o.countLoadShedder != nil && o.countLoadShedder()
+}
+
+// dropsNodes reports whether nodes may be dropped after fetching, by WithPostFilter or WithDedupBy
+func (o *options[T]) dropsNodes() bool {
+	return o.postFilter != nil || o.dedupBy != nil
+}
+
+// WithInclusiveLastColumn makes keyset queries compare the last order by field with `>=`/`<=` instead of `>`/`<`,
+// so rows tying with the cursor on all fields, including the cursor row itself, are part of the adjacent page.
+// It's for orderBys without a unique tiebreak (e.g. created_at only), where the exclusive comparison skips the rows
+// tying with the cursor, and the inclusive one repeats them across pages instead.
+func WithInclusiveLastColumn[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.inclusiveLastColumn = true
+	}
+}
+
+// WithNamedParams binds the cursor values of keyset conditions by name with sql.Named, e.g. `"age" > @age0`,
+// with the suffix 0 for the after cursor and 1 for the before cursor, so the values can be correlated by name in the conditions of the statement.
+// GORM resolves the names when building the statement, so the SQL sent to the database has the placeholders of the dialect as usual.
+func WithNamedParams[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.namedParams = true
+	}
+}
+
+// WithAggregateField is for a field of T computed by the aggregate expr (e.g. `SUM(amount)`) in the select list of a grouped query,
+// e.g. `db.Model(&Order{}).Select("customer_id, SUM(amount) AS total").Group("customer_id")`.
+// Keyset conditions are applied in HAVING with expr, since aggregates can't be compared in WHERE, and the ordering is by expr as well.
+// The total count of groups is counted by wrapping the grouped query.
+func WithAggregateField[T any](field string, expr string) Option[T] {
+	return func(o *options[T]) {
+		if o.aggregateExprs == nil {
+			o.aggregateExprs = map[string]string{}
+		}
+		o.aggregateExprs[field] = expr
+	}
+}
+
+// WithOrderExpr orders and compares field by the SQL expression expr (e.g. `LOWER("name")`) instead of its column,
+// in ORDER BY and the keyset conditions alike. The cursors still encode the value of field, which must equal expr of the row,
+// e.g. normalized by cursor.WithKeysetNormalizer, or a read-only field selected as expr. Since expr may tie on different rows,
+// the order bys should end with a unique column.
+func WithOrderExpr[T any](field string, expr string) Option[T] {
+	return func(o *options[T]) {
+		if o.orderExprs == nil {
+			o.orderExprs = map[string]string{}
+		}
+		o.orderExprs[field] = expr
+	}
+}
+
+// WithRowValueComparison makes keyset conditions compare row values, e.g. `("age","name") > (?,?)` instead of
+// `"age" > ? OR ("age" = ? AND "name" > ?)`, which lets the database (e.g. Postgres or MySQL) seek a composite index directly.
+// It falls back to the expansion if the directions of the order bys are mixed, or a column is nullable.
+func WithRowValueComparison[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.rowValues = true
+	}
+}
+
+// WithTimestampLocation is for a `timestamp without time zone` column of field, which stores the wall clocks of loc (e.g. time.UTC).
+// The values of field are converted to loc when encoded into cursors, and compared as wall clocks of loc without a time zone,
+// so the boundaries don't shift with the session time zone of the database.
+func WithTimestampLocation[T any](field string, loc *time.Location) Option[T] {
+	return func(o *options[T]) {
+		if o.timestampLocations == nil {
+			o.timestampLocations = map[string]*time.Location{}
+		}
+		o.timestampLocations[field] = loc
+		o.cursorOptions = append(o.cursorOptions, cursor.WithKeysetNormalizer(field, func(v any) (any, error) {
+			if isNull(v) {
+				return v, nil
+			}
+			t, err := toTime(v)
+			if err != nil {
+				return nil, err
+			}
+			return t.In(loc), nil
+		}))
+	}
+}
+
+// WithFloatPrecision orders and compares the float field rounded to places decimal places, i.e. `round(column, places)`,
+// in ORDER BY, the boundary comparisons and the equality branches alike, and the cursors encode the rounded values.
+// Floats differing only beyond the precision are then true ties, which are broken by the subsequent order bys, e.g. the primary key.
+// The cursors are rounded in Go, values exactly halfway (e.g. 0.125 to 2 places) may be rounded differently by the database.
+func WithFloatPrecision[T any](field string, places int) Option[T] {
+	return func(o *options[T]) {
+		if o.floatPrecisions == nil {
+			o.floatPrecisions = map[string]int{}
+		}
+		o.floatPrecisions[field] = places
+		o.cursorOptions = append(o.cursorOptions, cursor.WithKeysetNormalizer(field, func(v any) (any, error) {
+			if isNull(v) {
+				return v, nil
+			}
+			return roundFloat(v, places)
+		}))
+	}
+}
+
+// WithPostFilter drops the nodes for which filter returns false after they are fetched, e.g. permission checks
+// that can't be expressed in SQL. The keyset finder fetches more rows to fill the page, so that the page size,
+// HasNextPage/HasPreviousPage and cursors stay consistent. TotalCount is still counted without the filter,
+// unless the first page already contains all rows.
+// It is not supported by offset pagination.
+func WithPostFilter[T any](filter func(T) bool) Option[T] {
+	return func(o *options[T]) {
+		o.postFilter = filter
+	}
+}
+
+// WithDedupBy drops the nodes of a page whose key returned by keyFn is the same as a previous node of the page, keeping the first one,
+// e.g. parent rows duplicated by a join. Like WithPostFilter, the keyset finder fetches more rows to refill the page,
+// and TotalCount is counted with the duplicates, unless the first page already contains all rows.
+// Only duplicates within a page are dropped, so order by the columns of the key (e.g. the primary key) to keep them adjacent, then they don't span pages.
+// The keys must be comparable. It is not supported by offset pagination.
+func WithDedupBy[T any](keyFn func(T) any) Option[T] {
+	return func(o *options[T]) {
+		o.dedupBy = keyFn
+	}
+}
+
+// DefaultMaxRefills is the max number of refills of a page by default, see WithMaxRefills
+const DefaultMaxRefills = 10
+
+// WithMaxRefills bounds the number of extra fetches the keyset finder makes to refill a page with the nodes dropped by WithPostFilter or WithDedupBy,
+// DefaultMaxRefills by default. The finder fails with ErrTooManyRefills beyond it, instead of scanning the rest of the table, e.g. if the filter drops every row.
+func WithMaxRefills[T any](n int) Option[T] {
+	if n <= 0 {
+		panic("max refills must be greater than 0")
+	}
+	return func(o *options[T]) {
+		o.maxRefills = n
+	}
+}
+
+// WithDistinctOn deduplicates rows by columns with Postgres `DISTINCT ON` before paginating, e.g. a feed with the latest post per author.
+// Since DISTINCT ON requires its columns to lead the ORDER BY, the deduplication runs in a subquery ordered by columns and then the pagination order,
+// which keeps the first row of each key by the pagination order. Keyset conditions and the pagination order are applied to the subquery,
+// so cursors only encode the ordering columns. It is not supported by offset pagination.
+func WithDistinctOn[T any](columns ...string) Option[T] {
+	return func(o *options[T]) {
+		o.distinctOn = columns
+	}
+}
+
+// WithComputedField computes field with the SQL expression expr, e.g. `ts_rank(document, query)` or `price * (1 - discount)`,
+// so that it can be paginated by without recomputing the expression in SELECT, ORDER BY and keyset conditions.
+// The query is wrapped in a subquery which computes expr once per row as the column of field, referenced by the outer query.
+// field should be read-only and not migrated, e.g. `gorm:"->;-:migration"`.
+func WithComputedField[T any](field string, expr string) Option[T] {
+	return func(o *options[T]) {
+		if o.computedFields == nil {
+			o.computedFields = map[string]string{}
+		}
+		o.computedFields[field] = expr
+	}
+}
+
+// WithTimeBucket computes field as the start of the unit (e.g. "hour" or "day") the timestamp column falls in, by `date_trunc` of Postgres,
+// so that time-bucketed rows can be paginated by the bucket and a tiebreak, e.g. `Hour DESC, ID DESC`,
+// with cursors encoding the bucket boundary instead of the timestamp. It is computed as WithComputedField.
+func WithTimeBucket[T any](field string, column string, unit string) Option[T] {
+	if !slices.Contains(timeBucketUnits, unit) {
+		panic(fmt.Sprintf("unsupported time bucket unit %q", unit))
+	}
+	return func(o *options[T]) {
+		if o.timeBuckets == nil {
+			o.timeBuckets = map[string]timeBucket{}
+		}
+		o.timeBuckets[field] = timeBucket{column: column, unit: unit}
+	}
+}
+
+// WithUniqueOrderCheck makes the keyset adapter reject the order bys which don't cover the primary key or a unique index of NOT NULL columns
+// with ErrNondeterministicOrder, e.g. `Age` alone, whose ties would be skipped or repeated at the boundaries of pages.
+// It checks the order bys after WithPrimaryKeyTiebreak, which makes them deterministic.
+func WithUniqueOrderCheck[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.uniqueOrderCheck = true
+	}
+}
+
+// WithIndexHint hints the find queries to use the index of name, e.g. the composite index matching the keyset order,
+// which the MySQL optimizer may ignore and filesort instead. It's `USE INDEX (name)` on MySQL and a no-op on other databases.
+// The hint follows the table name, before the joins of the query if any.
+func WithIndexHint[T any](name string) Option[T] {
+	return func(o *options[T]) {
+		o.indexHint = name
+	}
+}
+
+// WithOnlyDeleted paginates and counts the soft deleted rows only, e.g. for a trash view,
+// by the field of gorm.DeletedAt, which must be in the model. Both the finder and the counter query them `Unscoped`.
+func WithOnlyDeleted[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.onlyDeleted = true
+	}
+}
+
+// WithPageEnricher calls enrich once per page with its nodes, e.g. to batch-load their associations by one query,
+// before the cursors are encoded, so that the cursors can depend on the enriched fields. db is a new session of the db of the adapter.
+// enrich may modify the nodes in place or replace the elements of nodes.
+func WithPageEnricher[T any](enrich func(ctx context.Context, db *gorm.DB, nodes []T) error) Option[T] {
+	return func(o *options[T]) {
+		o.pageEnricher = enrich
+	}
+}
+
+// WithCursorOptions passes opts to the cursor adapter, e.g. cursor.WithMaxKeysetKeys.
+func WithCursorOptions[T any](opts ...cursor.Option) Option[T] {
+	return func(o *options[T]) {
+		o.cursorOptions = append(o.cursorOptions, opts...)
+	}
+}
+
+// WithSessionConfig applies config (e.g. PrepareStmt, QueryFields or Logger) to the sessions of both the count and find executions.
+// The Context of config is ignored, the context of the request is used instead.
+func WithSessionConfig[T any](config *gorm.Session) Option[T] {
+	return func(o *options[T]) {
+		o.sessionConfig = config
+	}
+}
+
+// prepareDB binds ctx and the session config to db for an execution
+func prepareDB[T any](ctx context.Context, db *gorm.DB, o *options[T]) *gorm.DB {
+	if o.sessionConfig != nil {
+		config := *o.sessionConfig
+		config.Context = ctx
+		db = db.Session(&config)
+	} else {
+		// Always start a new session, db may be a chain (e.g. db.Where(...)) whose statement would be mutated otherwise
+		db = db.WithContext(ctx)
+	}
+	if o.onlyDeleted {
+		db = onlyDeleted[T](db)
+	}
+	return db
+}
+
+// WithTransientRetry retries the find and count executions up to maxAttempts in total if they fail with an error classified
+// as transient by classify, e.g. `connection reset` or `broken pipe`, with exponential backoff in between, until the context is done.
+// Other errors fail immediately. If classify is nil, connection resets, broken pipes and driver.ErrBadConn are transient.
+func WithTransientRetry[T any](maxAttempts int, classify func(error) bool) Option[T] {
+	if classify == nil {
+		classify = isConnectionError
+	}
+	return func(o *options[T]) {
+		o.transientRetry = &transientRetry{maxAttempts: maxAttempts, classify: classify}
+	}
+}
+
+// WithCountColumn makes the counter emit `COUNT(column)` instead of `COUNT(*)`,
+// which lets Postgres use an index-only scan on an index of column for filtered counts.
+// column must be NOT NULL (or the primary key), otherwise rows with NULL would not be counted.
+// It is ignored if the query selects columns itself (e.g. `SELECT DISTINCT ON ...`).
+func WithCountColumn[T any](column string) Option[T] {
+	return func(o *options[T]) {
+		o.countColumn = column
+	}
+}
+
+// WithWindowCount makes the counted adapters select the total count with the page by `COUNT(*) OVER ()`,
+// so that a page and its total take one round trip instead of two, see NewKeysetCounterWindowed and NewOffsetCounterWindowed.
+func WithWindowCount[T any]() Option[T] {
+	return func(o *options[T]) {
+		o.windowCount = true
+	}
+}
+
+// WithOffsetSnapshot pins `MAX(idColumn)` on the first request of offset pagination and encodes it into the cursors,
+// subsequent pages only see the rows with `idColumn <= snapshot`, so the rows inserted meanwhile don't shift the offsets.
+// It's for append-only tables with a monotonic idColumn.
+func WithOffsetSnapshot[T any](idColumn string) Option[T] {
+	return func(o *options[T]) {
+		o.offsetSnapshot = idColumn
+	}
+}
+
+// WithPrimaryKeyTiebreak makes keyset pagination append the primary key fields missing from the order bys, in the direction of the last order by,
+// e.g. ordered by `Status` only, where all the rows may share the same value and the exclusive comparison would skip all but the first page of them.
+// The order bys are only unique if they include the whole primary key, otherwise warn (optional) is called with the requested order bys,
+// e.g. to log the order bys which should have a tiebreak.
+func WithPrimaryKeyTiebreak[T any](warn func(ctx context.Context, orderBys []relay.OrderBy)) Option[T] {
+	return func(o *options[T]) {
+		o.primaryKeyTiebreak = &warn
+	}
+}
@@ -0,0 +1,46 @@
+package gormrelay
+
+import (
+	"context"
+	"database/sql/driver"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// transientRetryBackoff is the wait before the first retry, doubled for each subsequent one
+var transientRetryBackoff = 10 * time.Millisecond
+
+type transientRetry struct {
+	maxAttempts int
+	classify    func(error) bool
+}
+
+// isConnectionError is the default classifier of WithTransientRetry
+func isConnectionError(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.Is(err, driver.ErrBadConn)
+}
+
+// retryTransient calls fn until it succeeds, fails with an error not classified as transient,
+// or maxAttempts are made, waiting with exponential backoff in between unless ctx is done.
+func retryTransient[R any](ctx context.Context, r *transientRetry, fn func() (R, error)) (R, error) {
+	result, err := fn()
+	if r == nil {
+		return result, err
+	}
+	backoff := transientRetryBackoff
+	for attempt := 1; err != nil && attempt < r.maxAttempts && r.classify(err); attempt++ {
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			var zero R
+			return zero, errors.Wrapf(ctx.Err(), "retry after %v", err)
+		case <-timer.C:
+		}
+		backoff *= 2
+		result, err = fn()
+	}
+	return result, err
+}
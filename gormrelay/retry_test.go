@@ -0,0 +1,113 @@
+package gormrelay
+
+import (
+	"context"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type faultsKey struct{}
+
+// faults injects the errors of the queries in order, the queries after them succeed
+type faults struct {
+	errs    []error
+	queries atomic.Int32
+}
+
+func withFaults(ctx context.Context, errs ...error) (context.Context, *faults) {
+	f := &faults{errs: errs}
+	return context.WithValue(ctx, faultsKey{}, f), f
+}
+
+func registerFaults(t *testing.T) {
+	require.NoError(t, db.Callback().Query().Before("gorm:query").Register("test:faults", func(tx *gorm.DB) {
+		f, ok := tx.Statement.Context.Value(faultsKey{}).(*faults)
+		if !ok {
+			return
+		}
+		if n := int(f.queries.Add(1)); n <= len(f.errs) {
+			tx.AddError(f.errs[n-1])
+		}
+	}))
+	t.Cleanup(func() {
+		require.NoError(t, db.Callback().Query().Remove("test:faults"))
+	})
+}
+
+func TestTransientRetry(t *testing.T) {
+	resetDB(t)
+	registerFaults(t)
+
+	orderBys := []relay.OrderBy{{Field: "ID", Desc: false}}
+	req := &relay.PaginateRequest[*User]{First: lo.ToPtr(5)}
+	newPagination := func(f func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User], opts ...Option[*User]) relay.Pagination[*User] {
+		return relay.New(false, 10, 10, orderBys, f(db, opts...))
+	}
+
+	for name, f := range map[string]func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]{
+		"Keyset": NewKeysetAdapter[*User],
+		"Offset": NewOffsetAdapter[*User],
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newPagination(f, WithTransientRetry[*User](3, nil))
+
+			// the find and the count fail transiently once each
+			ctx, fs := withFaults(context.Background(), syscall.ECONNRESET, errors.Wrap(syscall.EPIPE, "write"))
+			resp, err := p.Paginate(ctx, req)
+			require.NoError(t, err)
+			require.Equal(t, lo.RangeFrom(1, 5), lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }))
+			require.Equal(t, 100, resp.PageInfo.TotalCount)
+			require.EqualValues(t, 4, fs.queries.Load())
+
+			// gives up after maxAttempts
+			ctx, fs = withFaults(context.Background(), syscall.ECONNRESET, syscall.ECONNRESET, syscall.ECONNRESET)
+			_, err = p.Paginate(ctx, req)
+			require.ErrorIs(t, err, syscall.ECONNRESET)
+			require.EqualValues(t, 3, fs.queries.Load())
+
+			// non-transient errors fail fast
+			ctx, fs = withFaults(context.Background(), errors.New("syntax error"))
+			_, err = p.Paginate(ctx, req)
+			require.ErrorContains(t, err, "syntax error")
+			require.EqualValues(t, 1, fs.queries.Load())
+
+			// not retried without the option
+			ctx, fs = withFaults(context.Background(), syscall.ECONNRESET)
+			_, err = newPagination(f).Paginate(ctx, req)
+			require.ErrorIs(t, err, syscall.ECONNRESET)
+			require.EqualValues(t, 1, fs.queries.Load())
+		})
+	}
+
+	t.Run("Classify", func(t *testing.T) {
+		errBusy := errors.New("server is busy")
+		p := newPagination(NewKeysetAdapter[*User], WithTransientRetry[*User](2, func(err error) bool {
+			return errors.Is(err, errBusy)
+		}))
+		ctx, _ := withFaults(context.Background(), errBusy)
+		_, err := p.Paginate(ctx, req)
+		require.NoError(t, err)
+
+		ctx, fs := withFaults(context.Background(), syscall.ECONNRESET)
+		_, err = p.Paginate(ctx, req)
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+		require.EqualValues(t, 1, fs.queries.Load())
+	})
+
+	t.Run("ContextDone", func(t *testing.T) {
+		p := newPagination(NewKeysetAdapter[*User], WithTransientRetry[*User](3, nil))
+		ctx, cancel := context.WithTimeout(context.Background(), transientRetryBackoff/2)
+		defer cancel()
+		ctx, fs := withFaults(ctx, syscall.ECONNRESET)
+		_, err := p.Paginate(ctx, req)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.EqualValues(t, 1, fs.queries.Load())
+	})
+}
@@ -0,0 +1,81 @@
+package gormrelay
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginateSession(t *testing.T) {
+	resetDB(t)
+
+	// the server only gets the token from the client, and builds the query by the filter of the session
+	paginate := func(token string) ([]int, string) {
+		s, err := relay.DecodeSession(token)
+		require.NoError(t, err)
+		minAge, err := strconv.Atoi(s.Filter)
+		require.NoError(t, err)
+
+		p := relay.New(false, 50, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db.Where("age > ?", minAge)))
+		resp, next, err := p.PaginateSession(context.Background(), token)
+		require.NoError(t, err)
+		require.Equal(t, 90, resp.PageInfo.TotalCount)
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID }), next
+	}
+
+	t.Run("Forward", func(t *testing.T) {
+		token, err := relay.EncodeSession(&relay.Session{
+			OrderBys: []relay.OrderBy{{Field: "Age"}, {Field: "ID"}},
+			Filter:   "10",
+			First:    lo.ToPtr(40),
+		})
+		require.NoError(t, err)
+
+		var pages [][]int
+		for token != "" {
+			var ids []int
+			ids, token = paginate(token)
+			pages = append(pages, ids)
+		}
+		require.Equal(t, [][]int{
+			lo.RangeWithSteps(90, 50, -1),
+			lo.RangeWithSteps(50, 10, -1),
+			lo.RangeWithSteps(10, 0, -1),
+		}, pages)
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		token, err := relay.EncodeSession(&relay.Session{
+			OrderBys: []relay.OrderBy{{Field: "ID"}},
+			Filter:   "10",
+			Last:     lo.ToPtr(40),
+		})
+		require.NoError(t, err)
+
+		var pages [][]int
+		for token != "" {
+			var ids []int
+			ids, token = paginate(token)
+			pages = append(pages, ids)
+		}
+		require.Equal(t, [][]int{
+			lo.RangeFrom(51, 40),
+			lo.RangeFrom(11, 40),
+			lo.RangeFrom(1, 10),
+		}, pages)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		p := relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID"}}, NewKeysetAdapter[*User](db))
+		for _, token := range []string{"!", "bm90IGpzb24"} {
+			resp, next, err := p.PaginateSession(context.Background(), token)
+			require.ErrorIs(t, err, relay.ErrInvalidCursor)
+			require.Nil(t, resp)
+			require.Empty(t, next)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package gormrelay
+
+import (
+	"sync"
+
+	relay "github.com/molon/gorelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"gorm.io/gorm/schema"
+)
+
+// ErrNondeterministicOrder is returned under WithUniqueOrderCheck if the order bys may tie on different rows
+var ErrNondeterministicOrder = errors.New("order by is not deterministic")
+
+// checkUniqueOrder checks that the fields of orderBys cover the primary key or a unique index of NOT NULL columns,
+// otherwise rows tying on all of them may be skipped or repeated at the boundaries of pages.
+func checkUniqueOrder(s *schema.Schema, orderBys []relay.OrderBy) error {
+	fields := lo.SliceToMap(orderBys, func(item relay.OrderBy) (string, bool) {
+		return item.Field, true
+	})
+	for _, key := range uniqueKeys(s) {
+		if lo.EveryBy(key, func(field *schema.Field) bool { return fields[field.Name] }) {
+			return nil
+		}
+	}
+	return relay.MarkError(errors.Wrapf(ErrNondeterministicOrder, "order by fields %v don't cover the primary key or a unique index of NOT NULL columns",
+		lo.Map(orderBys, func(item relay.OrderBy, _ int) string { return item.Field }),
+	), relay.ErrInvalidOrderBy)
+}
+
+var (
+	uniqueKeysCache sync.Map // *schema.Schema -> [][]*schema.Field
+	// ParseIndexes writes to the fields of the schema, so it's not called concurrently
+	uniqueKeysMu sync.Mutex
+)
+
+// uniqueKeys returns the fields of the primary key and the unique indexes of s, which can't contain NULLs, i.e. duplicates.
+// Partial unique indexes are excluded since they don't cover all rows.
+func uniqueKeys(s *schema.Schema) [][]*schema.Field {
+	if v, ok := uniqueKeysCache.Load(s); ok {
+		return v.([][]*schema.Field)
+	}
+	uniqueKeysMu.Lock()
+	defer uniqueKeysMu.Unlock()
+	if v, ok := uniqueKeysCache.Load(s); ok {
+		return v.([][]*schema.Field)
+	}
+
+	notNull := func(fields []*schema.Field) bool {
+		return lo.EveryBy(fields, func(field *schema.Field) bool { return field.PrimaryKey || field.NotNull })
+	}
+	var keys [][]*schema.Field
+	if len(s.PrimaryFields) > 0 {
+		keys = append(keys, s.PrimaryFields)
+	}
+	for _, field := range s.Fields {
+		if field.Unique && notNull([]*schema.Field{field}) {
+			keys = append(keys, []*schema.Field{field})
+		}
+	}
+	for _, index := range s.ParseIndexes() {
+		if index.Class != "UNIQUE" || index.Where != "" {
+			continue
+		}
+		fields := lo.Map(index.Fields, func(item schema.IndexOption, _ int) *schema.Field { return item.Field })
+		if notNull(fields) {
+			keys = append(keys, fields)
+		}
+	}
+	uniqueKeysCache.Store(s, keys)
+	return keys
+}
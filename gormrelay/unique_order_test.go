@@ -0,0 +1,88 @@
+package gormrelay
+
+import (
+	"context"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type account struct {
+	ID       int     `gorm:"primarykey"`
+	Email    string  `gorm:"unique;not null"`
+	Nickname *string `gorm:"unique"`
+	TenantID int     `gorm:"uniqueIndex:idx_tenant_code;not null"`
+	Code     string  `gorm:"uniqueIndex:idx_tenant_code;not null"`
+	Slug     string  `gorm:"uniqueIndex:idx_active_slug,where:deleted = false;not null"`
+	Age      int
+}
+
+func TestUniqueOrderCheck(t *testing.T) {
+	resetDB(t)
+
+	newPagination := func(opts ...Option[*User]) *relay.Paginator[*User] {
+		return relay.New(false, 10, 10, []relay.OrderBy{{Field: "ID", Desc: false}}, NewKeysetAdapter[*User](db, opts...))
+	}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	p := newPagination(WithUniqueOrderCheck[*User]())
+
+	// safe
+	resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(2),
+		OrderBys: []relay.OrderBy{{Field: "Age", Desc: false}, {Field: "ID", Desc: false}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{100, 99}, ids(resp))
+
+	// unsafe
+	resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(2),
+		OrderBys: []relay.OrderBy{{Field: "Age", Desc: false}},
+	})
+	require.Nil(t, resp)
+	require.ErrorIs(t, err, ErrNondeterministicOrder)
+	require.ErrorIs(t, err, relay.ErrInvalidOrderBy)
+	require.ErrorContains(t, err, "order by fields [Age] don't cover the primary key or a unique index of NOT NULL columns: order by is not deterministic")
+
+	// not checked by default
+	_, err = newPagination().Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(2),
+		OrderBys: []relay.OrderBy{{Field: "Age", Desc: false}},
+	})
+	require.NoError(t, err)
+
+	// made deterministic by the tiebreak
+	_, err = newPagination(WithUniqueOrderCheck[*User](), WithPrimaryKeyTiebreak[*User](nil)).Paginate(context.Background(), &relay.PaginateRequest[*User]{
+		First:    lo.ToPtr(2),
+		OrderBys: []relay.OrderBy{{Field: "Age", Desc: false}},
+	})
+	require.NoError(t, err)
+
+	s, err := parseSchema(db, &account{})
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		fields []string
+		unique bool
+	}{
+		{fields: []string{"Age", "ID"}, unique: true},
+		{fields: []string{"Email"}, unique: true},
+		{fields: []string{"Code", "Age", "TenantID"}, unique: true},
+		{fields: []string{"Code"}, unique: false},
+		// NULLs are not unique
+		{fields: []string{"Nickname"}, unique: false},
+		// partial indexes don't cover all rows
+		{fields: []string{"Slug"}, unique: false},
+	} {
+		err := checkUniqueOrder(s, lo.Map(tc.fields, func(field string, _ int) relay.OrderBy { return relay.OrderBy{Field: field} }))
+		if tc.unique {
+			require.NoError(t, err, tc.fields)
+		} else {
+			require.ErrorIs(t, err, ErrNondeterministicOrder, tc.fields)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package gormrelay
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+const windowTotalColumn = "__total"
+
+// selectWindowTotal wraps db as `SELECT table.*, COUNT(*) OVER () AS __total FROM (db) AS table`,
+// the window is computed before the LIMIT and OFFSET of the outer query, so every row of the page carries the total count.
+// If nested, it's wrapped once more as `SELECT * FROM (...) AS table`, so that the WHERE of the outer query (e.g. keyset conditions) doesn't narrow the total.
+func selectWindowTotal(db *gorm.DB, nested bool) (*gorm.DB, error) {
+	model := db.Statement.Model
+	if model == nil {
+		return nil, errors.New("model is nil")
+	}
+	if rv := reflect.ValueOf(model); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		// A non-nil model is required for the subquery
+		model = reflect.New(rv.Type().Elem()).Interface()
+	}
+
+	s, err := parseSchema(db, model)
+	if err != nil {
+		return nil, err
+	}
+
+	table := db.Statement.Quote(s.Table)
+	db = db.Session(&gorm.Session{NewDB: true}).Model(model).
+		Table("(?) AS "+table, db.Session(&gorm.Session{}).Model(model)).
+		Select(table + ".*, COUNT(*) OVER () AS " + db.Statement.Quote(windowTotalColumn))
+	if nested {
+		db = db.Session(&gorm.Session{NewDB: true}).Model(model).Table("(?) AS "+table, db)
+	}
+	return db, nil
+}
+
+// findWithWindowTotal finds into dest as Find does, except that the column of selectWindowTotal is scanned into total
+func findWithWindowTotal(db *gorm.DB, dest any, total *int) error {
+	rows, err := db.Rows()
+	if err != nil {
+		return errors.Wrap(err, "find")
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return errors.Wrap(err, "find")
+	}
+	index := slices.Index(columns, windowTotalColumn)
+	if index < 0 {
+		return errors.Errorf("missing column %q", windowTotalColumn)
+	}
+
+	db.Statement.Dest = dest
+	db.Statement.ReflectValue = reflect.ValueOf(dest).Elem()
+	gorm.Scan(&windowTotalRows{Rows: rows, index: index, total: total}, db, 0)
+	// Find runs them after scanning as well
+	callbacks.Preload(db)
+	callbacks.AfterQuery(db)
+	if db.Error != nil {
+		return errors.Wrap(db.Error, "find")
+	}
+	return errors.Wrap(rows.Err(), "find")
+}
+
+// windowTotalRows scans the column of the window total into total, which gorm would discard as an unknown column
+type windowTotalRows struct {
+	gorm.Rows
+	index int
+	total *int
+}
+
+func (r *windowTotalRows) Scan(dest ...any) error {
+	dest = slices.Clone(dest)
+	dest[r.index] = r.total
+	return r.Rows.Scan(dest...)
+}
@@ -0,0 +1,110 @@
+package gormrelay
+
+import (
+	"context"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestWindowCount(t *testing.T) {
+	resetDB(t)
+
+	orderBys := []relay.OrderBy{{Field: "Age", Desc: true}, {Field: "ID"}}
+	ids := func(resp *relay.PaginateResponse[*User]) []int {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) int { return edge.Node.ID })
+	}
+
+	for _, tc := range []struct {
+		name       string
+		newAdapter func(db *gorm.DB, opts ...Option[*User]) relay.ApplyCursorsFunc[*User]
+		cursor     func(id int) string
+	}{
+		{
+			name:       "Keyset",
+			newAdapter: NewKeysetAdapter[*User],
+			cursor: func(id int) string {
+				return mustEncodeKeysetCursor(&User{ID: id, Age: 101 - id}, []string{"Age", "ID"})
+			},
+		},
+		{
+			name:       "Offset",
+			newAdapter: NewOffsetAdapter[*User],
+			cursor: func(id int) string {
+				// ordered by age desc, so id 1 is the first row
+				return cursor.EncodeOffsetCursor(id - 1)
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			filtered := db.Where("age > ?", 20)
+			plain := relay.New(false, 10, 10, orderBys, tc.newAdapter(filtered))
+			recorder := newSQLRecorder()
+			windowed := relay.New(false, 10, 10, orderBys, tc.newAdapter(filtered.Session(&gorm.Session{Logger: recorder}), WithWindowCount[*User]()))
+
+			for _, r := range []struct {
+				name    string
+				req     *relay.PaginateRequest[*User]
+				ids     []int
+				queries int
+				// the count is needed to locate the page
+				countFirst bool
+			}{
+				{
+					name:    "FirstPage",
+					req:     &relay.PaginateRequest[*User]{First: lo.ToPtr(3)},
+					ids:     []int{1, 2, 3},
+					queries: 1,
+				},
+				{
+					name:    "MiddlePage",
+					req:     &relay.PaginateRequest[*User]{After: lo.ToPtr(tc.cursor(40)), First: lo.ToPtr(3)},
+					ids:     []int{41, 42, 43},
+					queries: 1,
+				},
+				{
+					name:    "MiddlePageBackward",
+					req:     &relay.PaginateRequest[*User]{Before: lo.ToPtr(tc.cursor(40)), Last: lo.ToPtr(3)},
+					ids:     []int{37, 38, 39},
+					queries: 1,
+				},
+				{
+					name:       "LastPage",
+					req:        &relay.PaginateRequest[*User]{Last: lo.ToPtr(3)},
+					ids:        []int{78, 79, 80},
+					queries:    lo.Ternary(tc.name == "Keyset", 1, 2),
+					countFirst: tc.name == "Offset",
+				},
+				{
+					name:    "BeyondEnd",
+					req:     &relay.PaginateRequest[*User]{After: lo.ToPtr(tc.cursor(80)), First: lo.ToPtr(3)},
+					ids:     []int{},
+					queries: 2,
+				},
+			} {
+				t.Run(r.name, func(t *testing.T) {
+					expected, err := plain.Paginate(context.Background(), r.req)
+					require.NoError(t, err)
+					require.Equal(t, r.ids, ids(expected))
+					require.Equal(t, 80, expected.PageInfo.TotalCount)
+
+					before := len(recorder.SQLs())
+					resp, err := windowed.Paginate(context.Background(), r.req)
+					require.NoError(t, err)
+					require.Equal(t, expected, resp)
+					sqls := recorder.SQLs()[before:]
+					require.Len(t, sqls, r.queries)
+					if r.countFirst {
+						require.NotContains(t, sqls[1], "OVER")
+					} else {
+						require.Contains(t, sqls[0], "COUNT(*) OVER ()")
+					}
+				})
+			}
+		})
+	}
+}
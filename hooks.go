@@ -0,0 +1,56 @@
+package relay
+
+import (
+	"context"
+	"time"
+)
+
+// Stage is where the error reported to Hooks.OnError happened
+type Stage string
+
+const (
+	StageDecodeCursor Stage = "decode_cursor"
+	StageCount        Stage = "count"
+	StageFind         Stage = "find"
+)
+
+// Hooks are called by the adapters around their queries, e.g. for tracing and metrics, see WithHooks.
+// Each of them is optional and must not modify the pagination.
+type Hooks struct {
+	// OnCount is called after counting, not if the count is known without a query, e.g. from a short first page
+	OnCount func(ctx context.Context, dur time.Duration, count int)
+	// OnFind is called after fetching the n nodes of a page
+	OnFind func(ctx context.Context, dur time.Duration, n int)
+	// OnError is called with the error of a stage before it's returned
+	OnError func(ctx context.Context, stage Stage, err error)
+	// OnCursorReset is called with the error of the invalid cursors the pagination proceeded without, see WithResetOnInvalidCursor
+	OnCursorReset func(ctx context.Context, err error)
+}
+
+// ReportCount calls OnCount if set, h may be nil
+func (h *Hooks) ReportCount(ctx context.Context, dur time.Duration, count int) {
+	if h != nil && h.OnCount != nil {
+		h.OnCount(ctx, dur, count)
+	}
+}
+
+// ReportFind calls OnFind if set, h may be nil
+func (h *Hooks) ReportFind(ctx context.Context, dur time.Duration, n int) {
+	if h != nil && h.OnFind != nil {
+		h.OnFind(ctx, dur, n)
+	}
+}
+
+// ReportError calls OnError if set, h may be nil
+func (h *Hooks) ReportError(ctx context.Context, stage Stage, err error) {
+	if h != nil && h.OnError != nil {
+		h.OnError(ctx, stage, err)
+	}
+}
+
+// ReportCursorReset calls OnCursorReset if set, h may be nil
+func (h *Hooks) ReportCursorReset(ctx context.Context, err error) {
+	if h != nil && h.OnCursorReset != nil {
+		h.OnCursorReset(ctx, err)
+	}
+}
@@ -0,0 +1,130 @@
+package relay
+
+type options struct {
+	compactPageInfo  bool
+	emptyPageCursors bool
+	requireLimit     bool
+	strict           bool
+	emptyOrderBys    []OrderBy
+	negotiateLimit   func(requested int) int
+	responseMeta     bool
+	forwardLimits    *limits
+	backwardLimits   *limits
+	hooks            *Hooks
+	// resetOnInvalidCursor proceeds without the invalid cursors instead of failing
+	resetOnInvalidCursor bool
+	sortValueBounds      bool
+}
+
+type limits struct {
+	maxLimit      int
+	limitIfNotSet int
+}
+
+type Option func(*options)
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithCompactPageInfo omits StartCursor/EndCursor from PageInfo.
+// Useful for clients that track their own position (e.g. infinite scroll),
+// with nodesOnly it also skips cursor encoding entirely.
+func WithCompactPageInfo() Option {
+	return func(o *options) {
+		o.compactPageInfo = true
+	}
+}
+
+// WithEmptyPageCursors carries the request's boundary cursor into StartCursor/EndCursor when the page is empty
+// (e.g. `first: 0` with `after`), so that clients can still construct the subsequent request.
+func WithEmptyPageCursors() Option {
+	return func(o *options) {
+		o.emptyPageCursors = true
+	}
+}
+
+// WithRequireExplicitLimit makes requests without first and last fail with ErrLimitRequired
+// instead of applying limitIfNotSet, which is then not required to be set.
+func WithRequireExplicitLimit() Option {
+	return func(o *options) {
+		o.requireLimit = true
+	}
+}
+
+// WithEmptyOrderBys makes an explicitly empty (non-nil) OrderBys of the request use orderBys, e.g. the primary key only.
+// Without it such a request is rejected, while a nil OrderBys always uses orderBysIfNotSet.
+func WithEmptyOrderBys(orderBys ...OrderBy) Option {
+	return func(o *options) {
+		o.emptyOrderBys = orderBys
+	}
+}
+
+// WithStrictInvariants checks the edges returned by applyCursorsFunc after fetching,
+// and fails with ErrInvariantViolated if there are more than requested, i.e. more than first/last (and so maxLimit) plus the one to detect more pages,
+// which means the finder misbehaves, e.g. a custom finder ignoring the limit.
+func WithStrictInvariants() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// WithLimitNegotiator lets the server choose the page size, e.g. smaller under high load.
+// negotiate is called with the validated first/last (or limitIfNotSet), the negotiated limit is bounded to [0, maxLimit]
+// and used to fetch the page instead, which is reported by PaginateResponse.AppliedLimit.
+func WithLimitNegotiator(negotiate func(requested int) int) Option {
+	return func(o *options) {
+		o.negotiateLimit = negotiate
+	}
+}
+
+// WithResponseMeta populates PaginateResponse.Meta with the effective order bys, limit and a summary of the request,
+// so that clients and logs have the full context of a page in one object.
+func WithResponseMeta() Option {
+	return func(o *options) {
+		o.responseMeta = true
+	}
+}
+
+// WithForwardLimits overrides maxLimit and limitIfNotSet of New for forward paging,
+// i.e. requests with first, or without first and last unless only before is set.
+func WithForwardLimits(maxLimit, limitIfNotSet int) Option {
+	return func(o *options) {
+		o.forwardLimits = &limits{maxLimit: maxLimit, limitIfNotSet: limitIfNotSet}
+	}
+}
+
+// WithBackwardLimits overrides maxLimit and limitIfNotSet of New for backward paging,
+// i.e. requests with last, or with only before but neither first nor last.
+func WithBackwardLimits(maxLimit, limitIfNotSet int) Option {
+	return func(o *options) {
+		o.backwardLimits = &limits{maxLimit: maxLimit, limitIfNotSet: limitIfNotSet}
+	}
+}
+
+// WithHooks passes hooks to the adapters by ApplyCursorsRequest.Hooks, those of the cursor package report their counts, finds and errors to it.
+func WithHooks(hooks Hooks) Option {
+	return func(o *options) {
+		o.hooks = &hooks
+	}
+}
+
+// WithResetOnInvalidCursor makes an invalid after or before cursor (see ErrInvalidCursor), e.g. a corrupt or expired one,
+// reset the pagination as if it was not set, e.g. to the first page for an invalid after, instead of failing.
+// The reset is reported to Hooks.OnCursorReset with the error of the cursor.
+func WithResetOnInvalidCursor() Option {
+	return func(o *options) {
+		o.resetOnInvalidCursor = true
+	}
+}
+
+// WithSortValueBounds fills PageInfo.FirstSortValue and PageInfo.LastSortValue, e.g. to display the range of a page
+func WithSortValueBounds() Option {
+	return func(o *options) {
+		o.sortValueBounds = true
+	}
+}
@@ -2,35 +2,66 @@ package relay
 
 import (
 	"context"
+	"regexp"
+	"slices"
 
 	"github.com/pkg/errors"
 	"github.com/samber/lo"
 )
 
+// Nulls is the position of NULLs in an order by
+type Nulls string
+
+const (
+	// NullsDefault leaves the position of NULLs to the database, e.g. the largest on Postgres and the smallest on MySQL
+	NullsDefault Nulls = ""
+	NullsFirst   Nulls = "FIRST"
+	NullsLast    Nulls = "LAST"
+)
+
 type OrderBy struct {
 	Field string `json:"field"`
 	Desc  bool   `json:"desc"`
+	Nulls Nulls  `json:"nulls,omitempty"`
+	// Collate is the collation to compare and order the field by, e.g. "C", which is supported by gormrelay
+	Collate string `json:"collate,omitempty"`
 }
 
+// collationRegexp matches the collation names which are safe to be quoted in SQL, e.g. "C" or "en_US.utf8"
+var collationRegexp = regexp.MustCompile(`^[A-Za-z0-9_.@-]+$`)
+
 type PaginateRequest[T any] struct {
 	After    *string   `json:"after"`
 	First    *int      `json:"first"`
 	Before   *string   `json:"before"`
 	Last     *int      `json:"last"`
 	OrderBys []OrderBy `json:"orderBys"`
+	// SkipTotalCount skips the count query, e.g. for infinite scroll which only needs HasNextPage,
+	// TotalCount is left zero and reported as skipped, unless counting is required to locate the last page in offset mode.
+	SkipTotalCount bool `json:"skipTotalCount,omitempty"`
 }
 
 type Edge[T any] struct {
 	Node   T      `json:"node"`
 	Cursor string `json:"cursor"`
+	// Index is the absolute 1-based position of the node, only available in offset mode
+	Index *int `json:"index,omitempty"`
 }
 
 type PageInfo struct {
-	TotalCount      int     `json:"totalCount,omitempty"`
-	HasNextPage     bool    `json:"hasNextPage"`
-	HasPreviousPage bool    `json:"hasPreviousPage"`
-	StartCursor     *string `json:"startCursor"`
-	EndCursor       *string `json:"endCursor"`
+	TotalCount int `json:"totalCount,omitempty"`
+	// TotalCount is an estimate, e.g. by gormrelay.WithMaxPKCount
+	TotalCountApproximate bool `json:"totalCountApproximate,omitempty"`
+	// TotalCount is deliberately skipped (e.g. under high load) rather than unavailable, clients may retry later
+	TotalCountSkipped bool    `json:"totalCountSkipped,omitempty"`
+	HasNextPage       bool    `json:"hasNextPage"`
+	HasPreviousPage   bool    `json:"hasPreviousPage"`
+	StartCursor       *string `json:"startCursor"`
+	EndCursor         *string `json:"endCursor"`
+	// FirstSortValue and LastSortValue are the values of the leading order by field of the first and last edges,
+	// only available under WithSortValueBounds with an adapter providing LazyEdge.SortValue, e.g. the keyset one
+	FirstSortValue any `json:"firstSortValue,omitempty"`
+	LastSortValue  any `json:"lastSortValue,omitempty"`
 }
 
 type PaginateResponse[T any] struct {
@@ -38,8 +69,53 @@ type PaginateResponse[T any] struct {
 	// Sometimes we need nodes only
 	Nodes    []T      `json:"nodes,omitempty"`
 	PageInfo PageInfo `json:"pageInfo"`
+	// AppliedLimit is the effective first or last after defaulting, a page with fewer edges is the end of data
+	AppliedLimit int `json:"appliedLimit"`
+	// TotalPages is `ceil(TotalCount / AppliedLimit)`, only available in offset mode with a counter
+	TotalPages *int `json:"totalPages,omitempty"`
+	// Meta echoes the effective request, only available under WithResponseMeta
+	Meta *ResponseMeta `json:"meta,omitempty"`
 }
 
+// ResponseMeta is the effective request of a page after defaulting and negotiating, e.g. for self-documenting APIs and logs
+type ResponseMeta struct {
+	// AppliedOrderBys are the order bys of the request, or else the default ones, as the adapter applied them,
+	// e.g. with the primary key tiebreak appended
+	AppliedOrderBys []OrderBy `json:"appliedOrderBys"`
+	// OrderBysDefaulted is true if AppliedOrderBys are not of the request, i.e. defaulted or completed by the adapter
+	OrderBysDefaulted bool `json:"orderBysDefaulted,omitempty"`
+	// RequestedLimit is the first or last of the request, nil if neither is set
+	RequestedLimit *int `json:"requestedLimit,omitempty"`
+	// AppliedLimit is the same as PaginateResponse.AppliedLimit, less than RequestedLimit if clamped by WithLimitNegotiator
+	AppliedLimit int `json:"appliedLimit"`
+	// Backward is true if the page is located by last, i.e. from the end or before
+	Backward       bool `json:"backward,omitempty"`
+	HasAfter       bool `json:"hasAfter,omitempty"`
+	HasBefore      bool `json:"hasBefore,omitempty"`
+	SkipTotalCount bool `json:"skipTotalCount,omitempty"`
+}
+
+// ErrLimitRequired is returned if neither first nor last is set under WithRequireExplicitLimit
+var ErrLimitRequired = errors.New("first or last must be set")
+
+// ErrInvariantViolated is returned under WithStrictInvariants if applyCursorsFunc returns more edges than requested
+var ErrInvariantViolated = errors.New("invariant violated")
+
+// The errors below are matched by errors.Is for the failures caused by the request, e.g. to respond 400 instead of 500,
+// the returned errors keep their own messages.
+var (
+	// ErrInvalidCursor is for the cursors which are malformed, tampered, expired or inconsistent with each other
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrFirstLastTogether is for first and last set together
+	ErrFirstLastTogether = errors.New("first and last cannot be used together")
+	// ErrInvalidLimit is for a negative first or last
+	ErrInvalidLimit = errors.New("invalid limit")
+	// ErrLimitExceeded is for first or last greater than the max limit
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrInvalidOrderBy is for empty, duplicated or invalid order bys
+	ErrInvalidOrderBy = errors.New("invalid order by")
+)
+
 type Pagination[T any] interface {
 	Paginate(ctx context.Context, req *PaginateRequest[T]) (*PaginateResponse[T], error)
 }
@@ -50,12 +126,29 @@ func (f PaginationFunc[T]) Paginate(ctx context.Context, req *PaginateRequest[T]
 	return f(ctx, req)
 }
 
-func New[T any](nodesOnly bool, maxLimit int, limitIfNotSet int, orderBysIfNotSet []OrderBy, applyCursorsFunc ApplyCursorsFunc[T]) Pagination[T] {
-	if limitIfNotSet <= 0 {
-		panic("limitIfNotSet must be greater than 0")
+// Paginator is the Pagination created by New
+type Paginator[T any] struct {
+	paginate PaginationFunc[T]
+	// the max limits of first and last, the batch sizes of EachBatch
+	forwardMaxLimit, backwardMaxLimit int
+}
+
+func (p *Paginator[T]) Paginate(ctx context.Context, req *PaginateRequest[T]) (*PaginateResponse[T], error) {
+	return p.paginate(ctx, req)
+}
+
+func New[T any](nodesOnly bool, maxLimit int, limitIfNotSet int, orderBysIfNotSet []OrderBy, applyCursorsFunc ApplyCursorsFunc[T], opts ...Option) *Paginator[T] {
+	o := newOptions(opts)
+	shared := limits{maxLimit: maxLimit, limitIfNotSet: limitIfNotSet}
+	shared.check(o.requireLimit, "")
+	forward, backward := shared, shared
+	if o.forwardLimits != nil {
+		forward = *o.forwardLimits
+		forward.check(o.requireLimit, "forward ")
 	}
-	if maxLimit < limitIfNotSet {
-		panic("maxLimit must be greater than or equal to limitIfNotSet")
+	if o.backwardLimits != nil {
+		backward = *o.backwardLimits
+		backward.check(o.requireLimit, "backward ")
 	}
 	if applyCursorsFunc == nil {
 		panic("applyCursorsFunc must be set")
@@ -63,62 +156,161 @@ func New[T any](nodesOnly bool, maxLimit int, limitIfNotSet int, orderBysIfNotSe
 	if len(orderBysIfNotSet) == 0 {
 		panic("orderBysIfNotSet must be set")
 	}
-	return PaginationFunc[T](func(ctx context.Context, req *PaginateRequest[T]) (*PaginateResponse[T], error) {
+	return &Paginator[T]{forwardMaxLimit: forward.maxLimit, backwardMaxLimit: backward.maxLimit, paginate: func(ctx context.Context, req *PaginateRequest[T]) (*PaginateResponse[T], error) {
 		first, last := req.First, req.Last
 		if first == nil && last == nil {
+			if o.requireLimit {
+				return nil, errors.WithStack(ErrLimitRequired)
+			}
 			if req.After == nil && req.Before != nil {
-				last = &limitIfNotSet
+				last = &backward.limitIfNotSet
 			} else {
-				first = &limitIfNotSet
+				first = &forward.limitIfNotSet
 			}
 		}
-		if first != nil && *first > maxLimit {
-			return nil, errors.New("first must be less than or equal to max limit")
+		if first != nil && *first > forward.maxLimit {
+			return nil, MarkError(errors.New("first must be less than or equal to max limit"), ErrLimitExceeded)
+		}
+		if last != nil && *last > backward.maxLimit {
+			return nil, MarkError(errors.New("last must be less than or equal to max limit"), ErrLimitExceeded)
 		}
-		if last != nil && *last > maxLimit {
-			return nil, errors.New("last must be less than or equal to max limit")
+		if o.negotiateLimit != nil {
+			first, last = negotiateLimit(first, forward.maxLimit, o.negotiateLimit), negotiateLimit(last, backward.maxLimit, o.negotiateLimit)
 		}
 
 		orderBys := req.OrderBys
-		if len(orderBys) == 0 {
+		if orderBys == nil {
 			orderBys = orderBysIfNotSet
+		} else if len(orderBys) == 0 {
+			if len(o.emptyOrderBys) == 0 {
+				return nil, MarkError(errors.New("orderBys must not be empty, leave it nil to use the default"), ErrInvalidOrderBy)
+			}
+			orderBys = o.emptyOrderBys
+		}
+
+		for _, orderBy := range orderBys {
+			if orderBy.Nulls != NullsDefault && orderBy.Nulls != NullsFirst && orderBy.Nulls != NullsLast {
+				return nil, MarkError(errors.Errorf("invalid nulls %q of order by field %q", orderBy.Nulls, orderBy.Field), ErrInvalidOrderBy)
+			}
+			if orderBy.Collate != "" && !collationRegexp.MatchString(orderBy.Collate) {
+				return nil, MarkError(errors.Errorf("invalid collate %q of order by field %q", orderBy.Collate, orderBy.Field), ErrInvalidOrderBy)
+			}
 		}
 
 		dups := lo.FindDuplicatesBy(orderBys, func(item OrderBy) string {
 			return item.Field
 		})
 		if (len(dups)) > 0 {
-			return nil, errors.Errorf("duplicated order by fields %v", lo.Map(dups, func(item OrderBy, _ int) string {
+			return nil, MarkError(errors.Errorf("duplicated order by fields %v", lo.Map(dups, func(item OrderBy, _ int) string {
 				return item.Field
-			}))
+			})), ErrInvalidOrderBy)
 		}
 
-		edges, nodes, pageInfo, err := EdgesToReturn(ctx, req.Before, req.After, first, last, orderBys, nodesOnly, applyCursorsFunc)
+		after, before := req.After, req.Before
+		edges, nodes, pageInfo, applied, err := edgesToReturn(ctx, before, after, first, last, orderBys, req.SkipTotalCount, nodesOnly, applyCursorsFunc, o)
+		if err != nil && o.resetOnInvalidCursor && errors.Is(err, ErrInvalidCursor) {
+			// Proceed without the after cursor, the before cursor, or both, whichever is the first to be valid
+			invalidErr := err
+			for _, cursors := range [][2]*string{{nil, req.Before}, {req.After, nil}, {nil, nil}} {
+				if cursors[0] == req.After && cursors[1] == req.Before {
+					continue
+				}
+				after, before = cursors[0], cursors[1]
+				edges, nodes, pageInfo, applied, err = edgesToReturn(ctx, before, after, first, last, orderBys, req.SkipTotalCount, nodesOnly, applyCursorsFunc, o)
+				if err == nil || !errors.Is(err, ErrInvalidCursor) {
+					break
+				}
+			}
+			if err == nil {
+				o.hooks.ReportCursorReset(ctx, invalidErr)
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
-		return &PaginateResponse[T]{Edges: edges, Nodes: nodes, PageInfo: *pageInfo}, nil
-	})
+		appliedLimit := lo.FromPtr(first)
+		if last != nil {
+			appliedLimit = *last
+		}
+		resp := &PaginateResponse[T]{Edges: edges, Nodes: nodes, PageInfo: *pageInfo, AppliedLimit: appliedLimit}
+		if applied.Paged && appliedLimit > 0 {
+			resp.TotalPages = lo.ToPtr((pageInfo.TotalCount + appliedLimit - 1) / appliedLimit)
+		}
+		if o.responseMeta {
+			if applied.OrderBys != nil {
+				orderBys = applied.OrderBys
+			}
+			resp.Meta = &ResponseMeta{
+				AppliedOrderBys:   slices.Clone(orderBys), // not to share the default ones
+				OrderBysDefaulted: len(req.OrderBys) == 0 || applied.OrderBys != nil,
+				RequestedLimit:    lo.CoalesceOrEmpty(req.First, req.Last),
+				AppliedLimit:      appliedLimit,
+				Backward:          last != nil,
+				HasAfter:          after != nil,
+				HasBefore:         before != nil,
+				SkipTotalCount:    req.SkipTotalCount,
+			}
+		}
+		return resp, nil
+	}}
+}
+
+// check panics if the limits are invalid, the limitIfNotSet is not required if requireLimit
+func (l limits) check(requireLimit bool, direction string) {
+	if requireLimit {
+		if l.maxLimit <= 0 {
+			panic(direction + "maxLimit must be greater than 0")
+		}
+		return
+	}
+	if l.limitIfNotSet <= 0 {
+		panic(direction + "limitIfNotSet must be greater than 0")
+	}
+	if l.maxLimit < l.limitIfNotSet {
+		panic(direction + "maxLimit must be greater than or equal to limitIfNotSet")
+	}
+}
+
+// negotiateLimit returns the negotiated limit bounded to [0, maxLimit], a nil or negative limit is left to be validated
+func negotiateLimit(limit *int, maxLimit int, negotiate func(requested int) int) *int {
+	if limit == nil || *limit < 0 {
+		return limit
+	}
+	negotiated := min(max(negotiate(*limit), 0), maxLimit)
+	return &negotiated
 }
 
 type ApplyCursorsRequest struct {
 	Before   *string
 	After    *string
 	OrderBys []OrderBy
+	// Limit is one more than first or last, the extra edge tells whether there is a next or previous page without counting
 	Limit    int
 	FromLast bool
+	// SkipTotalCount asks the adapter not to count, see PaginateRequest.SkipTotalCount
+	SkipTotalCount bool
+	// Hooks are those of WithHooks, nil if not set
+	Hooks *Hooks
 }
 
 type LazyEdge[T any] struct {
 	Node   T
 	Cursor func(ctx context.Context, node T) (string, error)
+	Index  *int // optional, the absolute 1-based position of the node
+	// SortValue is optional, it returns the value of the leading order by field of the node for WithSortValueBounds
+	SortValue func(ctx context.Context, node T) (any, error)
 }
 
 type ApplyCursorsResponse[T any] struct {
-	Edges              []LazyEdge[T]
-	TotalCount         int
-	HasBeforeOrNext    bool // `before` exists or it's next exists
-	HasAfterOrPrevious bool // `after` exists or it's previous exists
+	Edges                 []LazyEdge[T]
+	TotalCount            int
+	TotalCountApproximate bool
+	TotalCountSkipped     bool
+	HasBeforeOrNext       bool // `before` exists or it's next exists
+	HasAfterOrPrevious    bool // `after` exists or it's previous exists
+	Paged                 bool // offset based with a counted TotalCount, so the total pages are known
+	// OrderBys are the order bys applied if the adapter changed those of the request, e.g. appended a tiebreak, nil if not
+	OrderBys []OrderBy
 }
 
 // https://relay.dev/graphql/connections.htm#ApplyCursorsToEdges()
@@ -132,15 +324,29 @@ func EdgesToReturn[T any](
 	orderBys []OrderBy,
 	nodesOnly bool,
 	applyCursorsFunc ApplyCursorsFunc[T],
+	opts ...Option,
 ) (edges []Edge[T], nodes []T, pageInfo *PageInfo, err error) {
+	edges, nodes, pageInfo, _, err = edgesToReturn(ctx, before, after, first, last, orderBys, false, nodesOnly, applyCursorsFunc, newOptions(opts))
+	return edges, nodes, pageInfo, err
+}
+
+func edgesToReturn[T any](
+	ctx context.Context,
+	before, after *string, first, last *int,
+	orderBys []OrderBy,
+	skipTotalCount bool,
+	nodesOnly bool,
+	applyCursorsFunc ApplyCursorsFunc[T],
+	o *options,
+) (edges []Edge[T], nodes []T, pageInfo *PageInfo, applied *ApplyCursorsResponse[T], err error) {
 	if first != nil && last != nil {
-		return nil, nil, nil, errors.New("first and last cannot be used together")
+		return nil, nil, nil, nil, errors.WithStack(ErrFirstLastTogether)
 	}
 	if first != nil && *first < 0 {
-		return nil, nil, nil, errors.New("first must be a non-negative integer")
+		return nil, nil, nil, nil, MarkError(errors.New("first must be a non-negative integer"), ErrInvalidLimit)
 	}
 	if last != nil && *last < 0 {
-		return nil, nil, nil, errors.New("last must be a non-negative integer")
+		return nil, nil, nil, nil, MarkError(errors.New("last must be a non-negative integer"), ErrInvalidLimit)
 	}
 
 	var limit int
@@ -151,14 +357,19 @@ func EdgesToReturn[T any](
 	}
 
 	result, err := applyCursorsFunc(ctx, &ApplyCursorsRequest{
-		Before:   before,
-		After:    after,
-		OrderBys: orderBys,
-		Limit:    limit,
-		FromLast: last != nil,
+		Before:         before,
+		After:          after,
+		OrderBys:       orderBys,
+		Limit:          limit,
+		FromLast:       last != nil,
+		SkipTotalCount: skipTotalCount,
+		Hooks:          o.hooks,
 	})
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+	if o.strict && len(result.Edges) > limit {
+		return nil, nil, nil, nil, errors.Wrapf(ErrInvariantViolated, "%d edges returned for limit %d", len(result.Edges), limit)
 	}
 
 	lazyEdges := result.Edges
@@ -183,36 +394,64 @@ func EdgesToReturn[T any](
 
 	edges = make([]Edge[T], len(lazyEdges))
 	for i, lazyEdge := range lazyEdges {
-		if !nodesOnly || i == 0 || i == len(lazyEdges)-1 {
+		if !nodesOnly || (!o.compactPageInfo && (i == 0 || i == len(lazyEdges)-1)) {
 			cursor, err := lazyEdge.Cursor(ctx, lazyEdge.Node)
 			if err != nil {
-				return nil, nil, nil, err
+				return nil, nil, nil, nil, err
 			}
-			edges[i] = Edge[T]{Node: lazyEdge.Node, Cursor: cursor}
+			edges[i] = Edge[T]{Node: lazyEdge.Node, Cursor: cursor, Index: lazyEdge.Index}
 		} else {
-			edges[i] = Edge[T]{Node: lazyEdge.Node, Cursor: ""}
+			edges[i] = Edge[T]{Node: lazyEdge.Node, Cursor: "", Index: lazyEdge.Index}
 		}
 	}
 
 	pageInfo = &PageInfo{
-		TotalCount:      result.TotalCount,
-		HasNextPage:     hasNextPage,
-		HasPreviousPage: hasPreviousPage,
+		TotalCount:            result.TotalCount,
+		TotalCountApproximate: result.TotalCountApproximate,
+		TotalCountSkipped:     result.TotalCountSkipped,
+		HasNextPage:           hasNextPage,
+		HasPreviousPage:       hasPreviousPage,
 	}
-	if len(edges) > 0 {
+	if len(edges) > 0 && !o.compactPageInfo {
 		startCursor := edges[0].Cursor
 		pageInfo.StartCursor = &startCursor
 		endCursor := edges[len(edges)-1].Cursor
 		pageInfo.EndCursor = &endCursor
 	}
+	if len(lazyEdges) > 0 && o.sortValueBounds {
+		first, last := lazyEdges[0], lazyEdges[len(lazyEdges)-1]
+		if first.SortValue != nil && last.SortValue != nil {
+			if pageInfo.FirstSortValue, err = first.SortValue(ctx, first.Node); err != nil {
+				return nil, nil, nil, nil, err
+			}
+			if pageInfo.LastSortValue, err = last.SortValue(ctx, last.Node); err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+	}
+	if len(edges) == 0 && !o.compactPageInfo && o.emptyPageCursors {
+		// forward pagination continues from `after`, backward pagination from `before`
+		boundary, fallback := after, before
+		if last != nil {
+			boundary, fallback = before, after
+		}
+		if boundary == nil {
+			boundary = fallback
+		}
+		if boundary != nil {
+			startCursor, endCursor := *boundary, *boundary
+			pageInfo.StartCursor = &startCursor
+			pageInfo.EndCursor = &endCursor
+		}
+	}
 
 	if nodesOnly {
 		nodes = make([]T, len(lazyEdges))
 		for i, lazyEdge := range lazyEdges {
 			nodes[i] = lazyEdge.Node
 		}
-		return nil, nodes, pageInfo, nil
+		return nil, nodes, pageInfo, result, nil
 	}
 
-	return edges, nil, pageInfo, nil
+	return edges, nil, pageInfo, result, nil
 }
@@ -0,0 +1,54 @@
+// Package relaygql provides the GraphQL Relay connection types of paginations, e.g. to back gqlgen resolvers.
+package relaygql
+
+import (
+	relay "github.com/molon/gorelay"
+)
+
+// Connection is a connection of the GraphQL Cursor Connections Specification, with the conventional totalCount
+type Connection[T any] struct {
+	Edges      []Edge[T] `json:"edges"`
+	PageInfo   PageInfo  `json:"pageInfo"`
+	TotalCount int       `json:"totalCount"`
+}
+
+type Edge[T any] struct {
+	Node   T      `json:"node"`
+	Cursor string `json:"cursor"`
+}
+
+type PageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// ToConnection maps resp to a Connection, the nodes of a nodes-only response become edges with empty cursors
+func ToConnection[T any](resp *relay.PaginateResponse[T]) *Connection[T] {
+	if resp == nil {
+		return nil
+	}
+	var edges []Edge[T]
+	if resp.Nodes != nil {
+		edges = make([]Edge[T], len(resp.Nodes))
+		for i, node := range resp.Nodes {
+			edges[i] = Edge[T]{Node: node}
+		}
+	} else {
+		edges = make([]Edge[T], len(resp.Edges))
+		for i, edge := range resp.Edges {
+			edges[i] = Edge[T]{Node: edge.Node, Cursor: edge.Cursor}
+		}
+	}
+	return &Connection[T]{
+		Edges: edges,
+		PageInfo: PageInfo{
+			HasNextPage:     resp.PageInfo.HasNextPage,
+			HasPreviousPage: resp.PageInfo.HasPreviousPage,
+			StartCursor:     resp.PageInfo.StartCursor,
+			EndCursor:       resp.PageInfo.EndCursor,
+		},
+		TotalCount: resp.PageInfo.TotalCount,
+	}
+}
@@ -0,0 +1,64 @@
+package relaygql
+
+import (
+	"encoding/json"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type user struct {
+	ID int `json:"id"`
+}
+
+func TestToConnection(t *testing.T) {
+	marshal := func(v any) string {
+		b, err := json.Marshal(v)
+		require.NoError(t, err)
+		return string(b)
+	}
+
+	resp := &relay.PaginateResponse[*user]{
+		Edges: []relay.Edge[*user]{
+			{Node: &user{ID: 1}, Cursor: "c1", Index: lo.ToPtr(1)},
+			{Node: &user{ID: 2}, Cursor: "c2", Index: lo.ToPtr(2)},
+		},
+		PageInfo: relay.PageInfo{
+			TotalCount:  5,
+			HasNextPage: true,
+			StartCursor: lo.ToPtr("c1"),
+			EndCursor:   lo.ToPtr("c2"),
+		},
+		AppliedLimit: 2,
+	}
+	require.JSONEq(t, `{
+		"edges": [
+			{"node": {"id": 1}, "cursor": "c1"},
+			{"node": {"id": 2}, "cursor": "c2"}
+		],
+		"pageInfo": {"hasNextPage": true, "hasPreviousPage": false, "startCursor": "c1", "endCursor": "c2"},
+		"totalCount": 5
+	}`, marshal(ToConnection(resp)))
+
+	// nodes only
+	resp = &relay.PaginateResponse[*user]{
+		Nodes:    []*user{{ID: 3}},
+		PageInfo: relay.PageInfo{TotalCount: 5, HasPreviousPage: true},
+	}
+	require.JSONEq(t, `{
+		"edges": [{"node": {"id": 3}, "cursor": ""}],
+		"pageInfo": {"hasNextPage": false, "hasPreviousPage": true, "startCursor": null, "endCursor": null},
+		"totalCount": 5
+	}`, marshal(ToConnection(resp)))
+
+	// an empty page has empty edges rather than null
+	require.JSONEq(t, `{
+		"edges": [],
+		"pageInfo": {"hasNextPage": false, "hasPreviousPage": false, "startCursor": null, "endCursor": null},
+		"totalCount": 0
+	}`, marshal(ToConnection(&relay.PaginateResponse[*user]{})))
+
+	require.Nil(t, ToConnection[*user](nil))
+}
@@ -0,0 +1,61 @@
+// Package relayhttp provides helpers for serving paginations over HTTP.
+package relayhttp
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	relay "github.com/molon/gorelay"
+)
+
+// PageETag returns a strong ETag (quoted, e.g. for `If-None-Match`) of the page, hashed from the id of each node by idFn,
+// the cursors, the total count and whether there are more pages, so it changes if any node, the order or the count changes.
+// idFn may include a version of the node (e.g. its updated_at) to detect changes of the nodes themselves.
+func PageETag[T any](resp *relay.PaginateResponse[T], idFn func(T) string) string {
+	h := sha256.New()
+	if resp.Nodes != nil {
+		writeInt(h, len(resp.Nodes))
+		for _, node := range resp.Nodes {
+			writeString(h, idFn(node))
+		}
+	} else {
+		writeInt(h, len(resp.Edges))
+		for _, edge := range resp.Edges {
+			writeString(h, idFn(edge.Node))
+			writeString(h, edge.Cursor)
+		}
+	}
+
+	pageInfo := resp.PageInfo
+	writeInt(h, pageInfo.TotalCount)
+	for _, b := range []bool{pageInfo.TotalCountApproximate, pageInfo.TotalCountSkipped, pageInfo.HasNextPage, pageInfo.HasPreviousPage} {
+		writeBool(h, b)
+	}
+	for _, cursor := range []*string{pageInfo.StartCursor, pageInfo.EndCursor} {
+		writeBool(h, cursor != nil)
+		if cursor != nil {
+			writeString(h, *cursor)
+		}
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`
+}
+
+// Values are length-prefixed so that different sequences can't produce the same bytes
+func writeString(h hash.Hash, s string) {
+	writeInt(h, len(s))
+	h.Write([]byte(s))
+}
+
+func writeInt(h hash.Hash, n int) {
+	_ = binary.Write(h, binary.BigEndian, int64(n))
+}
+
+func writeBool(h hash.Hash, b bool) {
+	if b {
+		h.Write([]byte{1})
+	} else {
+		h.Write([]byte{0})
+	}
+}
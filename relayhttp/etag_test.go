@@ -0,0 +1,74 @@
+package relayhttp
+
+import (
+	"strconv"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID      int
+	Version int
+}
+
+func page(totalCount int, items ...item) *relay.PaginateResponse[item] {
+	resp := &relay.PaginateResponse[item]{
+		PageInfo: relay.PageInfo{TotalCount: totalCount, HasNextPage: true},
+	}
+	for _, it := range items {
+		resp.Edges = append(resp.Edges, relay.Edge[item]{Node: it, Cursor: strconv.Itoa(it.ID)})
+	}
+	if len(resp.Edges) > 0 {
+		resp.PageInfo.StartCursor = lo.ToPtr(resp.Edges[0].Cursor)
+		resp.PageInfo.EndCursor = lo.ToPtr(resp.Edges[len(resp.Edges)-1].Cursor)
+	}
+	return resp
+}
+
+func TestPageETag(t *testing.T) {
+	id := func(it item) string { return strconv.Itoa(it.ID) }
+	versioned := func(it item) string { return strconv.Itoa(it.ID) + "@" + strconv.Itoa(it.Version) }
+
+	etag := PageETag(page(10, item{ID: 1}, item{ID: 2}, item{ID: 3}), id)
+	require.Regexp(t, `^"[0-9a-f]{64}"$`, etag)
+
+	// stable across identical pages
+	require.Equal(t, etag, PageETag(page(10, item{ID: 1}, item{ID: 2}, item{ID: 3}), id))
+
+	for name, other := range map[string]string{
+		"Node":    PageETag(page(10, item{ID: 1}, item{ID: 2}, item{ID: 4}), id),
+		"Order":   PageETag(page(10, item{ID: 1}, item{ID: 3}, item{ID: 2}), id),
+		"Count":   PageETag(page(11, item{ID: 1}, item{ID: 2}, item{ID: 3}), id),
+		"Shorter": PageETag(page(10, item{ID: 1}, item{ID: 2}), id),
+		"Empty":   PageETag(page(10), id),
+	} {
+		require.NotEqual(t, etag, other, name)
+	}
+
+	resp := page(10, item{ID: 1}, item{ID: 2}, item{ID: 3})
+	resp.PageInfo.HasNextPage = false
+	require.NotEqual(t, etag, PageETag(resp, id))
+
+	// a change of the node itself is detected by a versioned id
+	require.Equal(t,
+		PageETag(page(10, item{ID: 1}, item{ID: 2, Version: 1}), id),
+		PageETag(page(10, item{ID: 1}, item{ID: 2, Version: 2}), id),
+	)
+	require.NotEqual(t,
+		PageETag(page(10, item{ID: 1}, item{ID: 2, Version: 1}), versioned),
+		PageETag(page(10, item{ID: 1}, item{ID: 2, Version: 2}), versioned),
+	)
+
+	// nodes only
+	nodesOnly := func(ids ...int) *relay.PaginateResponse[item] {
+		return &relay.PaginateResponse[item]{
+			Nodes:    lo.Map(ids, func(id int, _ int) item { return item{ID: id} }),
+			PageInfo: relay.PageInfo{TotalCount: 10},
+		}
+	}
+	require.Equal(t, PageETag(nodesOnly(1, 2), id), PageETag(nodesOnly(1, 2), id))
+	require.NotEqual(t, PageETag(nodesOnly(1, 2), id), PageETag(nodesOnly(2, 1), id))
+}
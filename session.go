@@ -0,0 +1,89 @@
+package relay
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Session is the state of a pagination bundled into an opaque token, so that clients resume it by the token alone,
+// instead of sending the order bys, the filter and the cursor separately.
+type Session struct {
+	OrderBys []OrderBy `json:"o,omitempty"`
+	// Filter is opaque to the pagination, e.g. the encoded filter parameters or their fingerprint,
+	// which the server reads by DecodeSession to build the query of the subsequent pages.
+	Filter         string  `json:"f,omitempty"`
+	After          *string `json:"a,omitempty"`
+	First          *int    `json:"n,omitempty"`
+	Before         *string `json:"b,omitempty"`
+	Last           *int    `json:"l,omitempty"`
+	SkipTotalCount bool    `json:"s,omitempty"`
+}
+
+// EncodeSession encodes s into a token, which is only encoded rather than encrypted,
+// so sign or encrypt it if clients must not read or tamper with the filter.
+func EncodeSession(s *Session) (string, error) {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal session")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeSession decodes a token of EncodeSession, the errors are marked with ErrInvalidCursor
+func DecodeSession(token string) (*Session, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, MarkError(errors.Wrap(err, "decode session"), ErrInvalidCursor)
+	}
+	s := &Session{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, MarkError(errors.Wrap(err, "unmarshal session"), ErrInvalidCursor)
+	}
+	return s, nil
+}
+
+// PaginateSession paginates by the session of token, and returns the token of the subsequent page in the same direction,
+// i.e. the next page, or the previous one if the session is of last, which is "" if there is no such page.
+func (p *Paginator[T]) PaginateSession(ctx context.Context, token string) (*PaginateResponse[T], string, error) {
+	s, err := DecodeSession(token)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := p.Paginate(ctx, &PaginateRequest[T]{
+		After:          s.After,
+		First:          s.First,
+		Before:         s.Before,
+		Last:           s.Last,
+		OrderBys:       s.OrderBys,
+		SkipTotalCount: s.SkipTotalCount,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	hasMore, next := resp.PageInfo.HasNextPage, resp.PageInfo.EndCursor
+	if s.Last != nil {
+		hasMore, next = resp.PageInfo.HasPreviousPage, resp.PageInfo.StartCursor
+	}
+	if !hasMore {
+		return resp, "", nil
+	}
+	if next == nil {
+		return nil, "", errors.New("cursor of the subsequent page is not available, PaginateSession can't be used with WithCompactPageInfo")
+	}
+
+	nextSession := *s
+	if s.Last != nil {
+		nextSession.Before = next
+	} else {
+		nextSession.After = next
+	}
+	nextToken, err := EncodeSession(&nextSession)
+	if err != nil {
+		return nil, "", err
+	}
+	return resp, nextToken, nil
+}
@@ -0,0 +1,296 @@
+package shardrelay
+
+import (
+	"cmp"
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	relay "github.com/molon/gorelay"
+	"github.com/molon/gorelay/cursor"
+	"github.com/molon/gorelay/gormrelay"
+	"github.com/pkg/errors"
+	"github.com/samber/lo"
+	"gorm.io/gorm"
+)
+
+// Boundary is the keyset boundary of a shard in a composite cursor.
+// After is the nearest row of the shard at or before the position of the cursor, and Before is the nearest one at or after it,
+// nil means there is no such row, e.g. the shard is exhausted in that direction.
+type Boundary struct {
+	After  *map[string]any `json:"after"`
+	Before *map[string]any `json:"before"`
+}
+
+// NewKeysetAdapter creates a relay.ApplyCursorsFunc which paginates the merged stream of the same keyset query against each shard.
+// Rows are merge-sorted by the order bys, ties across shards are broken by the index of the shard,
+// and the cursors encode the boundaries of every shard, so the pagination is gap-free even if the keys collide across shards.
+// The values of the order by fields must be comparable in memory, i.e. numbers, strings, bools or time.Time,
+// and the in-memory comparison must agree with the database, e.g. strings are compared bytewise like the C collation.
+func NewKeysetAdapter[T any](shards []*gorm.DB, opts ...gormrelay.Option[T]) relay.ApplyCursorsFunc[T] {
+	if len(shards) == 0 {
+		panic("shards must be set")
+	}
+	counters := lo.Map(shards, func(shard *gorm.DB, _ int) *gormrelay.KeysetCounter[T] {
+		return gormrelay.NewKeysetCounter[T](shard, opts...)
+	})
+	return func(ctx context.Context, req *relay.ApplyCursorsRequest) (*relay.ApplyCursorsResponse[T], error) {
+		keys := lo.Map(req.OrderBys, func(item relay.OrderBy, _ int) string {
+			return item.Field
+		})
+
+		after, err := decodeCursor(req.After, keys, len(shards))
+		if err != nil {
+			return nil, relay.MarkError(err, relay.ErrInvalidCursor)
+		}
+		before, err := decodeCursor(req.Before, keys, len(shards))
+		if err != nil {
+			return nil, relay.MarkError(err, relay.ErrInvalidCursor)
+		}
+
+		totalCount := 0
+		if !req.SkipTotalCount {
+			for _, counter := range counters {
+				n, err := counter.Count(ctx)
+				if err != nil {
+					return nil, err
+				}
+				totalCount += n
+			}
+		}
+
+		resp := &relay.ApplyCursorsResponse[T]{
+			Edges:             make([]relay.LazyEdge[T], 0),
+			TotalCount:        totalCount,
+			TotalCountSkipped: req.SkipTotalCount,
+			// Same as the keyset adapter, checking that it is not nil is sufficient.
+			HasAfterOrPrevious: after != nil,
+			HasBeforeOrNext:    before != nil,
+		}
+		if req.Limit <= 0 || (!req.SkipTotalCount && totalCount <= 0) {
+			return resp, nil
+		}
+
+		encoder, err := cursor.NewKeysetEncoder[T](keys)
+		if err != nil {
+			return nil, err
+		}
+
+		// Fetch one more row from each shard, so that a shard without rows on a side of the page is known to be exhausted
+		var items []*item[T]
+		for i, counter := range counters {
+			var shardAfter, shardBefore *map[string]any
+			if after != nil {
+				shardAfter = after[i].After
+			}
+			if before != nil {
+				shardBefore = before[i].Before
+			}
+			nodes, err := counter.Find(ctx, shardAfter, shardBefore, req.OrderBys, req.Limit+1, req.FromLast)
+			if err != nil {
+				return nil, err
+			}
+			for _, node := range nodes {
+				keyset, err := encoder.Keyset(node)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, &item[T]{node: node, keyset: keyset, shard: i})
+			}
+		}
+
+		var sortErr error
+		sort.SliceStable(items, func(i, j int) bool {
+			c, err := compareItems(items[i], items[j], req.OrderBys)
+			if err != nil && sortErr == nil {
+				sortErr = err
+			}
+			return c < 0
+		})
+		if sortErr != nil {
+			return nil, sortErr
+		}
+
+		start, end := 0, min(req.Limit, len(items))
+		if req.FromLast {
+			start, end = max(len(items)-req.Limit, 0), len(items)
+		}
+		for pos := start; pos < end; pos++ {
+			resp.Edges = append(resp.Edges, relay.LazyEdge[T]{
+				Node: items[pos].node,
+				Cursor: func(_ context.Context, _ T) (string, error) {
+					return encodeCursor(boundariesAt(items, pos, after, before, len(shards)))
+				},
+				SortValue: func(_ context.Context, _ T) (any, error) {
+					if len(req.OrderBys) == 0 {
+						return nil, nil
+					}
+					return items[pos].keyset[req.OrderBys[0].Field], nil
+				},
+			})
+		}
+		return resp, nil
+	}
+}
+
+type item[T any] struct {
+	node   T
+	keyset map[string]any
+	shard  int
+}
+
+// boundariesAt returns the boundaries of every shard at the position pos of the merged items.
+// The items of a shard are consecutive rows of it, so the nearest ones are found in items,
+// or else the shard has no more rows between the position and the boundaries of the request.
+func boundariesAt[T any](items []*item[T], pos int, after, before []Boundary, shards int) []Boundary {
+	boundaries := make([]Boundary, shards)
+	if after != nil {
+		for i := range boundaries {
+			boundaries[i].After = after[i].After
+		}
+	}
+	if before != nil {
+		for i := range boundaries {
+			boundaries[i].Before = before[i].Before
+		}
+	}
+	found := make([]bool, shards)
+	for i := pos; i >= 0; i-- {
+		if s := items[i].shard; !found[s] {
+			found[s] = true
+			boundaries[s].After = &items[i].keyset
+		}
+	}
+	found = make([]bool, shards)
+	for i := pos; i < len(items); i++ {
+		if s := items[i].shard; !found[s] {
+			found[s] = true
+			boundaries[s].Before = &items[i].keyset
+		}
+	}
+	return boundaries
+}
+
+func encodeCursor(boundaries []Boundary) (string, error) {
+	b, err := jsoniter.Marshal(boundaries)
+	if err != nil {
+		return "", errors.Wrap(err, "marshal cursor")
+	}
+	return string(b), nil
+}
+
+func decodeCursor(s *string, keys []string, shards int) ([]Boundary, error) {
+	if s == nil {
+		return nil, nil
+	}
+	var boundaries []Boundary
+	if err := jsoniter.Unmarshal([]byte(*s), &boundaries); err != nil {
+		return nil, errors.Wrap(err, "unmarshal cursor")
+	}
+	if len(boundaries) != shards {
+		return nil, errors.New("cursor length != shards length")
+	}
+	for _, boundary := range boundaries {
+		for _, keyset := range []*map[string]any{boundary.After, boundary.Before} {
+			if keyset == nil {
+				continue
+			}
+			if len(*keyset) != len(keys) {
+				return nil, errors.New("cursor length != keys length")
+			}
+			for _, key := range keys {
+				if _, ok := (*keyset)[key]; !ok {
+					return nil, errors.Errorf("key %q not found in cursor", key)
+				}
+			}
+		}
+	}
+	return boundaries, nil
+}
+
+func compareItems[T any](a, b *item[T], orderBys []relay.OrderBy) (int, error) {
+	for _, orderBy := range orderBys {
+		if c, ok := compareNulls(a.keyset[orderBy.Field], b.keyset[orderBy.Field], orderBy.Nulls); ok {
+			if c != 0 {
+				return c, nil
+			}
+			continue
+		}
+		c, err := compareValues(a.keyset[orderBy.Field], b.keyset[orderBy.Field])
+		if err != nil {
+			return 0, errors.Wrapf(err, "compare field %q", orderBy.Field)
+		}
+		if c != 0 {
+			if orderBy.Desc {
+				return -c, nil
+			}
+			return c, nil
+		}
+	}
+	return a.shard - b.shard, nil
+}
+
+// compareNulls compares a and b by the position of NULLs in the order if any of them is nil, it reports false otherwise,
+// or if the position depends on the database.
+func compareNulls(a, b any, nulls relay.Nulls) (int, bool) {
+	aNull, bNull := isNil(a), isNil(b)
+	if nulls == relay.NullsDefault || (!aNull && !bNull) {
+		return 0, false
+	}
+	c := 0
+	switch {
+	case aNull && bNull:
+		return 0, true
+	case aNull:
+		c = -1
+	default:
+		c = 1
+	}
+	if nulls == relay.NullsLast {
+		c = -c
+	}
+	return c, true
+}
+
+func isNil(v any) bool {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	return !rv.IsValid() || rv.Kind() == reflect.Ptr
+}
+
+func compareValues(a, b any) (int, error) {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for va.Kind() == reflect.Ptr && !va.IsNil() {
+		va = va.Elem()
+	}
+	for vb.Kind() == reflect.Ptr && !vb.IsNil() {
+		vb = vb.Elem()
+	}
+	if !va.IsValid() || !vb.IsValid() || va.Kind() == reflect.Ptr || vb.Kind() == reflect.Ptr {
+		return 0, errors.New("nil values can't be merged")
+	}
+	if va.Type() != vb.Type() {
+		return 0, errors.Errorf("mismatched types %s and %s", va.Type(), vb.Type())
+	}
+	if ta, ok := va.Interface().(time.Time); ok {
+		return ta.Compare(vb.Interface().(time.Time)), nil
+	}
+	switch va.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(va.Int(), vb.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(va.Uint(), vb.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(va.Float(), vb.Float()), nil
+	case reflect.String:
+		return strings.Compare(va.String(), vb.String()), nil
+	case reflect.Bool:
+		return cmp.Compare(lo.Ternary(va.Bool(), 1, 0), lo.Ternary(vb.Bool(), 1, 0)), nil
+	}
+	return 0, errors.Errorf("unsupported type %s", va.Type())
+}
@@ -0,0 +1,219 @@
+package shardrelay
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+
+	relay "github.com/molon/gorelay"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+	"github.com/theplant/testenv"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var db *gorm.DB
+
+func TestMain(m *testing.M) {
+	env, err := testenv.New().DBEnable(true).SetUp()
+	if err != nil {
+		panic(err)
+	}
+	defer env.TearDown()
+
+	db = env.DB
+	db.Logger = db.Logger.LogMode(logger.Info)
+
+	m.Run()
+}
+
+type User struct {
+	ID   int    `gorm:"primarykey;not null;" json:"id"`
+	Name string `gorm:"not null;" json:"name"`
+	Age  int    `gorm:"index;not null;" json:"age"`
+}
+
+// resetShards creates the shards as tables, the IDs collide across shards and some rows tie on all order by fields
+func resetShards(t *testing.T) []*gorm.DB {
+	var shards []*gorm.DB
+	for s := 0; s < 2; s++ {
+		table := fmt.Sprintf("users_shard_%d", s)
+		require.NoError(t, db.Exec("DROP TABLE IF EXISTS "+table).Error)
+		require.NoError(t, db.Table(table).AutoMigrate(&User{}))
+
+		vs := []*User{}
+		for i := 1; i <= 30+s*5; i++ {
+			vs = append(vs, &User{
+				ID:   i,
+				Name: fmt.Sprintf("shard%d-%d", s, i),
+				Age:  (i * (s + 1)) % 10,
+			})
+		}
+		require.NoError(t, db.Session(&gorm.Session{Logger: logger.Discard}).Table(table).Create(vs).Error)
+		shards = append(shards, db.Table(table))
+	}
+	return shards
+}
+
+func TestKeysetAdapter(t *testing.T) {
+	shards := resetShards(t)
+
+	orderBys := []relay.OrderBy{
+		{Field: "Age", Desc: true},
+		{Field: "ID", Desc: false},
+	}
+	p := relay.New(false, 10, 10, orderBys, NewKeysetAdapter[*User](shards))
+
+	// the globally ordered stream, ties across shards are ordered by the shard
+	var expected []string
+	var all []*User
+	for s := range shards {
+		var users []*User
+		require.NoError(t, db.Table(fmt.Sprintf("users_shard_%d", s)).Find(&users).Error)
+		all = append(all, users...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].Age != all[j].Age {
+			return all[i].Age > all[j].Age
+		}
+		return all[i].ID < all[j].ID
+	})
+	expected = lo.Map(all, func(u *User, _ int) string { return u.Name })
+	require.Len(t, expected, 65)
+
+	names := func(resp *relay.PaginateResponse[*User]) []string {
+		return lo.Map(resp.Edges, func(edge relay.Edge[*User], _ int) string { return edge.Node.Name })
+	}
+
+	t.Run("Forward", func(t *testing.T) {
+		var got []string
+		var after *string
+		for {
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				After: after,
+				First: lo.ToPtr(7),
+			})
+			require.NoError(t, err)
+			require.Equal(t, 65, resp.PageInfo.TotalCount)
+			got = append(got, names(resp)...)
+			if !resp.PageInfo.HasNextPage {
+				break
+			}
+			after = resp.PageInfo.EndCursor
+		}
+		require.Equal(t, expected, got)
+	})
+
+	t.Run("Backward", func(t *testing.T) {
+		var got []string
+		var before *string
+		for {
+			resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+				Before: before,
+				Last:   lo.ToPtr(6),
+			})
+			require.NoError(t, err)
+			got = append(names(resp), got...)
+			if !resp.PageInfo.HasPreviousPage {
+				break
+			}
+			before = resp.PageInfo.StartCursor
+		}
+		require.Equal(t, expected, got)
+	})
+
+	t.Run("ChangeDirection", func(t *testing.T) {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[:10], names(resp))
+
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: resp.PageInfo.EndCursor,
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[10:20], names(resp))
+
+		// back from the start of the second page
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			Before: resp.PageInfo.StartCursor,
+			Last:   lo.ToPtr(4),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[6:10], names(resp))
+
+		// between two cursors
+		first, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First: lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		resp, err = p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After:  &first.Edges[2].Cursor,
+			Before: &first.Edges[8].Cursor,
+			First:  lo.ToPtr(10),
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[3:8], names(resp))
+	})
+
+	t.Run("SkipTotalCount", func(t *testing.T) {
+		resp, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			First:          lo.ToPtr(10),
+			SkipTotalCount: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, expected[:10], names(resp))
+		require.True(t, resp.PageInfo.HasNextPage)
+		require.Zero(t, resp.PageInfo.TotalCount)
+		require.True(t, resp.PageInfo.TotalCountSkipped)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		_, err := p.Paginate(context.Background(), &relay.PaginateRequest[*User]{
+			After: lo.ToPtr(`[{"after":{"Age":1,"ID":1},"before":null}]`),
+			First: lo.ToPtr(10),
+		})
+		require.ErrorContains(t, err, "cursor length != shards length")
+	})
+}
+
+func TestCompareNulls(t *testing.T) {
+	five := lo.ToPtr(5)
+	var null *int
+
+	_, ok := compareNulls(five, null, relay.NullsDefault)
+	require.False(t, ok)
+	_, ok = compareNulls(five, lo.ToPtr(6), relay.NullsFirst)
+	require.False(t, ok)
+
+	for _, tc := range []struct {
+		a, b  any
+		nulls relay.Nulls
+		c     int
+	}{
+		{a: null, b: five, nulls: relay.NullsFirst, c: -1},
+		{a: five, b: nil, nulls: relay.NullsFirst, c: 1},
+		{a: null, b: five, nulls: relay.NullsLast, c: 1},
+		{a: five, b: nil, nulls: relay.NullsLast, c: -1},
+		{a: null, b: nil, nulls: relay.NullsLast, c: 0},
+	} {
+		c, ok := compareNulls(tc.a, tc.b, tc.nulls)
+		require.True(t, ok)
+		require.Equal(t, tc.c, c)
+	}
+
+	// NULLs are merged regardless of the direction
+	items := []*item[int]{
+		{keyset: map[string]any{"Rank": five}, shard: 0},
+		{keyset: map[string]any{"Rank": null}, shard: 1},
+	}
+	c, err := compareItems(items[0], items[1], []relay.OrderBy{{Field: "Rank", Desc: true, Nulls: relay.NullsFirst}})
+	require.NoError(t, err)
+	require.Equal(t, 1, c)
+	_, err = compareItems(items[0], items[1], []relay.OrderBy{{Field: "Rank", Desc: true}})
+	require.ErrorContains(t, err, "nil values can't be merged")
+}
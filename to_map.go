@@ -0,0 +1,34 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// PaginateToMap paginates req and indexes the nodes of the page by keyFn, with the page info for continuation.
+// It returns an error if two nodes have the same key.
+func PaginateToMap[T any, K comparable](ctx context.Context, p Pagination[T], req *PaginateRequest[T], keyFn func(T) K) (map[K]T, *PageInfo, error) {
+	resp, err := p.Paginate(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := resp.Nodes
+	if nodes == nil {
+		nodes = make([]T, len(resp.Edges))
+		for i, edge := range resp.Edges {
+			nodes[i] = edge.Node
+		}
+	}
+
+	m := make(map[K]T, len(nodes))
+	for _, node := range nodes {
+		key := keyFn(node)
+		if _, ok := m[key]; ok {
+			return nil, nil, errors.Errorf("duplicated key %v", key)
+		}
+		m[key] = node
+	}
+	return m, &resp.PageInfo, nil
+}